@@ -0,0 +1,62 @@
+package codec
+
+import (
+	"encoding"
+	"encoding/binary"
+	"fmt"
+)
+
+// Binary is a compact codec for values which either implement
+// encoding.BinaryMarshaler and encoding.BinaryUnmarshaler, are strings or
+// byte slices, or have a fixed size as defined by encoding/binary (numbers,
+// booleans and arrays or structs of those).
+//
+// Fixed size values are encoded in little endian byte order.
+type Binary[D any] struct{}
+
+func (Binary[D]) Marshal(data D) ([]byte, error) {
+	switch v := any(data).(type) {
+	case []byte:
+		return append([]byte(nil), v...), nil
+	case string:
+		return []byte(v), nil
+	case encoding.BinaryMarshaler:
+		return v.MarshalBinary()
+	}
+	if m, ok := any(&data).(encoding.BinaryMarshaler); ok {
+		return m.MarshalBinary()
+	}
+	b, err := binary.Append(nil, binary.LittleEndian, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: binary: %T", ErrUnsupportedType, data)
+	}
+	return b, nil
+}
+
+func (Binary[D]) Unmarshal(b []byte) (D, error) {
+	var data D
+	switch v := any(&data).(type) {
+	case *[]byte:
+		*v = append([]byte(nil), b...)
+		return data, nil
+	case *string:
+		*v = string(b)
+		return data, nil
+	case encoding.BinaryUnmarshaler:
+		if err := v.UnmarshalBinary(b); err != nil {
+			return data, fmt.Errorf("%w: binary: %w", ErrCorruptedData, err)
+		}
+		return data, nil
+	}
+	size := binary.Size(data)
+	if size < 0 {
+		return data, fmt.Errorf("%w: binary: %T", ErrUnsupportedType, data)
+	}
+	if size != len(b) {
+		return data, fmt.Errorf("%w: binary: expected %d bytes, got %d", ErrCorruptedData, size, len(b))
+	}
+	if _, err := binary.Decode(b, binary.LittleEndian, &data); err != nil {
+		return data, fmt.Errorf("%w: binary: %w", ErrCorruptedData, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,63 @@
+package codec_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/slawo/go-cache/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fixedValue struct {
+	ID    uint32
+	Score float64
+	Valid bool
+}
+
+func TestBinaryFixedSize(t *testing.T) {
+	c := codec.Binary[fixedValue]{}
+	value := fixedValue{ID: 42, Score: 1.5, Valid: true}
+	b, err := c.Marshal(value)
+	require.NoError(t, err)
+	assert.Len(t, b, 13)
+
+	decoded, err := c.Unmarshal(b)
+	require.NoError(t, err)
+	assert.Equal(t, value, decoded)
+
+	_, err = c.Unmarshal(b[:5])
+	assert.ErrorIs(t, err, codec.ErrCorruptedData)
+}
+
+func TestBinaryStringsAndBytes(t *testing.T) {
+	b, err := codec.Binary[string]{}.Marshal("hello")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello"), b)
+	s, err := codec.Binary[string]{}.Unmarshal(b)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", s)
+
+	raw := []byte{1, 2, 3}
+	b, err = codec.Binary[[]byte]{}.Marshal(raw)
+	require.NoError(t, err)
+	raw[0] = 9
+	assert.Equal(t, []byte{1, 2, 3}, b, "marshalled bytes should be a copy")
+}
+
+func TestBinaryMarshaler(t *testing.T) {
+	c := codec.Binary[netip.Addr]{}
+	addr := netip.MustParseAddr("10.0.0.1")
+	b, err := c.Marshal(addr)
+	require.NoError(t, err)
+	decoded, err := c.Unmarshal(b)
+	require.NoError(t, err)
+	assert.Equal(t, addr, decoded)
+}
+
+func TestBinaryUnsupportedType(t *testing.T) {
+	_, err := codec.Binary[int]{}.Marshal(1)
+	assert.ErrorIs(t, err, codec.ErrUnsupportedType)
+	_, err = codec.Binary[map[string]int]{}.Unmarshal([]byte{1})
+	assert.ErrorIs(t, err, codec.ErrUnsupportedType)
+}
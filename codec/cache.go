@@ -0,0 +1,118 @@
+package codec
+
+import (
+	"context"
+	"errors"
+
+	"github.com/slawo/go-cache/memory"
+)
+
+// ByteStore is a key/value store which only understands bytes.
+// Get returns memory.ErrNotFound for missing keys, Delete may either
+// return memory.ErrNotFound or ignore missing keys.
+//
+//go:generate mockery --name ByteStore --output mocks
+type ByteStore interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, data []byte) error
+	Delete(ctx context.Context, key string) error
+}
+
+// NewCache exposes a ByteStore as a typed memory.Cache, keys are encoded with
+// the given KeyEncoder and values with the given Codec.
+//
+// Values which fail to decode because they were written with another schema
+// version or are corrupted are dropped from the store and reported as
+// missing.
+func NewCache[K comparable, D any](store ByteStore, keys KeyEncoder[K], c Codec[D]) (*Cache[K, D], error) {
+	if store == nil {
+		return nil, errors.New("codec cache: missing store")
+	}
+	if keys == nil {
+		return nil, errors.New("codec cache: missing key encoder")
+	}
+	if c == nil {
+		return nil, errors.New("codec cache: missing codec")
+	}
+	return &Cache[K, D]{
+		ctx:   context.Background(),
+		store: store,
+		keys:  keys,
+		codec: c,
+	}, nil
+}
+
+// Cache implements memory.Cache on top of a ByteStore.
+type Cache[K comparable, D any] struct {
+	ctx   context.Context
+	store ByteStore
+	keys  KeyEncoder[K]
+	codec Codec[D]
+}
+
+// WithContext returns a copy of the cache using ctx for the calls to the
+// underlying store.
+func (c *Cache[K, D]) WithContext(ctx context.Context) *Cache[K, D] {
+	cp := *c
+	cp.ctx = ctx
+	return &cp
+}
+
+// Has reports whether the store has a valid entry for the key.
+func (c *Cache[K, D]) Has(key K) (bool, error) {
+	_, err := c.Get(key)
+	if errors.Is(err, memory.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Get returns the decoded value for the given key or memory.ErrNotFound.
+func (c *Cache[K, D]) Get(key K) (D, error) {
+	var data D
+	k, err := c.keys.EncodeKey(key)
+	if err != nil {
+		return data, err
+	}
+	b, err := c.store.Get(c.ctx, k)
+	if err != nil {
+		return data, err
+	}
+	data, err = c.codec.Unmarshal(b)
+	if errors.Is(err, ErrNewerSchema) {
+		// the entry belongs to newer readers, leave it to them
+		var zero D
+		return zero, memory.ErrNotFound
+	}
+	if errors.Is(err, ErrSchemaMismatch) || errors.Is(err, ErrCorruptedData) {
+		// the entry cannot be trusted, drop it rather than serving it
+		if err := c.store.Delete(c.ctx, k); err != nil && !errors.Is(err, memory.ErrNotFound) {
+			return data, err
+		}
+		var zero D
+		return zero, memory.ErrNotFound
+	}
+	return data, err
+}
+
+// Put encodes and stores the value for the given key.
+func (c *Cache[K, D]) Put(key K, data D) error {
+	k, err := c.keys.EncodeKey(key)
+	if err != nil {
+		return err
+	}
+	b, err := c.codec.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return c.store.Put(c.ctx, k, b)
+}
+
+// Delete removes the value for the given key.
+func (c *Cache[K, D]) Delete(key K) error {
+	k, err := c.keys.EncodeKey(key)
+	if err != nil {
+		return err
+	}
+	return c.store.Delete(c.ctx, k)
+}
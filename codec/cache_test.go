@@ -0,0 +1,120 @@
+package codec_test
+
+import (
+	"testing"
+
+	"github.com/slawo/go-cache/codec"
+	"github.com/slawo/go-cache/datastore/file"
+	"github.com/slawo/go-cache/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newIOProviderStore(t *testing.T) *codec.IOProviderStore {
+	p, err := file.NewIOProvider(t.TempDir())
+	require.NoError(t, err)
+	store, err := codec.NewIOProviderStore(p)
+	require.NoError(t, err)
+	return store
+}
+
+func TestNewCacheMissingArguments(t *testing.T) {
+	store := newIOProviderStore(t)
+	_, err := codec.NewCache[string, int](nil, codec.StringKeys[string]{}, codec.JSON[int]{})
+	assert.EqualError(t, err, "codec cache: missing store")
+	_, err = codec.NewCache[string, int](store, nil, codec.JSON[int]{})
+	assert.EqualError(t, err, "codec cache: missing key encoder")
+	_, err = codec.NewCache[string, int](store, codec.StringKeys[string]{}, nil)
+	assert.EqualError(t, err, "codec cache: missing codec")
+}
+
+func TestCacheImplementsCache(t *testing.T) {
+	var c memory.Cache[string, int]
+	c, err := codec.NewCache[string, int](newIOProviderStore(t), codec.StringKeys[string]{}, codec.JSON[int]{})
+	assert.NoError(t, err)
+	assert.NotNil(t, c)
+}
+
+func TestCacheOnIOProvider(t *testing.T) {
+	store := newIOProviderStore(t)
+	c, err := codec.NewCache[int, testValue](store, codec.FmtKeys[int]{}, codec.NewVersioned[testValue](codec.Gob[testValue]{}, 1))
+	require.NoError(t, err)
+	c = c.WithContext(t.Context())
+
+	found, err := c.Has(1)
+	assert.NoError(t, err)
+	assert.False(t, found)
+	_, err = c.Get(1)
+	assert.ErrorIs(t, err, memory.ErrNotFound)
+
+	long := testValue{Name: "a rather long name", Count: 1, Tags: []string{"x", "y", "z"}}
+	require.NoError(t, c.Put(1, long))
+	short := testValue{Name: "short"}
+	require.NoError(t, c.Put(1, short))
+
+	v, err := c.Get(1)
+	require.NoError(t, err)
+	assert.Equal(t, short, v)
+
+	found, err = c.Has(1)
+	assert.NoError(t, err)
+	assert.True(t, found)
+
+	require.NoError(t, c.Delete(1))
+	_, err = c.Get(1)
+	assert.ErrorIs(t, err, memory.ErrNotFound)
+}
+
+func TestCacheDropsOldSchemaEntries(t *testing.T) {
+	store := newIOProviderStore(t)
+	v1, err := codec.NewCache[string, testValue](store, codec.StringKeys[string]{}, codec.NewVersioned[testValue](codec.JSON[testValue]{}, 1))
+	require.NoError(t, err)
+	v2, err := codec.NewCache[string, testValue](store, codec.StringKeys[string]{}, codec.NewVersioned[testValue](codec.JSON[testValue]{}, 2))
+	require.NoError(t, err)
+
+	require.NoError(t, v1.Put("key", testValue{Name: "v1"}))
+	_, err = v2.Get("key")
+	assert.ErrorIs(t, err, memory.ErrNotFound)
+
+	// the entry has been dropped for every reader
+	_, err = v1.Get("key")
+	assert.ErrorIs(t, err, memory.ErrNotFound)
+	_, err = store.Get(t.Context(), "key")
+	assert.ErrorIs(t, err, memory.ErrNotFound)
+}
+
+func TestCacheKeepsNewerSchemaEntries(t *testing.T) {
+	store := newIOProviderStore(t)
+	v1, err := codec.NewCache[string, testValue](store, codec.StringKeys[string]{}, codec.NewVersioned[testValue](codec.JSON[testValue]{}, 1))
+	require.NoError(t, err)
+	v2, err := codec.NewCache[string, testValue](store, codec.StringKeys[string]{}, codec.NewVersioned[testValue](codec.JSON[testValue]{}, 2))
+	require.NoError(t, err)
+
+	require.NoError(t, v2.Put("key", testValue{Name: "v2"}))
+	_, err = v1.Get("key")
+	assert.ErrorIs(t, err, memory.ErrNotFound)
+
+	// the older reader misses without dropping the entry
+	got, err := v2.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, testValue{Name: "v2"}, got)
+}
+
+func TestIOProviderStoreRemovesDeletedValues(t *testing.T) {
+	p, err := file.NewIOProvider(t.TempDir())
+	require.NoError(t, err)
+	store, err := codec.NewIOProviderStore(p)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Put(t.Context(), "key", []byte("a long value")))
+	require.NoError(t, store.Put(t.Context(), "key", []byte("short")))
+	info, err := p.Stat(t.Context(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), info.Size, "the previous value should be truncated")
+
+	require.NoError(t, store.Delete(t.Context(), "key"))
+	ids, err := p.List(t.Context())
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+	require.NoError(t, store.Delete(t.Context(), "key"))
+}
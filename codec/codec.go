@@ -0,0 +1,73 @@
+// Package codec converts typed values to and from bytes so that byte oriented
+// stores, such as the datastore providers or Redis, can back a typed
+// memory.Cache.
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnsupportedType is returned when a codec cannot handle a type.
+	ErrUnsupportedType = errors.New("codec: unsupported type")
+	// ErrCorruptedData is returned when encoded data cannot be decoded.
+	ErrCorruptedData = errors.New("codec: corrupted data")
+	// ErrSchemaMismatch is returned when encoded data was written with an
+	// older schema version than the one expected.
+	ErrSchemaMismatch = errors.New("codec: schema version mismatch")
+	// ErrNewerSchema is returned when encoded data was written with a newer
+	// schema version than the one expected, for example by the replicas
+	// already upgraded during a rolling deploy.
+	ErrNewerSchema = errors.New("codec: newer schema version")
+)
+
+// Codec encodes values of type D to bytes and decodes them back.
+//
+//go:generate mockery --name Codec --output mocks
+type Codec[D any] interface {
+	Marshal(data D) ([]byte, error)
+	Unmarshal(b []byte) (D, error)
+}
+
+// JSON encodes values with encoding/json.
+type JSON[D any] struct{}
+
+func (JSON[D]) Marshal(data D) ([]byte, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("codec: json: %w", err)
+	}
+	return b, nil
+}
+
+func (JSON[D]) Unmarshal(b []byte) (D, error) {
+	var data D
+	if err := json.Unmarshal(b, &data); err != nil {
+		return data, fmt.Errorf("%w: json: %w", ErrCorruptedData, err)
+	}
+	return data, nil
+}
+
+// Gob encodes values with encoding/gob. Each value is encoded as a self
+// contained stream so it can be decoded on its own.
+type Gob[D any] struct{}
+
+func (Gob[D]) Marshal(data D) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(data); err != nil {
+		return nil, fmt.Errorf("codec: gob: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (Gob[D]) Unmarshal(b []byte) (D, error) {
+	var data D
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&data); err != nil {
+		return data, fmt.Errorf("%w: gob: %w", ErrCorruptedData, err)
+	}
+	return data, nil
+}
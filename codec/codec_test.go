@@ -0,0 +1,45 @@
+package codec_test
+
+import (
+	"testing"
+
+	"github.com/slawo/go-cache/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testValue struct {
+	Name  string
+	Count int
+	Tags  []string
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	value := testValue{Name: "tenant", Count: 3, Tags: []string{"a", "b"}}
+	codecs := map[string]codec.Codec[testValue]{
+		"json": codec.JSON[testValue]{},
+		"gob":  codec.Gob[testValue]{},
+	}
+	for name, c := range codecs {
+		t.Run(name, func(t *testing.T) {
+			b, err := c.Marshal(value)
+			require.NoError(t, err)
+			decoded, err := c.Unmarshal(b)
+			require.NoError(t, err)
+			assert.Equal(t, value, decoded)
+		})
+	}
+}
+
+func TestCodecsCorruptedData(t *testing.T) {
+	codecs := map[string]codec.Codec[testValue]{
+		"json": codec.JSON[testValue]{},
+		"gob":  codec.Gob[testValue]{},
+	}
+	for name, c := range codecs {
+		t.Run(name, func(t *testing.T) {
+			_, err := c.Unmarshal([]byte{0xff, 0x01, 0x02})
+			assert.ErrorIs(t, err, codec.ErrCorruptedData)
+		})
+	}
+}
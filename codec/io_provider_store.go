@@ -0,0 +1,95 @@
+package codec
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/slawo/go-cache/datastore"
+	"github.com/slawo/go-cache/memory"
+)
+
+// NewIOProviderStore exposes a datastore.DataIOProvider as a ByteStore.
+//
+// Each value is stored as a frame prefixed with its length, so a value can
+// be overwritten by a shorter one without the remains of the previous value
+// being read back. Deleted values are overwritten with an empty frame.
+//
+// When the provider implements datastore.DataManager, values are truncated
+// to their frame and deleted values are removed instead.
+func NewIOProviderStore(p datastore.DataIOProvider) (*IOProviderStore, error) {
+	if p == nil {
+		return nil, errors.New("io provider store: missing provider")
+	}
+	return &IOProviderStore{
+		p: p,
+	}, nil
+}
+
+// IOProviderStore implements ByteStore on top of a datastore.DataIOProvider.
+type IOProviderStore struct {
+	p datastore.DataIOProvider
+}
+
+func (s *IOProviderStore) Get(ctx context.Context, key string) ([]byte, error) {
+	r, err := s.p.GetReaderAt(ctx, key, 0)
+	if err != nil {
+		return nil, fmt.Errorf("io provider store: %w", err)
+	}
+	defer r.Close()
+	var buf []byte
+	chunk := make([]byte, 4096)
+	for {
+		n, err := r.Read(ctx, chunk)
+		buf = append(buf, chunk[:n]...)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("io provider store: %w", err)
+		}
+	}
+	size, n := binary.Uvarint(buf)
+	if n <= 0 || size == 0 || uint64(len(buf)-n) < size {
+		// missing, deleted or partially written value
+		return nil, memory.ErrNotFound
+	}
+	return buf[n : n+int(size)], nil
+}
+
+func (s *IOProviderStore) Put(ctx context.Context, key string, data []byte) error {
+	frame := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(data)), uint64(len(data)))
+	return s.write(ctx, key, append(frame, data...))
+}
+
+func (s *IOProviderStore) Delete(ctx context.Context, key string) error {
+	if m, ok := s.p.(datastore.DataManager); ok {
+		if err := m.Delete(ctx, key); err != nil && !errors.Is(err, datastore.ErrFileNotFound) {
+			return fmt.Errorf("io provider store: %w", err)
+		}
+		return nil
+	}
+	return s.write(ctx, key, binary.AppendUvarint(nil, 0))
+}
+
+func (s *IOProviderStore) write(ctx context.Context, key string, frame []byte) error {
+	w, err := s.p.GetWriterAt(ctx, key, 0)
+	if err != nil {
+		return fmt.Errorf("io provider store: %w", err)
+	}
+	if _, err := w.Write(ctx, frame); err != nil {
+		w.Close()
+		return fmt.Errorf("io provider store: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("io provider store: %w", err)
+	}
+	if m, ok := s.p.(datastore.DataManager); ok {
+		if err := m.Truncate(ctx, key, int64(len(frame))); err != nil {
+			return fmt.Errorf("io provider store: %w", err)
+		}
+	}
+	return nil
+}
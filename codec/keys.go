@@ -0,0 +1,72 @@
+package codec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidKey is returned when a key cannot be encoded.
+var ErrInvalidKey = errors.New("codec: invalid key")
+
+// KeyEncoder turns typed keys into the string keys used by byte stores.
+//
+//go:generate mockery --name KeyEncoder --output mocks
+type KeyEncoder[K comparable] interface {
+	EncodeKey(key K) (string, error)
+}
+
+// KeyEncoderFunc adapts a function to the KeyEncoder interface.
+type KeyEncoderFunc[K comparable] func(key K) (string, error)
+
+func (f KeyEncoderFunc[K]) EncodeKey(key K) (string, error) {
+	return f(key)
+}
+
+// StringKeys uses string keys as they are, empty keys are rejected.
+type StringKeys[K ~string] struct{}
+
+func (StringKeys[K]) EncodeKey(key K) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("%w: empty key", ErrInvalidKey)
+	}
+	return string(key), nil
+}
+
+// FmtKeys formats keys with fmt.Sprint, it suits numbers and types
+// implementing fmt.Stringer.
+type FmtKeys[K comparable] struct{}
+
+func (FmtKeys[K]) EncodeKey(key K) (string, error) {
+	k := fmt.Sprint(key)
+	if k == "" {
+		return "", fmt.Errorf("%w: empty key", ErrInvalidKey)
+	}
+	return k, nil
+}
+
+// PrefixKeys prepends a prefix to the keys produced by another encoder.
+func PrefixKeys[K comparable](prefix string, keys KeyEncoder[K]) KeyEncoder[K] {
+	return KeyEncoderFunc[K](func(key K) (string, error) {
+		k, err := keys.EncodeKey(key)
+		if err != nil {
+			return "", err
+		}
+		return prefix + k, nil
+	})
+}
+
+// HashedKeys replaces the keys produced by another encoder with their hex
+// encoded SHA-256 sum. It bounds the key length and makes any key safe to
+// use as a file name.
+func HashedKeys[K comparable](keys KeyEncoder[K]) KeyEncoder[K] {
+	return KeyEncoderFunc[K](func(key K) (string, error) {
+		k, err := keys.EncodeKey(key)
+		if err != nil {
+			return "", err
+		}
+		sum := sha256.Sum256([]byte(k))
+		return hex.EncodeToString(sum[:]), nil
+	})
+}
@@ -0,0 +1,43 @@
+package codec_test
+
+import (
+	"testing"
+
+	"github.com/slawo/go-cache/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStringKeys(t *testing.T) {
+	type tenantID string
+	k, err := codec.StringKeys[tenantID]{}.EncodeKey("tenant-1")
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-1", k)
+
+	_, err = codec.StringKeys[tenantID]{}.EncodeKey("")
+	assert.ErrorIs(t, err, codec.ErrInvalidKey)
+}
+
+func TestFmtKeys(t *testing.T) {
+	k, err := codec.FmtKeys[int]{}.EncodeKey(42)
+	require.NoError(t, err)
+	assert.Equal(t, "42", k)
+}
+
+func TestPrefixKeys(t *testing.T) {
+	keys := codec.PrefixKeys[int]("user:", codec.FmtKeys[int]{})
+	k, err := keys.EncodeKey(7)
+	require.NoError(t, err)
+	assert.Equal(t, "user:7", k)
+}
+
+func TestHashedKeys(t *testing.T) {
+	keys := codec.HashedKeys[string](codec.StringKeys[string]{})
+	k, err := keys.EncodeKey("../../etc/passwd")
+	require.NoError(t, err)
+	assert.Len(t, k, 64)
+	assert.NotContains(t, k, "/")
+
+	_, err = keys.EncodeKey("")
+	assert.ErrorIs(t, err, codec.ErrInvalidKey)
+}
@@ -0,0 +1,51 @@
+package codec_test
+
+import (
+	"testing"
+
+	"github.com/slawo/go-cache/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionedRoundTrip(t *testing.T) {
+	c := codec.NewVersioned[testValue](codec.JSON[testValue]{}, 2)
+	assert.Equal(t, uint16(2), c.Version())
+
+	value := testValue{Name: "v2", Count: 2}
+	b, err := c.Marshal(value)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{'g', 'c', 0, 2}, b[:4])
+
+	decoded, err := c.Unmarshal(b)
+	require.NoError(t, err)
+	assert.Equal(t, value, decoded)
+}
+
+func TestVersionedMismatch(t *testing.T) {
+	v1 := codec.NewVersioned[testValue](codec.JSON[testValue]{}, 1)
+	v2 := codec.NewVersioned[testValue](codec.JSON[testValue]{}, 2)
+
+	b, err := v1.Marshal(testValue{Name: "v1"})
+	require.NoError(t, err)
+	_, err = v2.Unmarshal(b)
+	assert.ErrorIs(t, err, codec.ErrSchemaMismatch)
+	assert.EqualError(t, err, "codec: schema version mismatch: got 1, expected 2")
+
+	b, err = v2.Marshal(testValue{Name: "v2"})
+	require.NoError(t, err)
+	_, err = v1.Unmarshal(b)
+	assert.ErrorIs(t, err, codec.ErrNewerSchema)
+	assert.NotErrorIs(t, err, codec.ErrSchemaMismatch)
+	assert.EqualError(t, err, "codec: newer schema version: got 2, expected 1")
+}
+
+func TestVersionedMissingHeader(t *testing.T) {
+	c := codec.NewVersioned[testValue](codec.JSON[testValue]{}, 1)
+	b, err := codec.JSON[testValue]{}.Marshal(testValue{Name: "raw"})
+	require.NoError(t, err)
+	_, err = c.Unmarshal(b)
+	assert.ErrorIs(t, err, codec.ErrCorruptedData)
+	_, err = c.Unmarshal(nil)
+	assert.ErrorIs(t, err, codec.ErrCorruptedData)
+}
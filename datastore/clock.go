@@ -0,0 +1,31 @@
+package datastore
+
+import "time"
+
+// Clock is the time source of the leases of the locks, it can be replaced to
+// test their expiry deterministically.
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f once d elapsed, like time.AfterFunc.
+	AfterFunc(d time.Duration, f func()) ClockTimer
+}
+
+// ClockTimer is a timer created by a Clock, its methods behave like the
+// methods of time.Timer.
+type ClockTimer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// SystemClock is the Clock of the time package.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	return time.AfterFunc(d, f)
+}
@@ -3,6 +3,7 @@ package datastore
 import (
 	"context"
 	"errors"
+	"time"
 
 	cache "github.com/slawo/go-cache"
 )
@@ -12,6 +13,9 @@ var (
 	ErrFileNotFound = errors.New("file not found")
 	// ErrInvalidFileID is returned when an invalid file ID is provided.
 	ErrInvalidFileID = errors.New("invalid file ID")
+	// ErrWriterClosed is returned when a writer is used after being
+	// committed, aborted or closed.
+	ErrWriterClosed = errors.New("writer closed")
 )
 
 //go:generate mockery --name DataIOProvider --output mocks
@@ -21,3 +25,57 @@ type DataIOProvider interface {
 	// GetFileWriter returns a writer for the file with the given ID.
 	GetWriterAt(ctx context.Context, dataID string, position int64) (cache.WriteCloser, error)
 }
+
+// TxWriteCloser is a writer whose data only becomes visible to readers once
+// committed. Closing it without committing discards the data, so a writer
+// interrupted by an error or a crash never leaves a partial file behind.
+//
+//go:generate mockery --name TxWriteCloser --output mocks
+type TxWriteCloser interface {
+	cache.WriteCloser
+	// Commit durably stores the written data, makes it visible and closes
+	// the writer.
+	Commit(ctx context.Context) error
+	// Abort discards the written data and closes the writer.
+	Abort() error
+}
+
+// TxDataIOProvider is a DataIOProvider which can hand out transactional
+// writers.
+//
+//go:generate mockery --name TxDataIOProvider --output mocks
+type TxDataIOProvider interface {
+	DataIOProvider
+	// GetTxWriterAt returns a transactional writer for the file with the
+	// given ID. Once committed, the file holds its previous content up to
+	// position followed by the written data.
+	GetTxWriterAt(ctx context.Context, dataID string, position int64) (TxWriteCloser, error)
+}
+
+// FileInfo describes a file held by a DataIOProvider.
+type FileInfo struct {
+	// FileId is the ID of the file in the DataIOProvider.
+	FileId string
+	// Size is the size of the file in bytes.
+	Size int64
+	// ModifiedAt is the time the file content was last changed.
+	ModifiedAt time.Time
+}
+
+// DataManager manages the files held by a DataIOProvider. Providers
+// implementing it return ErrFileNotFound for files which have never been
+// written or have been deleted.
+//
+//go:generate mockery --name DataManager --output mocks
+type DataManager interface {
+	// Delete removes the file with the given ID. Readers and writers already
+	// open on the file are left untouched.
+	Delete(ctx context.Context, dataID string) error
+	// Stat returns the description of the file with the given ID.
+	Stat(ctx context.Context, dataID string) (*FileInfo, error)
+	// List returns the sorted IDs of the files held by the provider.
+	List(ctx context.Context) ([]string, error)
+	// Truncate changes the size of the file with the given ID, extending
+	// it with zeroes if needed.
+	Truncate(ctx context.Context, dataID string, size int64) error
+}
@@ -0,0 +1,101 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	cache "github.com/slawo/go-cache"
+)
+
+// ErrStaleFencingToken is returned when a writer holds a fencing token older
+// than the last token seen for the same file.
+var ErrStaleFencingToken = errors.New("stale fencing token")
+
+type fencingTokenKey struct{}
+
+// WithFencingToken returns a context carrying the fencing token of the lock
+// guarding the writes made with it.
+func WithFencingToken(ctx context.Context, token uint64) context.Context {
+	return context.WithValue(ctx, fencingTokenKey{}, token)
+}
+
+// FencingTokenFromContext returns the fencing token carried by ctx.
+func FencingTokenFromContext(ctx context.Context) (uint64, bool) {
+	token, ok := ctx.Value(fencingTokenKey{}).(uint64)
+	return token, ok
+}
+
+// NewFencedIOProvider wraps a DataIOProvider so that it refuses the writes of
+// stale lock holders.
+func NewFencedIOProvider(p DataIOProvider) (*FencedIOProvider, error) {
+	if p == nil {
+		return nil, errors.New("fenced io provider: missing provider")
+	}
+	return &FencedIOProvider{
+		p:      p,
+		tokens: make(map[string]uint64),
+	}, nil
+}
+
+// FencedIOProvider checks the fencing tokens carried by the contexts of its
+// writers, see WithFencingToken. It remembers the highest token seen for
+// each file and refuses to open, or to write with, writers holding a lower
+// token. Writers opened without a token are not checked.
+//
+// The tokens are kept in memory, every writer of a file must go through the
+// same FencedIOProvider.
+type FencedIOProvider struct {
+	p      DataIOProvider
+	mu     sync.Mutex
+	tokens map[string]uint64
+}
+
+func (f *FencedIOProvider) GetReaderAt(ctx context.Context, dataID string, position int64) (cache.ReadCloser, error) {
+	return f.p.GetReaderAt(ctx, dataID, position)
+}
+
+func (f *FencedIOProvider) GetWriterAt(ctx context.Context, dataID string, position int64) (cache.WriteCloser, error) {
+	token, ok := FencingTokenFromContext(ctx)
+	if !ok {
+		return f.p.GetWriterAt(ctx, dataID, position)
+	}
+	if err := f.fence(dataID, token); err != nil {
+		return nil, err
+	}
+	w, err := f.p.GetWriterAt(ctx, dataID, position)
+	if err != nil {
+		return nil, err
+	}
+	return &fencedWriter{WriteCloser: w, f: f, dataID: dataID, token: token}, nil
+}
+
+// fence records token as the highest token of the file, it fails if a
+// higher token was already seen.
+func (f *FencedIOProvider) fence(dataID string, token uint64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if last := f.tokens[dataID]; token < last {
+		return fmt.Errorf("%w: %d < %d for %s", ErrStaleFencingToken, token, last, dataID)
+	}
+	f.tokens[dataID] = token
+	return nil
+}
+
+type fencedWriter struct {
+	cache.WriteCloser
+	f      *FencedIOProvider
+	dataID string
+	token  uint64
+}
+
+func (w *fencedWriter) Write(ctx context.Context, p []byte) (int, error) {
+	w.f.mu.Lock()
+	last := w.f.tokens[w.dataID]
+	w.f.mu.Unlock()
+	if w.token < last {
+		return 0, fmt.Errorf("%w: %d < %d for %s", ErrStaleFencingToken, w.token, last, w.dataID)
+	}
+	return w.WriteCloser.Write(ctx, p)
+}
@@ -0,0 +1,75 @@
+package datastore_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/slawo/go-cache/datastore"
+	"github.com/slawo/go-cache/datastore/memory"
+	"github.com/slawo/go-cache/datastore/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFencedIOProvider(t *testing.T) *datastore.FencedIOProvider {
+	p, err := memory.NewIOProvider()
+	require.NoError(t, err)
+	f, err := datastore.NewFencedIOProvider(p)
+	require.NoError(t, err)
+	return f
+}
+
+func TestNewFencedIOProviderFailsWithoutProvider(t *testing.T) {
+	f, err := datastore.NewFencedIOProvider(nil)
+	assert.EqualError(t, err, "fenced io provider: missing provider")
+	assert.Nil(t, f)
+}
+
+func TestFencedIOProviderTests(t *testing.T) {
+	tests.RunBaseIOProviderTests(t, tests.BaseIOProviderTestsOpts{
+		NewIOProvider: func(ctx context.Context, t *testing.T) (datastore.DataIOProvider, error) {
+			return newFencedIOProvider(t), nil
+		},
+	})
+}
+
+func TestFencedIOProviderRefusesStaleWriters(t *testing.T) {
+	f := newFencedIOProvider(t)
+	s, err := memory.NewSynchroniser()
+	require.NoError(t, err)
+
+	lock1, err := s.GetWriteLock(t.Context(), "file.bin")
+	require.NoError(t, err)
+	token1 := lock1.(datastore.FencedLock).FencingToken()
+	ctx1 := datastore.WithFencingToken(t.Context(), token1)
+	w1, err := f.GetWriterAt(ctx1, "file.bin", 0)
+	require.NoError(t, err)
+	_, err = w1.Write(ctx1, []byte("first"))
+	require.NoError(t, err)
+
+	// the first holder stalls, its lock is released and taken over
+	require.NoError(t, lock1.Unlock())
+	lock2, err := s.GetWriteLock(t.Context(), "file.bin")
+	require.NoError(t, err)
+	token2 := lock2.(datastore.FencedLock).FencingToken()
+	assert.Greater(t, token2, token1)
+	ctx2 := datastore.WithFencingToken(t.Context(), token2)
+	w2, err := f.GetWriterAt(ctx2, "file.bin", 0)
+	require.NoError(t, err)
+
+	_, err = w1.Write(ctx1, []byte("stale"))
+	assert.ErrorIs(t, err, datastore.ErrStaleFencingToken)
+	_, err = f.GetWriterAt(ctx1, "file.bin", 0)
+	assert.ErrorIs(t, err, datastore.ErrStaleFencingToken)
+
+	_, err = w2.Write(ctx2, []byte("second"))
+	require.NoError(t, err)
+	assert.NoError(t, w1.Close())
+	assert.NoError(t, w2.Close())
+
+	// other files and writers without token are not affected
+	_, err = f.GetWriterAt(ctx1, "other.bin", 0)
+	assert.NoError(t, err)
+	_, err = f.GetWriterAt(t.Context(), "file.bin", 0)
+	assert.NoError(t, err)
+}
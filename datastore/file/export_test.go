@@ -0,0 +1,36 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package file
+
+import (
+	"os"
+	"strconv"
+)
+
+// Crash releases the flock of the lock as the death of its holder would,
+// the lock file is left behind.
+func (l *FileLock) Crash() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	err := l.file.Close()
+	l.file = nil
+	close(l.unlocked)
+	return err
+}
+
+// Orphan records the dead process pid as the holder of the lock while its
+// flock stays held, as when the flock is inherited by a child of a dead
+// holder. It returns the lock file, which keeps the flock until it is
+// closed.
+func (l *FileLock) Orphan(pid int) (*os.File, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f := l.file
+	if err := f.Truncate(0); err != nil {
+		return f, err
+	}
+	_, err := f.WriteAt([]byte(l.s.host+"\n"+strconv.Itoa(pid)+"\n"), 0)
+	l.file = nil
+	close(l.unlocked)
+	return f, err
+}
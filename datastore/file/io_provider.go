@@ -5,11 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 
 	cache "github.com/slawo/go-cache"
+	"github.com/slawo/go-cache/datastore"
+	"github.com/slawo/go-cache/datastore/internal/chunked"
 )
 
 const (
@@ -42,13 +48,22 @@ type IOProvider struct {
 }
 
 func (s *IOProvider) GetReaderAt(ctx context.Context, fileId string, position int64) (cache.ReadCloser, error) {
-	p := path.Join(s.path, fileId)
+	p, err := s.filePath(fileId)
+	if err != nil {
+		return nil, err
+	}
 	file, err := os.Open(p)
 	if err != nil {
 		if !os.IsNotExist(err) {
 			return nil, fmt.Errorf("file store: unable to open file: %w", err)
 		}
 	}
+	if file == nil {
+		// the file has not been written yet, it reads as an empty file
+		return &SimpleFileReader{
+			p: position,
+		}, nil
+	}
 	if position > 0 {
 		if _, err := file.Seek(position, io.SeekStart); err != nil {
 			file.Close()
@@ -62,7 +77,10 @@ func (s *IOProvider) GetReaderAt(ctx context.Context, fileId string, position in
 }
 
 func (s *IOProvider) GetWriterAt(ctx context.Context, fileId string, position int64) (cache.WriteCloser, error) {
-	fileName := path.Join(s.path, fileId)
+	fileName, err := s.filePath(fileId)
+	if err != nil {
+		return nil, err
+	}
 	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
 		return nil, errors.New("file store: unable to open file for writing: " + err.Error())
@@ -79,6 +97,103 @@ func (s *IOProvider) GetWriterAt(ctx context.Context, fileId string, position in
 	}, nil
 }
 
+// Delete removes the file with the given ID.
+func (s *IOProvider) Delete(ctx context.Context, fileId string) error {
+	p, err := s.filePath(fileId)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s", datastore.ErrFileNotFound, fileId)
+		}
+		return fmt.Errorf("file store: unable to delete file: %w", err)
+	}
+	return nil
+}
+
+// Stat returns the size and modification time of the file with the given ID.
+func (s *IOProvider) Stat(ctx context.Context, fileId string) (*datastore.FileInfo, error) {
+	p, err := s.filePath(fileId)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Stat(p)
+	if (err != nil && os.IsNotExist(err)) || (err == nil && !f.Mode().IsRegular()) {
+		return nil, fmt.Errorf("%w: %s", datastore.ErrFileNotFound, fileId)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("file store: unable to stat file: %w", err)
+	}
+	return &datastore.FileInfo{
+		FileId:     fileId,
+		Size:       f.Size(),
+		ModifiedAt: f.ModTime(),
+	}, nil
+}
+
+// List returns the IDs of the files in the provider directory and its
+// subdirectories. Directories starting with a dot, such as the metadata
+// directory, are skipped.
+func (s *IOProvider) List(ctx context.Context) ([]string, error) {
+	ids := []string{}
+	err := filepath.WalkDir(s.path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != s.path && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		id, err := filepath.Rel(s.path, p)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, filepath.ToSlash(id))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("file store: unable to list files: %w", err)
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// Truncate changes the size of the file with the given ID.
+func (s *IOProvider) Truncate(ctx context.Context, fileId string, size int64) error {
+	if size < 0 {
+		return errors.New("file store: size cannot be negative")
+	}
+	if _, err := s.Stat(ctx, fileId); err != nil {
+		return err
+	}
+	p, err := s.filePath(fileId)
+	if err != nil {
+		return err
+	}
+	if err := os.Truncate(p, size); err != nil {
+		return fmt.Errorf("file store: unable to truncate file: %w", err)
+	}
+	return nil
+}
+
+// filePath returns the path of a file, rejecting IDs which would point
+// outside of the provider directory.
+func (s *IOProvider) filePath(fileId string) (string, error) {
+	if fileId == "" || !filepath.IsLocal(fileId) {
+		return "", fmt.Errorf("%w: %q", datastore.ErrInvalidFileID, fileId)
+	}
+	return path.Join(s.path, fileId), nil
+}
+
 type SimpleFileWriter struct {
 	mu   sync.Mutex
 	file *os.File
@@ -89,6 +204,8 @@ type SimpleFileReader struct {
 	mu   sync.Mutex
 	file *os.File
 	p    int64
+	// closed marks readers of missing files as closed
+	closed bool
 }
 
 func (r *SimpleFileReader) GetPosition(ctx context.Context) int64 {
@@ -97,14 +214,27 @@ func (r *SimpleFileReader) GetPosition(ctx context.Context) int64 {
 
 func (r *SimpleFileReader) Read(ctx context.Context, p []byte) (n int, err error) {
 	if r.file == nil {
-		return 0, errors.New("file writer: file is not open")
+		if r.closed {
+			return 0, errors.New("file writer: file is not open")
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, fmt.Errorf("file store: %w", err)
+		}
+		return 0, io.EOF
 	}
-	n, err = r.file.ReadAt(p, r.p)
-	r.p += int64(n) // Update the position after reading
-	return
+	return chunked.Do(ctx, p, func(c []byte) (int, error) {
+		n, err := r.file.ReadAt(c, r.p)
+		r.p += int64(n) // Update the position after reading
+		return n, err
+	})
 }
 
 func (r *SimpleFileReader) Close() error {
+	if r.file == nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.closed = true
+	}
 	if r.file != nil {
 		r.mu.Lock()
 		defer r.mu.Unlock()
@@ -125,9 +255,11 @@ func (w *SimpleFileWriter) Write(ctx context.Context, p []byte) (n int, err erro
 	if w.file == nil {
 		return 0, errors.New("file writer: file is not open")
 	}
-	n, err = w.file.Write(p)
-	w.p += int64(n) // Update the position after reading
-	return
+	return chunked.Do(ctx, p, func(c []byte) (int, error) {
+		n, err := w.file.Write(c)
+		w.p += int64(n) // Update the position after writing
+		return n, err
+	})
 }
 
 func (w *SimpleFileWriter) Close() error {
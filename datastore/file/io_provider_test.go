@@ -2,13 +2,16 @@ package file_test
 
 import (
 	"context"
+	"io"
 	"os"
+	"path"
 	"testing"
 
 	"github.com/slawo/go-cache/datastore"
 	"github.com/slawo/go-cache/datastore/file"
 	"github.com/slawo/go-cache/datastore/tests"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewSimpleFileStoreFailsOnMissingFolder(t *testing.T) {
@@ -54,6 +57,22 @@ func TestSimpleFileFailsOnMoreThanOneWriter(t *testing.T) {
 	assert.NotNil(t, w2)
 }
 
+func TestSimpleFileReaderOnMissingFileReadsEmpty(t *testing.T) {
+	store, err := file.NewIOProvider(t.TempDir())
+	assert.NoError(t, err)
+
+	r, err := store.GetReaderAt(t.Context(), "missing.bin", 10)
+	assert.NoError(t, err)
+	assert.NotNil(t, r)
+	n, err := r.Read(t.Context(), make([]byte, 8))
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 0, n)
+	assert.NoError(t, r.Close())
+
+	_, err = r.Read(t.Context(), make([]byte, 8))
+	assert.EqualError(t, err, "file writer: file is not open")
+}
+
 func TestRunBaseIOProviderTests(t *testing.T) {
 	d := t.TempDir()
 	newIOProvider := func(ctx context.Context, t *testing.T) (datastore.DataIOProvider, error) {
@@ -67,3 +86,51 @@ func TestRunBaseIOProviderTests(t *testing.T) {
 	}
 	tests.RunBaseIOProviderTests(t, opts)
 }
+
+func TestIOProviderListSkipsDotDirectories(t *testing.T) {
+	d := t.TempDir()
+	store, err := file.NewIOProvider(d)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(path.Join(d, file.MetaDataDir), 0755))
+	require.NoError(t, os.WriteFile(path.Join(d, file.MetaDataDir, "a.bin.json"), []byte("{}"), 0644))
+	require.NoError(t, os.MkdirAll(path.Join(d, "sub"), 0755))
+	require.NoError(t, os.WriteFile(path.Join(d, "sub", "b.bin"), []byte("b"), 0644))
+	require.NoError(t, os.WriteFile(path.Join(d, "a.bin"), []byte("a"), 0644))
+
+	ids, err := store.List(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.bin", "sub/b.bin"}, ids)
+
+	info, err := store.Stat(t.Context(), "sub/b.bin")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), info.Size)
+	_, err = store.Stat(t.Context(), "sub")
+	assert.ErrorIs(t, err, datastore.ErrFileNotFound)
+	assert.ErrorIs(t, store.Delete(t.Context(), "../a.bin"), datastore.ErrInvalidFileID)
+}
+
+func TestIOProviderRejectsPathsOutsideItsDirectory(t *testing.T) {
+	root := t.TempDir()
+	d := path.Join(root, "store")
+	require.NoError(t, os.Mkdir(d, 0755))
+	require.NoError(t, os.WriteFile(path.Join(root, "secret.bin"), []byte("secret"), 0644))
+	store, err := file.NewIOProvider(d)
+	require.NoError(t, err)
+
+	for _, id := range []string{"../secret.bin", "sub/../../secret.bin", "/etc/passwd", ""} {
+		r, err := store.GetReaderAt(t.Context(), id, 0)
+		assert.ErrorIs(t, err, datastore.ErrInvalidFileID, id)
+		assert.Nil(t, r)
+		w, err := store.GetWriterAt(t.Context(), id, 0)
+		assert.ErrorIs(t, err, datastore.ErrInvalidFileID, id)
+		assert.Nil(t, w)
+	}
+	w, err := store.GetWriterAt(t.Context(), "../escaped.bin", 0)
+	assert.ErrorIs(t, err, datastore.ErrInvalidFileID)
+	assert.Nil(t, w)
+	_, err = os.Stat(path.Join(root, "escaped.bin"))
+	assert.True(t, os.IsNotExist(err), "the writer created a file outside of the store")
+	content, err := os.ReadFile(path.Join(root, "secret.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(content))
+}
@@ -0,0 +1,423 @@
+package file
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slawo/go-cache/datastore"
+)
+
+const (
+	// MetaDataDir is the directory, relative to the data directory, holding
+	// the metadata records.
+	MetaDataDir = ".meta"
+
+	metaRecordExt     = ".json"
+	metaRecordVersion = 1
+	tempFilePattern   = "*.tmp"
+)
+
+// NewMetaDataStore opens the metadata store of the data directory at path,
+// which is usually shared with an IOProvider.
+//
+// Every record is loaded into an in-memory index on startup. While loading,
+// the store repairs what a crash may have left behind: temporary files are
+// removed, unreadable records are dropped, records of written files missing
+// from the data directory are dropped and completed parts which are not
+// backed by data on disk are cleared. The records of files which are not
+// written yet are kept.
+//
+// The store expects to be the only writer of the metadata directory.
+func NewMetaDataStore(ctx context.Context, dataPath string) (*MetaDataStore, error) {
+	if dataPath == "" {
+		return nil, errors.New("file meta data store: missing path")
+	}
+	f, err := os.Stat(dataPath)
+	if err != nil && os.IsNotExist(err) {
+		return nil, errors.New("file meta data store: path does not exist")
+	}
+	if err != nil {
+		return nil, errors.New("file meta data store: unable to access path")
+	}
+	if !f.IsDir() {
+		return nil, errors.New("file meta data store: path is not a directory")
+	}
+	s := &MetaDataStore{
+		dataPath: dataPath,
+		metaPath: path.Join(dataPath, MetaDataDir),
+		records:  make(map[string]*metaRecord),
+	}
+	if err := os.MkdirAll(s.metaPath, 0755); err != nil {
+		return nil, fmt.Errorf("file meta data store: unable to create metadata directory: %w", err)
+	}
+	if err := s.load(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// MetaDataStore is a datastore.MetaDataStore persisting one record per file
+// in the metadata directory. Records are written atomically: they are
+// written to a temporary file which is synced and renamed over the previous
+// record.
+type MetaDataStore struct {
+	mu       sync.RWMutex
+	dataPath string
+	metaPath string
+	records  map[string]*metaRecord
+}
+
+type metaRecord struct {
+	Version    int                           `json:"version"`
+	FileId     string                        `json:"fileId"`
+	Meta       *datastore.FileMeta           `json:"meta,omitempty"`
+	Completion *datastore.FileCompletionData `json:"completion,omitempty"`
+}
+
+// GetFileMeta retrieves metadata for a file by its ID.
+func (s *MetaDataStore) GetFileMeta(ctx context.Context, fileId string) (*datastore.FileMeta, error) {
+	if err := checkFileId(fileId); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if r, exists := s.records[fileId]; exists && r.Meta != nil {
+		metaCopy := *r.Meta
+		return &metaCopy, nil
+	}
+	return nil, fmt.Errorf("%w: %s", datastore.ErrFileNotFound, fileId)
+}
+
+// SaveFileMeta saves metadata for a file.
+func (s *MetaDataStore) SaveFileMeta(ctx context.Context, fileMeta *datastore.FileMeta) error {
+	if fileMeta == nil {
+		return errors.New("file metadata cannot be nil")
+	} else if err := checkFileId(fileMeta.FileId); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.record(fileMeta.FileId)
+	metaCopy := *fileMeta
+	r.Meta = &metaCopy
+	return s.write(r)
+}
+
+// DeleteFileMeta removes the metadata and completion data of a file.
+func (s *MetaDataStore) DeleteFileMeta(ctx context.Context, fileId string) error {
+	if err := checkFileId(fileId); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.records[fileId]; !exists {
+		return nil
+	}
+	if err := os.Remove(s.recordPath(fileId)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("file meta data store: unable to delete record: %w", err)
+	}
+	delete(s.records, fileId)
+	if err := syncDir(s.metaPath); err != nil {
+		return fmt.Errorf("file meta data store: %w", err)
+	}
+	return nil
+}
+
+// GetFileCompletionData retrieves completion data for a file by its ID.
+func (s *MetaDataStore) GetFileCompletionData(ctx context.Context, fileId string) (*datastore.FileCompletionData, error) {
+	if err := checkFileId(fileId); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if r, exists := s.records[fileId]; exists && r.Completion != nil {
+		return r.Completion.Clone(), nil
+	}
+	return nil, fmt.Errorf("%w: %s", datastore.ErrFileNotFound, fileId)
+}
+
+// SaveFileCompletionData saves completion data for a file.
+func (s *MetaDataStore) SaveFileCompletionData(ctx context.Context, completionData *datastore.FileCompletionData) error {
+	if completionData == nil {
+		return errors.New("completion data cannot be nil")
+	} else if err := checkFileId(completionData.FileId); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.record(completionData.FileId)
+	r.Completion = completionData.Clone()
+	return s.write(r)
+}
+
+// MarkPartCompleted sets a part in the completion data of a file.
+func (s *MetaDataStore) MarkPartCompleted(ctx context.Context, fileId string, part int) (*datastore.FileCompletionData, error) {
+	if err := checkFileId(fileId); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, exists := s.records[fileId]; !exists || r.Completion == nil {
+		return nil, fmt.Errorf("%w: %s", datastore.ErrFileNotFound, fileId)
+	}
+	r := s.record(fileId)
+	updated := r.Completion.Clone()
+	set, err := updated.SetPartCompleted(part)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %d", err, part)
+	}
+	if set {
+		updated.UpdatedAt = time.Now()
+		r.Completion = updated
+		if err := s.write(r); err != nil {
+			return nil, err
+		}
+	}
+	return updated.Clone(), nil
+}
+
+// record returns a copy of the record of a file, or a new empty record,
+// which replaces the indexed one once written. The lock must be held.
+func (s *MetaDataStore) record(fileId string) *metaRecord {
+	if r, exists := s.records[fileId]; exists {
+		cp := *r
+		return &cp
+	}
+	return &metaRecord{
+		Version: metaRecordVersion,
+		FileId:  fileId,
+	}
+}
+
+// write persists a record and updates the index, the lock must be held.
+func (s *MetaDataStore) write(r *metaRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("file meta data store: unable to encode record: %w", err)
+	}
+	if err := writeFileAtomic(s.metaPath, s.recordPath(r.FileId), b); err != nil {
+		return fmt.Errorf("file meta data store: %w", err)
+	}
+	s.records[r.FileId] = r
+	return nil
+}
+
+func (s *MetaDataStore) recordPath(fileId string) string {
+	return path.Join(s.metaPath, recordName(fileId))
+}
+
+// recordName escapes a file ID into the name of its record, the IDs too long
+// for a file name are hashed.
+func recordName(fileId string) string {
+	name := url.PathEscape(fileId)
+	if len(name) > 200 {
+		sum := sha256.Sum256([]byte(fileId))
+		name = hex.EncodeToString(sum[:])
+	}
+	return name + metaRecordExt
+}
+
+// checkFileId checks that a file ID is a path within the data directory.
+func checkFileId(fileId string) error {
+	if strings.TrimSpace(fileId) == "" {
+		return fmt.Errorf("%w: empty file ID", datastore.ErrInvalidFileID)
+	}
+	if !filepath.IsLocal(fileId) {
+		return fmt.Errorf("%w: %q", datastore.ErrInvalidFileID, fileId)
+	}
+	return nil
+}
+
+// load builds the index from the records on disk and repairs them.
+func (s *MetaDataStore) load(ctx context.Context) error {
+	entries, err := os.ReadDir(s.metaPath)
+	if err != nil {
+		return fmt.Errorf("file meta data store: unable to read metadata directory: %w", err)
+	}
+	changed := false
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("file meta data store: %w", err)
+		}
+		name := e.Name()
+		p := path.Join(s.metaPath, name)
+		if e.IsDir() {
+			continue
+		}
+		if !strings.HasSuffix(name, metaRecordExt) {
+			// temporary file left over by an interrupted write
+			if err := os.Remove(p); err != nil {
+				return fmt.Errorf("file meta data store: unable to remove temporary file: %w", err)
+			}
+			changed = true
+			continue
+		}
+		r, err := s.readRecord(p, name)
+		if err != nil {
+			if err := os.Remove(p); err != nil {
+				return fmt.Errorf("file meta data store: unable to remove invalid record: %w", err)
+			}
+			changed = true
+			continue
+		}
+		size, err := s.dataSize(r.FileId)
+		if err != nil {
+			return err
+		}
+		if size < 0 && hasData(r) {
+			// the data is gone, the record is meaningless
+			if err := os.Remove(p); err != nil {
+				return fmt.Errorf("file meta data store: unable to remove orphan record: %w", err)
+			}
+			changed = true
+			continue
+		}
+		if repairCompletion(r, size) {
+			if err := s.write(r); err != nil {
+				return err
+			}
+			continue
+		}
+		s.records[r.FileId] = r
+	}
+	if changed {
+		if err := syncDir(s.metaPath); err != nil {
+			return fmt.Errorf("file meta data store: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *MetaDataStore) readRecord(p, name string) (*metaRecord, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	r := &metaRecord{}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, err
+	}
+	if r.Version != metaRecordVersion {
+		return nil, fmt.Errorf("unsupported record version %d", r.Version)
+	}
+	fileId := r.FileId
+	if checkFileId(fileId) != nil || recordName(fileId) != name {
+		return nil, errors.New("record does not match its file name")
+	}
+	if (r.Meta != nil && r.Meta.FileId != fileId) || (r.Completion != nil && r.Completion.FileId != fileId) {
+		return nil, errors.New("record does not match its file ID")
+	}
+	return r, nil
+}
+
+// dataSize returns the size of the data file or -1 if it does not exist.
+func (s *MetaDataStore) dataSize(fileId string) (int64, error) {
+	f, err := os.Stat(path.Join(s.dataPath, fileId))
+	if os.IsNotExist(err) {
+		return -1, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("file meta data store: unable to access data file: %w", err)
+	}
+	return f.Size(), nil
+}
+
+// hasData reports whether a record describes data which was written, the
+// records of files which are not written yet have no data file.
+func hasData(r *metaRecord) bool {
+	return (r.Completion != nil && r.Completion.PartsCompleted > 0) || (r.Meta != nil && r.Meta.Complete)
+}
+
+// repairCompletion clears the completed parts which are not backed by data
+// on disk. It reports whether the record was changed.
+func repairCompletion(r *metaRecord, size int64) bool {
+	c := r.Completion
+	if c == nil || c.PartSize <= 0 {
+		return false
+	}
+	var expectedSize int64 = -1
+	if r.Meta != nil && r.Meta.FileSize > 0 {
+		expectedSize = r.Meta.FileSize
+	}
+	repaired := &datastore.FileCompletionData{
+		FileId:     c.FileId,
+		PartSize:   c.PartSize,
+		TotalParts: c.TotalParts,
+		UpdatedAt:  c.UpdatedAt,
+	}
+	for part := 0; part < len(c.Parts)*8; part++ {
+		if !c.IsPartCompleted(part) {
+			continue
+		}
+		end := int64(part+1) * c.PartSize
+		if expectedSize >= 0 && end > expectedSize {
+			end = expectedSize
+		}
+		if end <= size {
+			repaired.SetPartCompleted(part)
+		}
+	}
+	if repaired.PartsCompleted == c.PartsCompleted {
+		return false
+	}
+	repaired.UpdatedAt = time.Now()
+	r.Completion = repaired
+	if r.Meta != nil {
+		r.Meta.Complete = false
+	}
+	return true
+}
+
+// writeFileAtomic replaces the file at p with data. The data is written to
+// a temporary file in dir which is synced before being renamed to p, the
+// directory is synced afterwards so the rename survives a crash.
+func writeFileAtomic(dir, p string, data []byte) error {
+	tmp, err := os.CreateTemp(dir, tempFilePattern)
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file: %w", err)
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("unable to write temporary file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("unable to sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("unable to close temporary file: %w", err)
+	}
+	if err := os.Rename(tmpName, p); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("unable to rename temporary file: %w", err)
+	}
+	return syncDir(dir)
+}
+
+// syncDir flushes the directory entries of dir to disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("unable to open directory: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("unable to sync directory: %w", err)
+	}
+	return nil
+}
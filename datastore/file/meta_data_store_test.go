@@ -0,0 +1,177 @@
+package file_test
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slawo/go-cache/datastore"
+	"github.com/slawo/go-cache/datastore/file"
+	"github.com/slawo/go-cache/datastore/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMetaDataStoreFailsOnInvalidPath(t *testing.T) {
+	store, err := file.NewMetaDataStore(t.Context(), "")
+	assert.EqualError(t, err, "file meta data store: missing path")
+	assert.Nil(t, store)
+
+	store, err = file.NewMetaDataStore(t.Context(), t.TempDir()+"/non-existing")
+	assert.EqualError(t, err, "file meta data store: path does not exist")
+	assert.Nil(t, store)
+
+	f := t.TempDir() + "/a-file.bin"
+	require.NoError(t, os.WriteFile(f, []byte("test"), 0644))
+	store, err = file.NewMetaDataStore(t.Context(), f)
+	assert.EqualError(t, err, "file meta data store: path is not a directory")
+	assert.Nil(t, store)
+}
+
+func TestFileMetaDataStoreTests(t *testing.T) {
+	tests.RunMetaDataStoreTests(t, tests.MetaDataStoreTestsOpts{
+		NewMetaDataStore: func(ctx context.Context, t *testing.T) (datastore.MetaDataStore, error) {
+			return file.NewMetaDataStore(ctx, t.TempDir())
+		},
+	})
+}
+
+func TestFileMetaDataStorePersists(t *testing.T) {
+	d := t.TempDir()
+	fileId := "dir/file.bin"
+	require.NoError(t, os.Mkdir(path.Join(d, "dir"), 0755))
+	require.NoError(t, os.WriteFile(path.Join(d, fileId), make([]byte, 2048), 0644))
+
+	store, err := file.NewMetaDataStore(t.Context(), d)
+	require.NoError(t, err)
+	meta := &datastore.FileMeta{
+		FileId:      fileId,
+		FileSize:    2048,
+		Checksum:    "sha256:abc",
+		ContentType: "application/octet-stream",
+		CreatedAt:   time.Now(),
+		Complete:    true,
+	}
+	require.NoError(t, store.SaveFileMeta(t.Context(), meta))
+	completion := &datastore.FileCompletionData{FileId: fileId, PartSize: 1024, TotalParts: 2}
+	require.NoError(t, store.SaveFileCompletionData(t.Context(), completion))
+	completion, err = store.MarkPartCompleted(t.Context(), fileId, 0)
+	require.NoError(t, err)
+	completion, err = store.MarkPartCompleted(t.Context(), fileId, 1)
+	require.NoError(t, err)
+
+	reopened, err := file.NewMetaDataStore(t.Context(), d)
+	require.NoError(t, err)
+	got, err := reopened.GetFileMeta(t.Context(), fileId)
+	require.NoError(t, err)
+	tests.AssertFileMetaEqual(t, meta, got)
+	gotCompletion, err := reopened.GetFileCompletionData(t.Context(), fileId)
+	require.NoError(t, err)
+	tests.AssertCompletionDataEqual(t, completion, gotCompletion)
+
+	require.NoError(t, reopened.DeleteFileMeta(t.Context(), fileId))
+	reopened, err = file.NewMetaDataStore(t.Context(), d)
+	require.NoError(t, err)
+	_, err = reopened.GetFileMeta(t.Context(), fileId)
+	assert.ErrorIs(t, err, datastore.ErrFileNotFound)
+}
+
+func TestFileMetaDataStoreRepairsOnStartup(t *testing.T) {
+	d := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(d, "partial.bin"), make([]byte, 1500), 0644))
+	require.NoError(t, os.WriteFile(path.Join(d, "orphan.bin"), []byte("x"), 0644))
+
+	store, err := file.NewMetaDataStore(t.Context(), d)
+	require.NoError(t, err)
+	for _, fileId := range []string{"partial.bin", "orphan.bin"} {
+		require.NoError(t, store.SaveFileMeta(t.Context(), &datastore.FileMeta{FileId: fileId, FileSize: 3000, Complete: true}))
+		require.NoError(t, store.SaveFileCompletionData(t.Context(), &datastore.FileCompletionData{FileId: fileId, PartSize: 1000, TotalParts: 3}))
+		for part := 0; part < 3; part++ {
+			_, err := store.MarkPartCompleted(t.Context(), fileId, part)
+			require.NoError(t, err)
+		}
+	}
+
+	// simulate a crash: lost data, interrupted writes and a torn record
+	require.NoError(t, os.Remove(path.Join(d, "orphan.bin")))
+	metaDir := path.Join(d, file.MetaDataDir)
+	require.NoError(t, os.WriteFile(path.Join(metaDir, "123456.tmp"), []byte("{"), 0644))
+	require.NoError(t, os.WriteFile(path.Join(metaDir, "torn.bin.json"), []byte(`{"version":1,"fileId":"to`), 0644))
+
+	reopened, err := file.NewMetaDataStore(t.Context(), d)
+	require.NoError(t, err)
+
+	_, err = reopened.GetFileMeta(t.Context(), "orphan.bin")
+	assert.ErrorIs(t, err, datastore.ErrFileNotFound, "records without data should be dropped")
+	_, err = reopened.GetFileMeta(t.Context(), "torn.bin")
+	assert.ErrorIs(t, err, datastore.ErrFileNotFound)
+
+	meta, err := reopened.GetFileMeta(t.Context(), "partial.bin")
+	require.NoError(t, err)
+	assert.False(t, meta.Complete)
+	completion, err := reopened.GetFileCompletionData(t.Context(), "partial.bin")
+	require.NoError(t, err)
+	assert.Equal(t, 1, completion.PartsCompleted, "only the first part is backed by data")
+	assert.True(t, completion.IsPartCompleted(0))
+	assert.False(t, completion.IsPartCompleted(1))
+
+	entries, err := os.ReadDir(metaDir)
+	require.NoError(t, err)
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.Equal(t, []string{"partial.bin.json"}, names)
+}
+
+func TestFileMetaDataStoreKeepsRecordsWithoutDataOnRestart(t *testing.T) {
+	d := t.TempDir()
+	store, err := file.NewMetaDataStore(t.Context(), d)
+	require.NoError(t, err)
+	require.NoError(t, store.SaveFileMeta(t.Context(), &datastore.FileMeta{FileId: "pending.bin", FileSize: 3000}))
+	require.NoError(t, store.SaveFileCompletionData(t.Context(), &datastore.FileCompletionData{FileId: "pending.bin", PartSize: 1000, TotalParts: 3}))
+
+	reopened, err := file.NewMetaDataStore(t.Context(), d)
+	require.NoError(t, err)
+	meta, err := reopened.GetFileMeta(t.Context(), "pending.bin")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3000), meta.FileSize)
+	completion, err := reopened.GetFileCompletionData(t.Context(), "pending.bin")
+	require.NoError(t, err)
+	assert.Equal(t, 0, completion.PartsCompleted)
+	assert.Equal(t, 3, completion.TotalParts)
+}
+
+func TestFileMetaDataStoreRejectsPathsOutsideItsDirectory(t *testing.T) {
+	store, err := file.NewMetaDataStore(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	for _, fileId := range []string{"../outside.bin", "/etc/passwd", "dir/../../outside.bin"} {
+		err := store.SaveFileMeta(t.Context(), &datastore.FileMeta{FileId: fileId})
+		assert.ErrorIs(t, err, datastore.ErrInvalidFileID, fileId)
+		err = store.SaveFileCompletionData(t.Context(), &datastore.FileCompletionData{FileId: fileId})
+		assert.ErrorIs(t, err, datastore.ErrInvalidFileID, fileId)
+		_, err = store.GetFileMeta(t.Context(), fileId)
+		assert.ErrorIs(t, err, datastore.ErrInvalidFileID, fileId)
+	}
+}
+
+func TestFileMetaDataStoreHashesLongFileIds(t *testing.T) {
+	d := t.TempDir()
+	fileId := strings.Repeat("dir/", 100) + "file.bin"
+	store, err := file.NewMetaDataStore(t.Context(), d)
+	require.NoError(t, err)
+	require.NoError(t, store.SaveFileMeta(t.Context(), &datastore.FileMeta{FileId: fileId, FileSize: 10}))
+
+	reopened, err := file.NewMetaDataStore(t.Context(), d)
+	require.NoError(t, err)
+	meta, err := reopened.GetFileMeta(t.Context(), fileId)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), meta.FileSize)
+	require.NoError(t, reopened.DeleteFileMeta(t.Context(), fileId))
+	entries, err := os.ReadDir(path.Join(d, file.MetaDataDir))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,250 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package file
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/slawo/go-cache/datastore"
+)
+
+// LocksDir is the directory, relative to the data directory, holding the
+// lock files of the Synchroniser.
+const LocksDir = ".locks"
+
+// guardFile is the file, in the locks directory, locked while a lock file
+// is checked and removed. Its name does not end with .lock, it cannot clash
+// with a lock file.
+const guardFile = ".guard"
+
+// NewSynchroniser creates a synchroniser shared by the processes of a host
+// through advisory locks (flock) on files in the given data directory.
+func NewSynchroniser(path string) (*Synchroniser, error) {
+	if path == "" {
+		return nil, errors.New("file synchroniser: missing path")
+	}
+	f, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New("file synchroniser: path does not exist")
+		}
+		return nil, errors.New("file synchroniser: unable to access path")
+	}
+	if !f.IsDir() {
+		return nil, errors.New("file synchroniser: path is not a directory")
+	}
+	dir := filepath.Join(path, LocksDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("file synchroniser: unable to create locks directory: %w", err)
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("file synchroniser: unable to get host name: %w", err)
+	}
+	return &Synchroniser{
+		dir:  dir,
+		host: host,
+	}, nil
+}
+
+// Synchroniser is a datastore.DataSynchroniser for the processes of a single
+// host sharing a data directory. Every lock is a file holding the host name
+// and PID of its holder, locked with flock and removed on unlock.
+//
+// The kernel releases the flock of a process which exits, a lock file which
+// is still locked although its holder is dead, for example because the lock
+// was inherited by a child process, is considered stale and is replaced.
+// Holders running on another host, or in another PID namespace with another
+// host name, are never considered dead.
+//
+// The lock files are only removed, and the holder of a newly locked file
+// only recorded, while holding the flock of a guard file shared by every
+// lock. A lock file cannot be replaced between its check and its removal,
+// and a fresh holder cannot be mistaken for the dead one it replaces.
+type Synchroniser struct {
+	dir  string
+	host string
+}
+
+func (s *Synchroniser) GetWriteLock(ctx context.Context, lockID string) (datastore.DataWriteLock, error) {
+	if strings.TrimSpace(lockID) == "" {
+		return nil, datastore.ErrInvalidLockID
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("file synchroniser: %w", err)
+	}
+	p := filepath.Join(s.dir, lockFileName(lockID))
+	for {
+		file, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("file synchroniser: unable to open lock file: %w", err)
+		}
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			var removed bool
+			gerr := s.guarded(func() {
+				removed = s.isStale(file) && removeIfUnchanged(p, file)
+			})
+			file.Close()
+			if gerr != nil {
+				return nil, gerr
+			}
+			if removed {
+				continue
+			}
+			return nil, fmt.Errorf("%w: %s", datastore.ErrLockAlreadyHeld, lockID)
+		} else if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("file synchroniser: unable to lock file: %w", err)
+		}
+		var current bool
+		var werr error
+		gerr := s.guarded(func() {
+			// the file may have been removed by its previous holder or
+			// replaced as stale since it was opened
+			current = isCurrent(p, file)
+			if current {
+				werr = writeHolder(file, s.host)
+			}
+		})
+		if gerr == nil && !current {
+			file.Close()
+			continue
+		}
+		if err := errors.Join(gerr, werr); err != nil {
+			s.guarded(func() { removeIfUnchanged(p, file) })
+			file.Close()
+			return nil, fmt.Errorf("file synchroniser: unable to write lock file: %w", err)
+		}
+		return &FileLock{
+			s:        s,
+			path:     p,
+			file:     file,
+			unlocked: make(chan struct{}),
+		}, nil
+	}
+}
+
+// guarded calls fn while holding the flock of the guard file.
+func (s *Synchroniser) guarded(fn func()) error {
+	guard, err := os.OpenFile(filepath.Join(s.dir, guardFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("file synchroniser: unable to open guard file: %w", err)
+	}
+	defer guard.Close()
+	if err := syscall.Flock(int(guard.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("file synchroniser: unable to lock guard file: %w", err)
+	}
+	fn()
+	return nil
+}
+
+// isStale checks whether the holder recorded in a locked file is a dead
+// process of this host.
+func (s *Synchroniser) isStale(file *os.File) bool {
+	buf := make([]byte, 512)
+	n, _ := file.ReadAt(buf, 0)
+	host, pid, ok := strings.Cut(strings.TrimSpace(string(buf[:n])), "\n")
+	if !ok || host != s.host {
+		return false
+	}
+	id, err := strconv.Atoi(pid)
+	if err != nil || id <= 0 || id == os.Getpid() {
+		return false
+	}
+	return errors.Is(syscall.Kill(id, 0), syscall.ESRCH)
+}
+
+// FileLock is a lock held through a flock on a lock file. A flock is held
+// until it is released or its holder exits, FileLock does not implement
+// datastore.LosableLock.
+type FileLock struct {
+	mu       sync.Mutex
+	s        *Synchroniser
+	path     string
+	file     *os.File
+	unlocked chan struct{}
+}
+
+// Unlock removes the lock file and releases the flock.
+func (l *FileLock) Unlock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return datastore.ErrLockNotHeld
+	}
+	gerr := l.s.guarded(func() { removeIfUnchanged(l.path, l.file) })
+	err := errors.Join(gerr, syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN))
+	if cerr := l.file.Close(); err == nil {
+		err = cerr
+	}
+	l.file = nil
+	close(l.unlocked)
+	if err != nil {
+		return fmt.Errorf("file synchroniser: unable to unlock file: %w", err)
+	}
+	return nil
+}
+
+func (l *FileLock) Unlocked() bool {
+	select {
+	case <-l.unlocked:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *FileLock) WaitUnlocked() <-chan struct{} {
+	return l.unlocked
+}
+
+// writeHolder records the host name and PID of the holder of a lock.
+func writeHolder(file *os.File, host string) error {
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := file.WriteAt([]byte(host+"\n"+strconv.Itoa(os.Getpid())+"\n"), 0)
+	return err
+}
+
+// isCurrent checks whether the open file is still the file at path p.
+func isCurrent(p string, file *os.File) bool {
+	opened, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(p)
+	return err == nil && os.SameFile(opened, current)
+}
+
+// removeIfUnchanged removes the file at path p if it is the open file, the
+// caller must hold the flock of the guard file.
+func removeIfUnchanged(p string, file *os.File) bool {
+	if !isCurrent(p, file) {
+		return false
+	}
+	return os.Remove(p) == nil
+}
+
+// lockFileName escapes a lock ID into a file name, the IDs too long for a
+// file name are hashed.
+func lockFileName(lockID string) string {
+	name := url.PathEscape(lockID)
+	if len(name) > 200 {
+		sum := sha256.Sum256([]byte(lockID))
+		name = hex.EncodeToString(sum[:])
+	}
+	return name + ".lock"
+}
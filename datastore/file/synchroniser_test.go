@@ -0,0 +1,216 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package file_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/slawo/go-cache/datastore"
+	"github.com/slawo/go-cache/datastore/file"
+	"github.com/slawo/go-cache/datastore/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSynchroniserFailsOnMissingFolder(t *testing.T) {
+	s, err := file.NewSynchroniser("")
+	assert.EqualError(t, err, "file synchroniser: missing path")
+	assert.Nil(t, s)
+}
+
+func TestNewSynchroniserFailsOnNonExistingFolder(t *testing.T) {
+	s, err := file.NewSynchroniser(t.TempDir() + "/non-existing")
+	assert.EqualError(t, err, "file synchroniser: path does not exist")
+	assert.Nil(t, s)
+}
+
+func TestSynchroniserGetLockWithEmptyKey(t *testing.T) {
+	s, err := file.NewSynchroniser(t.TempDir())
+	require.NoError(t, err)
+
+	lock, err := s.GetWriteLock(context.Background(), " ")
+	assert.ErrorIs(t, err, datastore.ErrInvalidLockID)
+	assert.Nil(t, lock)
+}
+
+func TestSynchroniserGetLock(t *testing.T) {
+	d := t.TempDir()
+	s, err := file.NewSynchroniser(d)
+	require.NoError(t, err)
+
+	lock, err := s.GetWriteLock(context.Background(), "some/file")
+	require.NoError(t, err)
+	assert.FileExists(t, path.Join(d, file.LocksDir, "some%2Ffile.lock"))
+
+	_, err = s.GetWriteLock(context.Background(), "some/file")
+	assert.ErrorIs(t, err, datastore.ErrLockAlreadyHeld)
+
+	require.NoError(t, lock.Unlock())
+	assert.True(t, lock.Unlocked())
+	assert.NoFileExists(t, path.Join(d, file.LocksDir, "some%2Ffile.lock"))
+	assert.EqualError(t, lock.Unlock(), "lock is not held")
+
+	lock, err = s.GetWriteLock(context.Background(), "some/file")
+	require.NoError(t, err)
+	assert.NoError(t, lock.Unlock())
+}
+
+func TestSynchroniserGetLockMultiTest(t *testing.T) {
+	d := t.TempDir()
+	tests.RunParallelLockTests(t, tests.ParallelLockTestsOpts{
+		MaxSyncs: 5,
+		MaxTries: 10,
+		MaxLocks: 50,
+		NewDataSynchroniser: func(ctx context.Context, t *testing.T) (datastore.DataSynchroniser, error) {
+			return file.NewSynchroniser(d)
+		},
+	})
+}
+
+func TestSynchroniserLockHarness(t *testing.T) {
+	d := t.TempDir()
+	tests.RunLockHarness(t, tests.LockHarnessOpts{
+		NewDataSynchroniser: func(ctx context.Context, t *testing.T) (datastore.DataSynchroniser, error) {
+			return file.NewSynchroniser(d)
+		},
+		// the flock of a dead process is released by the kernel while its
+		// lock file is left behind
+		Crash: func(t *testing.T, s datastore.DataSynchroniser, lock datastore.DataWriteLock) {
+			require.NoError(t, lock.(*file.FileLock).Crash())
+		},
+	})
+}
+
+func TestSynchroniserStaleLockHarness(t *testing.T) {
+	d := t.TempDir()
+	cmd := exec.Command("true")
+	require.NoError(t, cmd.Run())
+	tests.RunLockHarness(t, tests.LockHarnessOpts{
+		NewDataSynchroniser: func(ctx context.Context, t *testing.T) (datastore.DataSynchroniser, error) {
+			return file.NewSynchroniser(d)
+		},
+		// the flock outlives its dead holder, the lock is stale
+		Crash: func(t *testing.T, s datastore.DataSynchroniser, lock datastore.DataWriteLock) {
+			f, err := lock.(*file.FileLock).Orphan(cmd.Process.Pid)
+			t.Cleanup(func() { f.Close() })
+			require.NoError(t, err)
+		},
+	})
+}
+
+// holdLockFile locks the lock file of lockID as if it was held by pid.
+func holdLockFile(t *testing.T, d, lockID string, pid int) {
+	host, err := os.Hostname()
+	require.NoError(t, err)
+	f, err := os.OpenFile(path.Join(d, file.LocksDir, lockID+".lock"), os.O_RDWR|os.O_CREATE, 0644)
+	require.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+	require.NoError(t, syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB))
+	_, err = fmt.Fprintf(f, "%s\n%d\n", host, pid)
+	require.NoError(t, err)
+}
+
+func TestSynchroniserReplacesStaleLock(t *testing.T) {
+	d := t.TempDir()
+	s, err := file.NewSynchroniser(d)
+	require.NoError(t, err)
+
+	cmd := exec.Command("true")
+	require.NoError(t, cmd.Run())
+	holdLockFile(t, d, "stale", cmd.Process.Pid)
+
+	lock, err := s.GetWriteLock(context.Background(), "stale")
+	require.NoError(t, err)
+	assert.NoError(t, lock.Unlock())
+}
+
+func TestSynchroniserReplacesStaleLockOnce(t *testing.T) {
+	d := t.TempDir()
+	_, err := file.NewSynchroniser(d)
+	require.NoError(t, err)
+	cmd := exec.Command("true")
+	require.NoError(t, cmd.Run())
+	for range 20 {
+		lockID := "stale" + strconv.Itoa(rand.Int())
+		holdLockFile(t, d, lockID, cmd.Process.Pid)
+
+		var (
+			wg    sync.WaitGroup
+			mu    sync.Mutex
+			locks []datastore.DataWriteLock
+		)
+		for range 8 {
+			s, err := file.NewSynchroniser(d)
+			require.NoError(t, err)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				lock, err := s.GetWriteLock(context.Background(), lockID)
+				if err != nil {
+					assert.ErrorIs(t, err, datastore.ErrLockAlreadyHeld)
+					return
+				}
+				mu.Lock()
+				locks = append(locks, lock)
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+		require.Len(t, locks, 1, "the stale lock was replaced by several holders")
+		require.NoError(t, locks[0].Unlock())
+	}
+}
+
+func TestSynchroniserKeepsLockOfLiveHolder(t *testing.T) {
+	d := t.TempDir()
+	s, err := file.NewSynchroniser(d)
+	require.NoError(t, err)
+
+	holdLockFile(t, d, "live", os.Getppid())
+
+	_, err = s.GetWriteLock(context.Background(), "live")
+	assert.ErrorIs(t, err, datastore.ErrLockAlreadyHeld)
+}
+
+func TestSynchroniserAcrossProcesses(t *testing.T) {
+	if d := os.Getenv("FILE_SYNCHRONISER_HELPER_DIR"); d != "" {
+		s, err := file.NewSynchroniser(d)
+		require.NoError(t, err)
+		_, err = s.GetWriteLock(context.Background(), "shared")
+		require.NoError(t, err)
+		fmt.Println("locked " + strconv.Itoa(os.Getpid()))
+		select {}
+	}
+	d := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSynchroniserAcrossProcesses$")
+	cmd.Env = append(os.Environ(), "FILE_SYNCHRONISER_HELPER_DIR="+d)
+	out, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	defer cmd.Process.Kill()
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() && scanner.Text() != "locked "+strconv.Itoa(cmd.Process.Pid) {
+	}
+
+	s, err := file.NewSynchroniser(d)
+	require.NoError(t, err)
+	_, err = s.GetWriteLock(context.Background(), "shared")
+	assert.ErrorIs(t, err, datastore.ErrLockAlreadyHeld)
+
+	require.NoError(t, cmd.Process.Kill())
+	cmd.Wait()
+
+	lock, err := s.GetWriteLock(context.Background(), "shared")
+	require.NoError(t, err)
+	assert.NoError(t, lock.Unlock())
+}
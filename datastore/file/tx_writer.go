@@ -0,0 +1,206 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slawo/go-cache/datastore"
+	"github.com/slawo/go-cache/datastore/internal/chunked"
+)
+
+// StagingDir is the directory, relative to the data directory, holding the
+// data of the transactional writers until it is committed.
+const StagingDir = ".staging"
+
+// GetTxWriterAt returns a transactional writer for the given file. The data
+// is written to a staging file which is synced and renamed over the file on
+// commit, the file is never seen partially written.
+//
+// When position is not zero the staging file starts with a copy of the
+// first position bytes of the file.
+func (s *IOProvider) GetTxWriterAt(ctx context.Context, fileId string, position int64) (datastore.TxWriteCloser, error) {
+	target, err := s.filePath(fileId)
+	if err != nil {
+		return nil, err
+	}
+	if position < 0 {
+		return nil, errors.New("file store: position cannot be negative")
+	}
+	staging := path.Join(s.path, StagingDir)
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		return nil, fmt.Errorf("file store: unable to create staging directory: %w", err)
+	}
+	file, err := os.CreateTemp(staging, tempFilePattern)
+	if err != nil {
+		return nil, fmt.Errorf("file store: unable to create staging file: %w", err)
+	}
+	w := &TxFileWriter{
+		file:   file,
+		target: target,
+		p:      position,
+	}
+	if position > 0 {
+		if err := copyPrefix(file, target, position); err != nil {
+			w.discard()
+			return nil, fmt.Errorf("file store: unable to copy file to staging: %w", err)
+		}
+	}
+	return w, nil
+}
+
+// CleanStaging removes the staging files last modified before the given
+// time, which are left behind by writers interrupted by a crash.
+func (s *IOProvider) CleanStaging(ctx context.Context, before time.Time) error {
+	staging := path.Join(s.path, StagingDir)
+	entries, err := os.ReadDir(staging)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("file store: unable to read staging directory: %w", err)
+	}
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("file store: %w", err)
+		}
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".tmp") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || !info.ModTime().Before(before) {
+			continue
+		}
+		if err := os.Remove(path.Join(staging, e.Name())); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("file store: unable to remove staging file: %w", err)
+		}
+	}
+	return nil
+}
+
+// TxFileWriter is a transactional writer writing to a staging file.
+type TxFileWriter struct {
+	mu     sync.Mutex
+	file   *os.File
+	target string
+	p      int64
+}
+
+func (w *TxFileWriter) GetPosition(ctx context.Context) int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.p
+}
+
+func (w *TxFileWriter) Write(ctx context.Context, p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return 0, datastore.ErrWriterClosed
+	}
+	return chunked.Do(ctx, p, func(c []byte) (int, error) {
+		n, err := w.file.WriteAt(c, w.p)
+		w.p += int64(n)
+		return n, err
+	})
+}
+
+// Commit syncs the staging file and renames it over the target file.
+func (w *TxFileWriter) Commit(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return datastore.ErrWriterClosed
+	}
+	if err := ctx.Err(); err != nil {
+		w.discard()
+		return fmt.Errorf("file store: %w", err)
+	}
+	file := w.file
+	w.file = nil
+	tmpName := file.Name()
+	if err := file.Chmod(0644); err != nil {
+		file.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("file store: unable to set staging file mode: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("file store: unable to sync staging file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("file store: unable to close staging file: %w", err)
+	}
+	dir := path.Dir(w.target)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("file store: unable to create directory: %w", err)
+	}
+	if err := os.Rename(tmpName, w.target); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("file store: unable to commit staging file: %w", err)
+	}
+	if err := syncDir(dir); err != nil {
+		return fmt.Errorf("file store: %w", err)
+	}
+	return nil
+}
+
+// Abort removes the staging file.
+func (w *TxFileWriter) Abort() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return datastore.ErrWriterClosed
+	}
+	return w.discard()
+}
+
+// Close removes the staging file if the writer was neither committed nor
+// aborted, closing a finished writer is a no-op.
+func (w *TxFileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.discard()
+}
+
+// discard closes and removes the staging file, the lock must be held.
+func (w *TxFileWriter) discard() error {
+	file := w.file
+	w.file = nil
+	closeErr := file.Close()
+	if err := os.Remove(file.Name()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("file store: unable to remove staging file: %w", err)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("file store: unable to close staging file: %w", closeErr)
+	}
+	return nil
+}
+
+// copyPrefix copies the first size bytes of the file at src to dst, dst is
+// extended with zeroes if src is shorter.
+func copyPrefix(dst *os.File, src string, size int64) error {
+	f, err := os.Open(src)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if f != nil {
+		defer f.Close()
+		if _, err := io.CopyN(dst, f, size); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+	}
+	return dst.Truncate(size)
+}
@@ -0,0 +1,79 @@
+package file_test
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/slawo/go-cache/datastore/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxFileWriterLeavesNoStagingFiles(t *testing.T) {
+	d := t.TempDir()
+	store, err := file.NewIOProvider(d)
+	require.NoError(t, err)
+
+	w, err := store.GetTxWriterAt(t.Context(), "dir/committed.bin", 0)
+	require.NoError(t, err)
+	_, err = w.Write(t.Context(), []byte("committed"))
+	require.NoError(t, err)
+	require.NoError(t, w.Commit(t.Context()))
+
+	w, err = store.GetTxWriterAt(t.Context(), "aborted.bin", 0)
+	require.NoError(t, err)
+	_, err = w.Write(t.Context(), []byte("aborted"))
+	require.NoError(t, err)
+	require.NoError(t, w.Abort())
+
+	entries, err := os.ReadDir(path.Join(d, file.StagingDir))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	data, err := os.ReadFile(path.Join(d, "dir/committed.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("committed"), data)
+	info, err := os.Stat(path.Join(d, "dir/committed.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+	_, err = os.Stat(path.Join(d, "aborted.bin"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestTxFileWriterRejectsInvalidIds(t *testing.T) {
+	store, err := file.NewIOProvider(t.TempDir())
+	require.NoError(t, err)
+	_, err = store.GetTxWriterAt(t.Context(), "../escape.bin", 0)
+	assert.Error(t, err)
+}
+
+func TestCleanStaging(t *testing.T) {
+	d := t.TempDir()
+	store, err := file.NewIOProvider(d)
+	require.NoError(t, err)
+	require.NoError(t, store.CleanStaging(t.Context(), time.Now()), "a missing staging directory is not an error")
+
+	// a writer interrupted by a crash
+	stale, err := store.GetTxWriterAt(t.Context(), "stale.bin", 0)
+	require.NoError(t, err)
+	_, err = stale.Write(t.Context(), []byte("stale"))
+	require.NoError(t, err)
+	entries, err := os.ReadDir(path.Join(d, file.StagingDir))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(path.Join(d, file.StagingDir, entries[0].Name()), old, old))
+
+	w, err := store.GetTxWriterAt(t.Context(), "active.bin", 0)
+	require.NoError(t, err)
+	_, err = w.Write(t.Context(), []byte("active"))
+	require.NoError(t, err)
+
+	require.NoError(t, store.CleanStaging(t.Context(), time.Now().Add(-time.Minute)))
+	entries, err = os.ReadDir(path.Join(d, file.StagingDir))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "recent staging files are kept")
+	require.NoError(t, w.Commit(t.Context()))
+	assert.NoError(t, stale.Close())
+}
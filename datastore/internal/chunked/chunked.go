@@ -0,0 +1,27 @@
+// Package chunked splits the long reads and writes of the io providers into
+// chunks, so that they can be interrupted through their context.
+package chunked
+
+import (
+	"context"
+	"fmt"
+)
+
+// Size is the size of the chunks long reads and writes are split into.
+const Size = 64 << 10
+
+// Do calls fn on consecutive chunks of p until p is processed, fn fails or
+// processes a short chunk. The context is checked before each chunk.
+func Do(ctx context.Context, p []byte, fn func(c []byte) (int, error)) (n int, err error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return n, fmt.Errorf("io provider: %w", err)
+		}
+		c := p[n:min(len(p), n+Size)]
+		m, err := fn(c)
+		n += m
+		if err != nil || m < len(c) || n == len(p) {
+			return n, err
+		}
+	}
+}
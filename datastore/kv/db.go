@@ -0,0 +1,233 @@
+package kv
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/slawo/go-cache/memory"
+)
+
+var (
+	// ErrKeyNotFound is returned when a key is not in the database.
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrKeyRequired is returned when an empty key is given.
+	ErrKeyRequired = errors.New("key required")
+	// ErrKeyTooLarge is returned when a key is larger than MaxKeySize.
+	ErrKeyTooLarge = errors.New("key too large")
+	// ErrTxNotWritable is returned when a read-only transaction is modified.
+	ErrTxNotWritable = errors.New("transaction not writable")
+	// ErrClosed is returned when the database is used after being closed.
+	ErrClosed = errors.New("database closed")
+	// ErrLocked is returned when the database file is already opened by
+	// another DB.
+	ErrLocked = errors.New("database locked")
+)
+
+// nodeCacheSize is the number of decoded pages kept in memory.
+const nodeCacheSize = 1024
+
+// Open opens the database file at path, creating it if it does not exist.
+//
+// The file is locked with an exclusive flock until the DB is closed, opening
+// it again, from this process or another one, fails with ErrLocked.
+func Open(path string) (*DB, error) {
+	if path == "" {
+		return nil, errors.New("kv: missing path")
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("kv: unable to open database: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("kv: unable to lock database: %w", err)
+	}
+	cache, err := memory.NewLRU[pgid, *node](nodeCacheSize)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("kv: %w", err)
+	}
+	db := &DB{
+		f:     f,
+		cache: cache,
+	}
+	if err := db.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// DB is an embedded key/value store keeping its keys sorted in a copy on
+// write B+tree held in a single file.
+//
+// Writes never overwrite the pages of the last committed tree: modified
+// pages are written to free pages, synced, and only then is the meta page
+// pointing to the new tree written and synced. A crash at any point leaves
+// the database in the state of the last successful commit.
+//
+// Reads run concurrently, writes are serialised and block reads.
+type DB struct {
+	mu            sync.RWMutex
+	f             *os.File
+	closed        bool
+	meta          meta
+	free          []pgid // pages free in the last commit, sorted
+	freelistPages []pgid // pages holding the freelist of the last commit
+	cache         *memory.LRU[pgid, *node]
+}
+
+// View runs fn in a read-only transaction.
+func (db *DB) View(fn func(tx *Tx) error) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return ErrClosed
+	}
+	tx, err := db.begin(false)
+	if err != nil {
+		return err
+	}
+	return fn(tx)
+}
+
+// Update runs fn in a read-write transaction which is committed if fn
+// returns nil and discarded otherwise.
+func (db *DB) Update(fn func(tx *Tx) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return ErrClosed
+	}
+	tx, err := db.begin(true)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.commit()
+}
+
+// Close closes the database file.
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return nil
+	}
+	db.closed = true
+	err := unlockFile(db.f)
+	if cerr := db.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (db *DB) begin(writable bool) (*Tx, error) {
+	tx := &Tx{
+		db:        db,
+		writable:  writable,
+		pageCount: db.meta.pageCount,
+	}
+	if writable {
+		tx.free = slices.Clone(db.free)
+	}
+	if db.meta.root != 0 {
+		root, err := tx.load(db.meta.root)
+		if err != nil {
+			return nil, err
+		}
+		tx.root = root
+	}
+	return tx, nil
+}
+
+// load reads the meta pages and the freelist, initialising the file if it
+// is empty.
+func (db *DB) load() error {
+	info, err := db.f.Stat()
+	if err != nil {
+		return fmt.Errorf("kv: unable to access database: %w", err)
+	}
+	if info.Size() == 0 {
+		return db.init()
+	}
+	var (
+		found bool
+		errs  []error
+	)
+	for i := range 2 {
+		buf, err := db.readPage(pgid(i))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		m, err := readMeta(buf)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("kv: meta page %d: %w", i, err))
+			continue
+		}
+		if !found || m.txid > db.meta.txid {
+			db.meta = m
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("kv: invalid database file: %w", errors.Join(errs...))
+	}
+	for id := db.meta.freelist; id != 0; {
+		buf, err := db.readPage(id)
+		if err != nil {
+			return err
+		}
+		typ, count, next := readHeader(buf)
+		if typ != pageFreelist || count > freelistCapacity {
+			return fmt.Errorf("kv: page %d is not a freelist page", id)
+		}
+		db.freelistPages = append(db.freelistPages, id)
+		for i := range int(count) {
+			db.free = append(db.free, pgid(binary.LittleEndian.Uint64(buf[pageHeaderSize+i*8:])))
+		}
+		id = next
+	}
+	slices.Sort(db.free)
+	return nil
+}
+
+func (db *DB) init() error {
+	db.meta = meta{pageCount: 2}
+	buf := make([]byte, pageSize)
+	db.meta.write(buf)
+	for i := range 2 {
+		if err := db.writePage(pgid(i), buf); err != nil {
+			return err
+		}
+	}
+	if err := db.f.Sync(); err != nil {
+		return fmt.Errorf("kv: unable to sync database: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) readPage(id pgid) ([]byte, error) {
+	buf := make([]byte, pageSize)
+	if _, err := db.f.ReadAt(buf, int64(id)*pageSize); err != nil {
+		return nil, fmt.Errorf("kv: unable to read page %d: %w", id, err)
+	}
+	return buf, nil
+}
+
+// writePage writes a page, dropping the node cached for it as the page may
+// have been reused.
+func (db *DB) writePage(id pgid, buf []byte) error {
+	db.cache.Delete(id)
+	if _, err := db.f.WriteAt(buf, int64(id)*pageSize); err != nil {
+		return fmt.Errorf("kv: unable to write page %d: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+package kv_test
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/slawo/go-cache/datastore/kv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openDB(t *testing.T, p string) *kv.DB {
+	t.Helper()
+	db, err := kv.Open(p)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestOpenFailsOnInvalidFile(t *testing.T) {
+	db, err := kv.Open("")
+	assert.EqualError(t, err, "kv: missing path")
+	assert.Nil(t, db)
+
+	p := path.Join(t.TempDir(), "invalid.db")
+	require.NoError(t, os.WriteFile(p, bytes.Repeat([]byte("x"), 3*4096), 0644))
+	db, err = kv.Open(p)
+	assert.ErrorContains(t, err, "kv: invalid database file")
+	assert.Nil(t, db)
+}
+
+func TestDBBasicOperations(t *testing.T) {
+	db := openDB(t, path.Join(t.TempDir(), "test.db"))
+
+	err := db.View(func(tx *kv.Tx) error {
+		_, err := tx.Get([]byte("a"))
+		assert.ErrorIs(t, err, kv.ErrKeyNotFound)
+		assert.ErrorIs(t, tx.Put([]byte("a"), nil), kv.ErrTxNotWritable)
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = db.Update(func(tx *kv.Tx) error {
+		assert.ErrorIs(t, tx.Put(nil, []byte("v")), kv.ErrKeyRequired)
+		assert.ErrorIs(t, tx.Put(make([]byte, kv.MaxKeySize+1), []byte("v")), kv.ErrKeyTooLarge)
+		require.NoError(t, tx.Put([]byte("a"), []byte("1")))
+		require.NoError(t, tx.Put([]byte("b"), []byte("2")))
+		require.NoError(t, tx.Put([]byte("a"), []byte("3")))
+		require.NoError(t, tx.Delete([]byte("b")))
+		require.NoError(t, tx.Delete([]byte("missing")))
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = db.Update(func(tx *kv.Tx) error {
+		require.NoError(t, tx.Put([]byte("c"), []byte("discarded")))
+		return fmt.Errorf("rollback")
+	})
+	assert.EqualError(t, err, "rollback")
+
+	err = db.View(func(tx *kv.Tx) error {
+		v, err := tx.Get([]byte("a"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("3"), v)
+		_, err = tx.Get([]byte("b"))
+		assert.ErrorIs(t, err, kv.ErrKeyNotFound)
+		_, err = tx.Get([]byte("c"))
+		assert.ErrorIs(t, err, kv.ErrKeyNotFound)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, db.Close())
+	assert.ErrorIs(t, db.View(func(tx *kv.Tx) error { return nil }), kv.ErrClosed)
+}
+
+// TestDBMatchesModel applies random operations to the database and to a map
+// and checks that they agree, across reopens.
+func TestDBMatchesModel(t *testing.T) {
+	p := path.Join(t.TempDir(), "test.db")
+	db := openDB(t, p)
+	model := map[string][]byte{}
+	rnd := rand.New(rand.NewSource(1))
+
+	randomValue := func() []byte {
+		size := rnd.Intn(64)
+		if rnd.Intn(10) == 0 {
+			size = rnd.Intn(3 * 4096) // overflow pages
+		}
+		v := make([]byte, size)
+		rnd.Read(v)
+		return v
+	}
+
+	for round := range 20 {
+		err := db.Update(func(tx *kv.Tx) error {
+			for range 500 {
+				k := fmt.Sprintf("key-%05d-%s", rnd.Intn(3000), bytes.Repeat([]byte("k"), rnd.Intn(200)))
+				if rnd.Intn(3) == 0 {
+					delete(model, k)
+					if err := tx.Delete([]byte(k)); err != nil {
+						return err
+					}
+					continue
+				}
+				v := randomValue()
+				model[k] = v
+				if err := tx.Put([]byte(k), v); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		if round%5 == 4 {
+			require.NoError(t, db.Close())
+			db = openDB(t, p)
+		}
+		assertMatchesModel(t, db, model)
+	}
+
+	// deleting everything leaves an empty tree
+	err := db.Update(func(tx *kv.Tx) error {
+		for k := range model {
+			if err := tx.Delete([]byte(k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assertMatchesModel(t, db, map[string][]byte{})
+}
+
+func assertMatchesModel(t *testing.T, db *kv.DB, model map[string][]byte) {
+	t.Helper()
+	keys := make([]string, 0, len(model))
+	for k := range model {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	err := db.View(func(tx *kv.Tx) error {
+		for _, k := range keys {
+			v, err := tx.Get([]byte(k))
+			if !assert.NoError(t, err, k) || !assert.Equal(t, model[k], v, k) {
+				return nil
+			}
+		}
+		got := []string{}
+		err := tx.Ascend(nil, nil, func(key, value []byte) (bool, error) {
+			got = append(got, string(key))
+			return true, nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, keys, got)
+
+		got = []string{}
+		err = tx.Descend(nil, nil, func(key, value []byte) (bool, error) {
+			got = append(got, string(key))
+			return true, nil
+		})
+		require.NoError(t, err)
+		slices.Reverse(got)
+		assert.Equal(t, keys, got)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestDBRangeScans(t *testing.T) {
+	db := openDB(t, path.Join(t.TempDir(), "test.db"))
+	err := db.Update(func(tx *kv.Tx) error {
+		for i := range 2000 {
+			if err := tx.Put(fmt.Appendf(nil, "%05d", i*2), fmt.Appendf(nil, "%d", i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	collect := func(scan func(lo, hi []byte, fn func(key, value []byte) (bool, error)) error, lo, hi string, limit int) []string {
+		var l, h []byte
+		if lo != "" {
+			l = []byte(lo)
+		}
+		if hi != "" {
+			h = []byte(hi)
+		}
+		got := []string{}
+		require.NoError(t, scan(l, h, func(key, value []byte) (bool, error) {
+			got = append(got, string(key))
+			return len(got) < limit, nil
+		}))
+		return got
+	}
+
+	err = db.View(func(tx *kv.Tx) error {
+		assert.Equal(t, []string{"01000", "01002", "01004"}, collect(tx.Ascend, "01000", "01006", 100))
+		assert.Equal(t, []string{"01002", "01004"}, collect(tx.Ascend, "01001", "01005", 100))
+		assert.Equal(t, []string{"00000", "00002"}, collect(tx.Ascend, "", "", 2))
+		assert.Equal(t, []string{"03996", "03998"}, collect(tx.Ascend, "03995", "", 100))
+		assert.Equal(t, []string{}, collect(tx.Ascend, "03999", "", 100))
+
+		assert.Equal(t, []string{"01004", "01002", "01000"}, collect(tx.Descend, "01000", "01006", 100))
+		assert.Equal(t, []string{"01004", "01002"}, collect(tx.Descend, "01001", "01005", 100))
+		assert.Equal(t, []string{"03998", "03996"}, collect(tx.Descend, "", "", 2))
+		assert.Equal(t, []string{"00002", "00000"}, collect(tx.Descend, "", "00003", 100))
+		assert.Equal(t, []string{}, collect(tx.Descend, "", "00000", 100))
+
+		errStop := fmt.Errorf("stop")
+		assert.Equal(t, errStop, tx.Ascend(nil, nil, func(key, value []byte) (bool, error) {
+			return true, errStop
+		}))
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestDBReusesFreePages(t *testing.T) {
+	p := path.Join(t.TempDir(), "test.db")
+	db := openDB(t, p)
+	value := make([]byte, 2*4096)
+	write := func() {
+		err := db.Update(func(tx *kv.Tx) error {
+			for i := range 200 {
+				if err := tx.Put(fmt.Appendf(nil, "key-%d", i), value); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+	}
+	// pages freed by a commit are only reused by the next ones, the file
+	// settles once it holds two copies of the data
+	for range 3 {
+		write()
+	}
+	info, err := os.Stat(p)
+	require.NoError(t, err)
+	for range 10 {
+		write()
+	}
+	after, err := os.Stat(p)
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), after.Size(), "rewriting the same data should not grow the file")
+}
+
+func TestDBRecoversFromTornMetaPage(t *testing.T) {
+	p := path.Join(t.TempDir(), "test.db")
+	db := openDB(t, p)
+	put := func(v string) {
+		require.NoError(t, db.Update(func(tx *kv.Tx) error {
+			return tx.Put([]byte("key"), []byte(v))
+		}))
+	}
+	put("first")  // first commit, written to meta page 1
+	put("second") // second commit, written to meta page 0
+	require.NoError(t, db.Close())
+
+	// tear the latest meta page as an interrupted write would
+	f, err := os.OpenFile(p, os.O_RDWR, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte("garbage"), 20)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	db = openDB(t, p)
+	err = db.View(func(tx *kv.Tx) error {
+		v, err := tx.Get([]byte("key"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("first"), v)
+		return nil
+	})
+	require.NoError(t, err)
+	put("third")
+	err = db.View(func(tx *kv.Tx) error {
+		v, err := tx.Get([]byte("key"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("third"), v)
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,15 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package kv
+
+import "os"
+
+// lockFile does nothing where flock is not available, the database file must
+// then only be opened by a single DB at a time.
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package kv_test
+
+import (
+	"path"
+	"testing"
+
+	"github.com/slawo/go-cache/datastore/kv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenLocksFile(t *testing.T) {
+	p := path.Join(t.TempDir(), "test.db")
+	db, err := kv.Open(p)
+	require.NoError(t, err)
+
+	other, err := kv.Open(p)
+	assert.ErrorIs(t, err, kv.ErrLocked)
+	assert.Nil(t, other)
+
+	require.NoError(t, db.Close())
+	db, err = kv.Open(p)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+}
@@ -0,0 +1,483 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/slawo/go-cache/datastore"
+)
+
+// Key prefixes of the records held by a MetaDataStore.
+const (
+	prefixMeta       byte = 'm' // m<file id> -> FileMeta
+	prefixCompletion byte = 'c' // c<file id> -> completionHeader
+	prefixParts      byte = 'p' // p<id length><file id><chunk> -> bitmap chunk
+	prefixAccess     byte = 'a' // a<last access><file id> -> empty
+	prefixSize       byte = 's' // s<size><file id> -> empty
+	prefixExpiry     byte = 'e' // e<expiry><file id> -> empty
+)
+
+const (
+	// partsChunkSize is the size of the bitmap chunks, small enough to be
+	// stored inline in a leaf.
+	partsChunkSize = maxInlineValue
+	// maxFileIdSize leaves room for the prefixes and suffixes of the keys.
+	maxFileIdSize = MaxKeySize - 16
+	// scanBatchSize is the number of entries read per transaction by scans.
+	scanBatchSize = 256
+)
+
+// NewMetaDataStore opens the metadata store held in the database file at
+// dbPath, creating it if it does not exist.
+func NewMetaDataStore(ctx context.Context, dbPath string) (*MetaDataStore, error) {
+	if dbPath == "" {
+		return nil, errors.New("kv meta data store: missing path")
+	}
+	db, err := Open(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("kv meta data store: %w", err)
+	}
+	return &MetaDataStore{db: db}, nil
+}
+
+// MetaDataStore is a datastore.MetaDataStore backed by a single DB file.
+//
+// Besides the records, the store maintains indexes of the files by last
+// access time, size and expiry time so that eviction and garbage collection
+// can range-scan them without loading every record. Completion bitmaps are
+// split in chunks so that marking a part only rewrites a small record.
+type MetaDataStore struct {
+	db *DB
+}
+
+// completionHeader is the completion data without its bitmap.
+type completionHeader struct {
+	FileId         string    `json:"fileId"`
+	PartSize       int64     `json:"partSize"`
+	TotalParts     int       `json:"totalParts"`
+	PartsCompleted int       `json:"partsCompleted"`
+	PartsLength    int       `json:"partsLength"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// Close closes the database file.
+func (s *MetaDataStore) Close() error {
+	return s.db.Close()
+}
+
+// GetFileMeta retrieves metadata for a file by its ID.
+func (s *MetaDataStore) GetFileMeta(ctx context.Context, fileId string) (*datastore.FileMeta, error) {
+	if err := checkFileId(fileId); err != nil {
+		return nil, err
+	}
+	var meta *datastore.FileMeta
+	err := s.view(func(tx *Tx) error {
+		m, err := getMeta(tx, fileId)
+		meta = m
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// SaveFileMeta saves metadata for a file.
+func (s *MetaDataStore) SaveFileMeta(ctx context.Context, fileMeta *datastore.FileMeta) error {
+	if fileMeta == nil {
+		return errors.New("file metadata cannot be nil")
+	} else if err := checkFileId(fileMeta.FileId); err != nil {
+		return err
+	}
+	b, err := json.Marshal(fileMeta)
+	if err != nil {
+		return fmt.Errorf("kv meta data store: unable to encode metadata: %w", err)
+	}
+	return s.update(func(tx *Tx) error {
+		if err := deleteMeta(tx, fileMeta.FileId); err != nil {
+			return err
+		}
+		if err := tx.Put(fileKey(prefixMeta, fileMeta.FileId), b); err != nil {
+			return err
+		}
+		for _, k := range indexKeys(fileMeta) {
+			if err := tx.Put(k, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteFileMeta removes the metadata and completion data of a file.
+func (s *MetaDataStore) DeleteFileMeta(ctx context.Context, fileId string) error {
+	if err := checkFileId(fileId); err != nil {
+		return err
+	}
+	return s.update(func(tx *Tx) error {
+		if err := deleteMeta(tx, fileId); err != nil {
+			return err
+		}
+		return deleteCompletion(tx, fileId)
+	})
+}
+
+// GetFileCompletionData retrieves completion data for a file by its ID.
+func (s *MetaDataStore) GetFileCompletionData(ctx context.Context, fileId string) (*datastore.FileCompletionData, error) {
+	if err := checkFileId(fileId); err != nil {
+		return nil, err
+	}
+	var c *datastore.FileCompletionData
+	err := s.view(func(tx *Tx) error {
+		h, err := getCompletionHeader(tx, fileId)
+		if err != nil {
+			return err
+		}
+		c, err = getCompletion(tx, h)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SaveFileCompletionData saves completion data for a file.
+func (s *MetaDataStore) SaveFileCompletionData(ctx context.Context, completionData *datastore.FileCompletionData) error {
+	if completionData == nil {
+		return errors.New("completion data cannot be nil")
+	} else if err := checkFileId(completionData.FileId); err != nil {
+		return err
+	}
+	c := completionData
+	return s.update(func(tx *Tx) error {
+		if err := deleteCompletion(tx, c.FileId); err != nil {
+			return err
+		}
+		h := &completionHeader{
+			FileId:         c.FileId,
+			PartSize:       c.PartSize,
+			TotalParts:     c.TotalParts,
+			PartsCompleted: c.PartsCompleted,
+			PartsLength:    len(c.Parts),
+			UpdatedAt:      c.UpdatedAt,
+		}
+		if err := putCompletionHeader(tx, h); err != nil {
+			return err
+		}
+		for i := 0; i < len(c.Parts); i += partsChunkSize {
+			chunk := c.Parts[i:min(i+partsChunkSize, len(c.Parts))]
+			if bytes.Count(chunk, []byte{0}) == len(chunk) {
+				continue // missing chunks read as zeroes
+			}
+			if err := tx.Put(partsKey(c.FileId, i/partsChunkSize), chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MarkPartCompleted sets a part in the completion data of a file, only the
+// bitmap chunk holding the part is rewritten.
+func (s *MetaDataStore) MarkPartCompleted(ctx context.Context, fileId string, part int) (*datastore.FileCompletionData, error) {
+	if err := checkFileId(fileId); err != nil {
+		return nil, err
+	}
+	var c *datastore.FileCompletionData
+	err := s.update(func(tx *Tx) error {
+		h, err := getCompletionHeader(tx, fileId)
+		if err != nil {
+			return err
+		}
+		if !datastore.ValidPart(h.TotalParts, part) {
+			return fmt.Errorf("%w: %d", datastore.ErrInvalidPart, part)
+		}
+		index, offset := part/8/partsChunkSize, part/8%partsChunkSize
+		key := partsKey(fileId, index)
+		chunk, err := tx.Get(key)
+		if err != nil && !errors.Is(err, ErrKeyNotFound) {
+			return err
+		}
+		if offset < len(chunk) && chunk[offset]&(0x80>>(part%8)) != 0 {
+			c, err = getCompletion(tx, h)
+			return err
+		}
+		updated := make([]byte, max(len(chunk), offset+1))
+		copy(updated, chunk)
+		updated[offset] |= 0x80 >> (part % 8)
+		if err := tx.Put(key, updated); err != nil {
+			return err
+		}
+		h.PartsCompleted++
+		h.PartsLength = max(h.PartsLength, part/8+1)
+		h.UpdatedAt = time.Now()
+		if err := putCompletionHeader(tx, h); err != nil {
+			return err
+		}
+		c, err = getCompletion(tx, h)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ScanByLastAccess calls fn with the metadata of the files last accessed
+// before the given time, least recently accessed first, until fn returns
+// false. Files which were never accessed come first. A zero time scans every
+// file.
+//
+// Scans read the index in batches and do not hold the database between
+// batches, fn can modify the store.
+func (s *MetaDataStore) ScanByLastAccess(ctx context.Context, before time.Time, fn func(*datastore.FileMeta) bool) error {
+	hi := []byte{prefixAccess + 1}
+	if !before.IsZero() {
+		hi = indexKey(prefixAccess, timeIndex(before), "")
+	}
+	return s.scan(ctx, []byte{prefixAccess}, hi, false, fn)
+}
+
+// ScanBySize calls fn with the metadata of the files at least minSize bytes
+// large, largest first, until fn returns false.
+func (s *MetaDataStore) ScanBySize(ctx context.Context, minSize int64, fn func(*datastore.FileMeta) bool) error {
+	return s.scan(ctx, indexKey(prefixSize, minSize, ""), []byte{prefixSize + 1}, true, fn)
+}
+
+// ScanByExpiry calls fn with the metadata of the files expiring before the
+// given time, soonest first, until fn returns false. A zero time scans every
+// file with an expiry time, files which do not expire are not indexed.
+func (s *MetaDataStore) ScanByExpiry(ctx context.Context, before time.Time, fn func(*datastore.FileMeta) bool) error {
+	hi := []byte{prefixExpiry + 1}
+	if !before.IsZero() {
+		hi = indexKey(prefixExpiry, timeIndex(before), "")
+	}
+	return s.scan(ctx, []byte{prefixExpiry}, hi, false, fn)
+}
+
+func (s *MetaDataStore) scan(ctx context.Context, lo, hi []byte, desc bool, fn func(*datastore.FileMeta) bool) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("kv meta data store: %w", err)
+		}
+		var (
+			batch []*datastore.FileMeta
+			last  []byte
+		)
+		err := s.view(func(tx *Tx) error {
+			visit := func(key, _ []byte) (bool, error) {
+				meta, err := getMeta(tx, string(key[9:]))
+				if err != nil {
+					return false, err
+				}
+				batch = append(batch, meta)
+				last = bytes.Clone(key)
+				return len(batch) < scanBatchSize, nil
+			}
+			if desc {
+				return tx.Descend(lo, hi, visit)
+			}
+			return tx.Ascend(lo, hi, visit)
+		})
+		if err != nil {
+			return err
+		}
+		for _, meta := range batch {
+			if !fn(meta) {
+				return nil
+			}
+		}
+		if len(batch) < scanBatchSize {
+			return nil
+		}
+		if desc {
+			hi = last
+		} else {
+			lo = append(last, 0)
+		}
+	}
+}
+
+// view runs fn in a read-only transaction.
+func (s *MetaDataStore) view(fn func(tx *Tx) error) error {
+	return wrapError(s.db.View(fn))
+}
+
+// update runs fn in a read-write transaction.
+func (s *MetaDataStore) update(fn func(tx *Tx) error) error {
+	return wrapError(s.db.Update(fn))
+}
+
+// wrapError prefixes the errors which are not reported to the caller as
+// is.
+func wrapError(err error) error {
+	if err == nil || errors.Is(err, datastore.ErrFileNotFound) || errors.Is(err, datastore.ErrInvalidPart) {
+		return err
+	}
+	return fmt.Errorf("kv meta data store: %w", err)
+}
+
+func checkFileId(fileId string) error {
+	if strings.TrimSpace(fileId) == "" {
+		return fmt.Errorf("%w: empty file ID", datastore.ErrInvalidFileID)
+	}
+	if len(fileId) > maxFileIdSize {
+		return fmt.Errorf("%w: file ID longer than %d bytes", datastore.ErrInvalidFileID, maxFileIdSize)
+	}
+	return nil
+}
+
+func getMeta(tx *Tx, fileId string) (*datastore.FileMeta, error) {
+	b, err := tx.Get(fileKey(prefixMeta, fileId))
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, fmt.Errorf("%w: %s", datastore.ErrFileNotFound, fileId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	meta := &datastore.FileMeta{}
+	if err := json.Unmarshal(b, meta); err != nil {
+		return nil, fmt.Errorf("unable to decode metadata: %w", err)
+	}
+	return meta, nil
+}
+
+// deleteMeta removes the metadata of a file and its index entries.
+func deleteMeta(tx *Tx, fileId string) error {
+	meta, err := getMeta(tx, fileId)
+	if errors.Is(err, datastore.ErrFileNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, k := range indexKeys(meta) {
+		if err := tx.Delete(k); err != nil {
+			return err
+		}
+	}
+	return tx.Delete(fileKey(prefixMeta, fileId))
+}
+
+func getCompletionHeader(tx *Tx, fileId string) (*completionHeader, error) {
+	b, err := tx.Get(fileKey(prefixCompletion, fileId))
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, fmt.Errorf("%w: %s", datastore.ErrFileNotFound, fileId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	h := &completionHeader{}
+	if err := json.Unmarshal(b, h); err != nil {
+		return nil, fmt.Errorf("unable to decode completion data: %w", err)
+	}
+	return h, nil
+}
+
+func putCompletionHeader(tx *Tx, h *completionHeader) error {
+	b, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("unable to encode completion data: %w", err)
+	}
+	return tx.Put(fileKey(prefixCompletion, h.FileId), b)
+}
+
+// getCompletion assembles the completion data from its header and chunks.
+func getCompletion(tx *Tx, h *completionHeader) (*datastore.FileCompletionData, error) {
+	c := &datastore.FileCompletionData{
+		FileId:         h.FileId,
+		PartSize:       h.PartSize,
+		TotalParts:     h.TotalParts,
+		PartsCompleted: h.PartsCompleted,
+		UpdatedAt:      h.UpdatedAt,
+	}
+	if h.PartsLength > 0 {
+		c.Parts = make([]byte, h.PartsLength)
+	}
+	prefix := partsPrefix(h.FileId)
+	err := tx.Ascend(prefix, nil, func(key, chunk []byte) (bool, error) {
+		if !bytes.HasPrefix(key, prefix) {
+			return false, nil
+		}
+		offset := int(binary.BigEndian.Uint32(key[len(prefix):])) * partsChunkSize
+		if offset < len(c.Parts) {
+			copy(c.Parts[offset:], chunk)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// deleteCompletion removes the completion data of a file and its chunks.
+func deleteCompletion(tx *Tx, fileId string) error {
+	h, err := getCompletionHeader(tx, fileId)
+	if errors.Is(err, datastore.ErrFileNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for i := 0; i < h.PartsLength; i += partsChunkSize {
+		if err := tx.Delete(partsKey(fileId, i/partsChunkSize)); err != nil {
+			return err
+		}
+	}
+	return tx.Delete(fileKey(prefixCompletion, fileId))
+}
+
+func fileKey(prefix byte, fileId string) []byte {
+	return append([]byte{prefix}, fileId...)
+}
+
+// partsPrefix returns the prefix of the bitmap chunks of a file. The ID is
+// length prefixed so that the chunks of a file are not mixed with the ones
+// of the files whose ID it prefixes.
+func partsPrefix(fileId string) []byte {
+	k := make([]byte, 0, len(fileId)+7)
+	k = append(k, prefixParts)
+	k = binary.BigEndian.AppendUint16(k, uint16(len(fileId)))
+	return append(k, fileId...)
+}
+
+func partsKey(fileId string, chunk int) []byte {
+	return binary.BigEndian.AppendUint32(partsPrefix(fileId), uint32(chunk))
+}
+
+// indexKey returns the key of an index entry, the value is encoded so that
+// the keys sort in the order of the signed values.
+func indexKey(prefix byte, v int64, fileId string) []byte {
+	k := make([]byte, 0, len(fileId)+9)
+	k = append(k, prefix)
+	k = binary.BigEndian.AppendUint64(k, uint64(v)^(1<<63))
+	return append(k, fileId...)
+}
+
+func indexKeys(meta *datastore.FileMeta) [][]byte {
+	keys := [][]byte{
+		indexKey(prefixAccess, timeIndex(meta.LastAccessedAt), meta.FileId),
+		indexKey(prefixSize, meta.FileSize, meta.FileId),
+	}
+	if !meta.ExpiresAt.IsZero() {
+		keys = append(keys, indexKey(prefixExpiry, timeIndex(meta.ExpiresAt), meta.FileId))
+	}
+	return keys
+}
+
+// timeIndex returns the index value of a time, the zero time sorts first.
+func timeIndex(t time.Time) int64 {
+	if t.IsZero() {
+		return math.MinInt64
+	}
+	return t.UnixNano()
+}
@@ -0,0 +1,149 @@
+package kv_test
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slawo/go-cache/datastore"
+	"github.com/slawo/go-cache/datastore/kv"
+	"github.com/slawo/go-cache/datastore/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMetaDataStore(t *testing.T, p string) *kv.MetaDataStore {
+	t.Helper()
+	store, err := kv.NewMetaDataStore(t.Context(), p)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestKVMetaDataStoreTests(t *testing.T) {
+	tests.RunMetaDataStoreTests(t, tests.MetaDataStoreTestsOpts{
+		NewMetaDataStore: func(ctx context.Context, t *testing.T) (datastore.MetaDataStore, error) {
+			return newMetaDataStore(t, path.Join(t.TempDir(), "meta.db")), nil
+		},
+	})
+}
+
+func TestKVMetaDataStoreRejectsLongFileIds(t *testing.T) {
+	store := newMetaDataStore(t, path.Join(t.TempDir(), "meta.db"))
+	err := store.SaveFileMeta(t.Context(), &datastore.FileMeta{FileId: strings.Repeat("a", kv.MaxKeySize)})
+	assert.ErrorIs(t, err, datastore.ErrInvalidFileID)
+}
+
+func TestKVMetaDataStorePersists(t *testing.T) {
+	p := path.Join(t.TempDir(), "meta.db")
+	store := newMetaDataStore(t, p)
+	meta := &datastore.FileMeta{
+		FileId:    "file.bin",
+		FileSize:  1 << 40,
+		SourceURI: "https://example.com/file.bin?" + strings.Repeat("signature", 200),
+		CreatedAt: time.Now(),
+	}
+	require.NoError(t, store.SaveFileMeta(t.Context(), meta))
+	completion := &datastore.FileCompletionData{FileId: "file.bin", PartSize: 1 << 20, TotalParts: 1 << 20}
+	require.NoError(t, store.SaveFileCompletionData(t.Context(), completion))
+	for _, part := range []int{0, 7, 4096, 1<<20 - 1} {
+		var err error
+		completion, err = store.MarkPartCompleted(t.Context(), "file.bin", part)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 4, completion.PartsCompleted)
+	assert.Len(t, completion.Parts, 1<<17)
+	require.NoError(t, store.Close())
+
+	store = newMetaDataStore(t, p)
+	got, err := store.GetFileMeta(t.Context(), "file.bin")
+	require.NoError(t, err)
+	tests.AssertFileMetaEqual(t, meta, got)
+	gotCompletion, err := store.GetFileCompletionData(t.Context(), "file.bin")
+	require.NoError(t, err)
+	tests.AssertCompletionDataEqual(t, completion, gotCompletion)
+}
+
+func TestKVMetaDataStoreScans(t *testing.T) {
+	store := newMetaDataStore(t, path.Join(t.TempDir(), "meta.db"))
+	now := time.Now()
+	// more files than read per scan batch
+	const count = 600
+	for i := range count {
+		meta := &datastore.FileMeta{
+			FileId:         fmt.Sprintf("file-%03d", i),
+			FileSize:       int64((i * 37) % count),
+			LastAccessedAt: now.Add(-time.Duration(count-i) * time.Minute),
+		}
+		if i%2 == 0 {
+			meta.ExpiresAt = now.Add(time.Duration(i) * time.Second)
+		}
+		require.NoError(t, store.SaveFileMeta(t.Context(), meta))
+	}
+	// updating a file moves it in the indexes
+	moved := &datastore.FileMeta{FileId: "file-000", FileSize: 5, LastAccessedAt: now}
+	require.NoError(t, store.SaveFileMeta(t.Context(), moved))
+
+	var accessed []string
+	err := store.ScanByLastAccess(t.Context(), time.Time{}, func(m *datastore.FileMeta) bool {
+		accessed = append(accessed, m.FileId)
+		return true
+	})
+	require.NoError(t, err)
+	require.Len(t, accessed, count)
+	assert.Equal(t, "file-001", accessed[0])
+	assert.Equal(t, "file-599", accessed[count-2])
+	assert.Equal(t, "file-000", accessed[count-1])
+
+	accessed = nil
+	err = store.ScanByLastAccess(t.Context(), now.Add(-time.Duration(count-10)*time.Minute), func(m *datastore.FileMeta) bool {
+		accessed = append(accessed, m.FileId)
+		return true
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"file-001", "file-002", "file-003", "file-004", "file-005", "file-006", "file-007", "file-008", "file-009"}, accessed)
+
+	var sizes []int64
+	err = store.ScanBySize(t.Context(), count-300, func(m *datastore.FileMeta) bool {
+		sizes = append(sizes, m.FileSize)
+		return true
+	})
+	require.NoError(t, err)
+	require.Len(t, sizes, 300)
+	for i, size := range sizes {
+		assert.Equal(t, int64(count-1-i), size)
+	}
+
+	var expiring []string
+	err = store.ScanByExpiry(t.Context(), now.Add(10*time.Second), func(m *datastore.FileMeta) bool {
+		expiring = append(expiring, m.FileId)
+		return true
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"file-002", "file-004", "file-006", "file-008"}, expiring, "file-000 no longer expires")
+
+	// scans can delete the files they visit
+	evicted := 0
+	err = store.ScanByLastAccess(t.Context(), time.Time{}, func(m *datastore.FileMeta) bool {
+		require.NoError(t, store.DeleteFileMeta(t.Context(), m.FileId))
+		evicted++
+		return evicted < 400
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 400, evicted)
+	remaining := 0
+	err = store.ScanBySize(t.Context(), 0, func(m *datastore.FileMeta) bool {
+		remaining++
+		return true
+	})
+	require.NoError(t, err)
+	assert.Equal(t, count-400, remaining)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	err = store.ScanBySize(ctx, 0, func(m *datastore.FileMeta) bool { return true })
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,233 @@
+package kv
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+const (
+	pageSize       = 4096
+	pageHeaderSize = 16
+
+	// MaxKeySize is the maximum size of a key in bytes.
+	MaxKeySize = 1024
+	// Values larger than maxInlineValue are stored in a chain of overflow
+	// pages instead of in their leaf, so that a page always fits a few
+	// entries.
+	maxInlineValue = 512
+	// Nodes smaller than minFill are merged with a sibling on delete.
+	minFill = pageSize / 4
+
+	leafEntryHeader   = 7
+	branchEntryHeader = 10
+	overflowCapacity  = pageSize - pageHeaderSize
+	freelistCapacity  = (pageSize - pageHeaderSize) / 8
+
+	metaMagic   = 0x67636b76 // "gckv"
+	metaVersion = 1
+	metaSize    = 56
+)
+
+// pgid is the index of a page in the database file. Pages 0 and 1 hold the
+// meta pages so 0 is used as a nil page ID.
+type pgid uint64
+
+// Page types, stored in the first byte of every page.
+const (
+	pageMeta uint8 = iota + 1
+	pageBranch
+	pageLeaf
+	pageFreelist
+	pageOverflow
+)
+
+// Every page starts with a 16 bytes header:
+//
+//	[0]     page type
+//	[4:8]   element count, or byte count for overflow pages
+//	[8:16]  next page of a freelist or overflow chain
+//
+// Leaf elements are encoded as flags(1) key length(2) value length(4) key
+// value, where the value is replaced by the ID of the first overflow page
+// when the overflow flag is set. Branch elements are encoded as key
+// length(2) child page ID(8) key.
+const leafFlagOverflow uint8 = 1
+
+func putHeader(buf []byte, typ uint8, count uint32, next pgid) {
+	buf[0] = typ
+	binary.LittleEndian.PutUint32(buf[4:], count)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(next))
+}
+
+func readHeader(buf []byte) (typ uint8, count uint32, next pgid) {
+	return buf[0], binary.LittleEndian.Uint32(buf[4:]), pgid(binary.LittleEndian.Uint64(buf[8:]))
+}
+
+// meta is the root of the database, two copies are kept in pages 0 and 1
+// and the valid one with the highest txid wins on open.
+type meta struct {
+	root      pgid
+	freelist  pgid
+	pageCount pgid
+	txid      uint64
+}
+
+func (m *meta) write(buf []byte) {
+	buf[0] = pageMeta
+	binary.LittleEndian.PutUint32(buf[4:], metaMagic)
+	binary.LittleEndian.PutUint32(buf[8:], metaVersion)
+	binary.LittleEndian.PutUint32(buf[12:], pageSize)
+	binary.LittleEndian.PutUint64(buf[16:], uint64(m.root))
+	binary.LittleEndian.PutUint64(buf[24:], uint64(m.freelist))
+	binary.LittleEndian.PutUint64(buf[32:], uint64(m.pageCount))
+	binary.LittleEndian.PutUint64(buf[40:], m.txid)
+	binary.LittleEndian.PutUint32(buf[48:], crc32.ChecksumIEEE(buf[:48]))
+}
+
+func readMeta(buf []byte) (meta, error) {
+	switch {
+	case buf[0] != pageMeta || binary.LittleEndian.Uint32(buf[4:]) != metaMagic:
+		return meta{}, fmt.Errorf("not a meta page")
+	case binary.LittleEndian.Uint32(buf[48:]) != crc32.ChecksumIEEE(buf[:48]):
+		return meta{}, fmt.Errorf("meta page checksum mismatch")
+	case binary.LittleEndian.Uint32(buf[8:]) != metaVersion:
+		return meta{}, fmt.Errorf("unsupported version %d", binary.LittleEndian.Uint32(buf[8:]))
+	case binary.LittleEndian.Uint32(buf[12:]) != pageSize:
+		return meta{}, fmt.Errorf("unsupported page size %d", binary.LittleEndian.Uint32(buf[12:]))
+	}
+	return meta{
+		root:      pgid(binary.LittleEndian.Uint64(buf[16:])),
+		freelist:  pgid(binary.LittleEndian.Uint64(buf[24:])),
+		pageCount: pgid(binary.LittleEndian.Uint64(buf[32:])),
+		txid:      binary.LittleEndian.Uint64(buf[40:]),
+	}, nil
+}
+
+// value is a leaf value, either held inline or in an overflow chain.
+type value struct {
+	data     []byte
+	overflow pgid
+	size     int
+}
+
+// node is a decoded branch or leaf page. Nodes read from the file are
+// shared through the node cache and must be owned before being modified.
+type node struct {
+	pgid  pgid // page the node was read from, 0 for new nodes
+	leaf  bool
+	dirty bool
+	keys  [][]byte
+	vals  []value // leaf only
+	ids   []pgid  // branch only
+	kids  []*node // children loaded by a writable transaction, branch only
+}
+
+func (n *node) entrySize(i int) int {
+	if !n.leaf {
+		return branchEntryHeader + len(n.keys[i])
+	}
+	if n.vals[i].overflow != 0 {
+		return leafEntryHeader + len(n.keys[i]) + 8
+	}
+	return leafEntryHeader + len(n.keys[i]) + len(n.vals[i].data)
+}
+
+func (n *node) size() int {
+	s := pageHeaderSize
+	for i := range n.keys {
+		s += n.entrySize(i)
+	}
+	return s
+}
+
+func (n *node) encode(buf []byte) {
+	typ := pageBranch
+	if n.leaf {
+		typ = pageLeaf
+	}
+	putHeader(buf, typ, uint32(len(n.keys)), 0)
+	off := pageHeaderSize
+	for i, k := range n.keys {
+		if !n.leaf {
+			binary.LittleEndian.PutUint16(buf[off:], uint16(len(k)))
+			binary.LittleEndian.PutUint64(buf[off+2:], uint64(n.ids[i]))
+			off += branchEntryHeader
+			off += copy(buf[off:], k)
+			continue
+		}
+		v := n.vals[i]
+		buf[off] = 0
+		if v.overflow != 0 {
+			buf[off] = leafFlagOverflow
+		}
+		binary.LittleEndian.PutUint16(buf[off+1:], uint16(len(k)))
+		binary.LittleEndian.PutUint32(buf[off+3:], uint32(v.size))
+		off += leafEntryHeader
+		off += copy(buf[off:], k)
+		if v.overflow != 0 {
+			binary.LittleEndian.PutUint64(buf[off:], uint64(v.overflow))
+			off += 8
+		} else {
+			off += copy(buf[off:], v.data)
+		}
+	}
+}
+
+// decodeNode decodes a branch or leaf page, the node references buf.
+func decodeNode(id pgid, buf []byte) (*node, error) {
+	typ, count, _ := readHeader(buf)
+	if typ != pageBranch && typ != pageLeaf {
+		return nil, fmt.Errorf("kv: page %d is not a tree page", id)
+	}
+	n := &node{
+		pgid: id,
+		leaf: typ == pageLeaf,
+		keys: make([][]byte, count),
+	}
+	if n.leaf {
+		n.vals = make([]value, count)
+	} else {
+		n.ids = make([]pgid, count)
+	}
+	off := pageHeaderSize
+	for i := range n.keys {
+		if n.leaf {
+			if off+leafEntryHeader > len(buf) {
+				return nil, fmt.Errorf("kv: page %d is corrupted", id)
+			}
+			flags := buf[off]
+			klen := int(binary.LittleEndian.Uint16(buf[off+1:]))
+			vlen := int(binary.LittleEndian.Uint32(buf[off+3:]))
+			off += leafEntryHeader
+			inline := vlen
+			if flags&leafFlagOverflow != 0 {
+				inline = 8
+			}
+			if off+klen+inline > len(buf) {
+				return nil, fmt.Errorf("kv: page %d is corrupted", id)
+			}
+			n.keys[i] = buf[off : off+klen : off+klen]
+			off += klen
+			if flags&leafFlagOverflow != 0 {
+				n.vals[i] = value{overflow: pgid(binary.LittleEndian.Uint64(buf[off:])), size: vlen}
+			} else {
+				n.vals[i] = value{data: buf[off : off+vlen : off+vlen], size: vlen}
+			}
+			off += inline
+			continue
+		}
+		if off+branchEntryHeader > len(buf) {
+			return nil, fmt.Errorf("kv: page %d is corrupted", id)
+		}
+		klen := int(binary.LittleEndian.Uint16(buf[off:]))
+		n.ids[i] = pgid(binary.LittleEndian.Uint64(buf[off+2:]))
+		off += branchEntryHeader
+		if off+klen > len(buf) {
+			return nil, fmt.Errorf("kv: page %d is corrupted", id)
+		}
+		n.keys[i] = buf[off : off+klen : off+klen]
+		off += klen
+	}
+	return n, nil
+}
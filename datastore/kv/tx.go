@@ -0,0 +1,599 @@
+package kv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+// Tx is a transaction on a DB, it is only valid in the function given to
+// View or Update.
+//
+// Keys and values returned by a transaction must not be modified and must
+// not be used after the transaction ends. A transaction must not be
+// modified from the callback of Ascend or Descend.
+type Tx struct {
+	db        *DB
+	writable  bool
+	changed   bool
+	root      *node
+	free      []pgid // pages this transaction can write to
+	pending   []pgid // pages released by this transaction
+	pageCount pgid
+}
+
+// Get returns the value of the given key or ErrKeyNotFound.
+func (tx *Tx) Get(key []byte) ([]byte, error) {
+	n := tx.root
+	if n == nil {
+		return nil, ErrKeyNotFound
+	}
+	for !n.leaf {
+		kid, err := tx.child(n, branchIndex(n.keys, key))
+		if err != nil {
+			return nil, err
+		}
+		n = kid
+	}
+	i, found := slices.BinarySearchFunc(n.keys, key, bytes.Compare)
+	if !found {
+		return nil, ErrKeyNotFound
+	}
+	return tx.value(n.vals[i])
+}
+
+// Put creates or replaces the value of the given key.
+func (tx *Tx) Put(key, val []byte) error {
+	if err := tx.checkWrite(key); err != nil {
+		return err
+	}
+	v := value{data: slices.Clone(val), size: len(val)}
+	if len(val) > maxInlineValue {
+		id, err := tx.writeOverflow(val)
+		if err != nil {
+			return err
+		}
+		v = value{overflow: id, size: len(val)}
+	}
+	if tx.root == nil {
+		tx.root = &node{leaf: true, dirty: true}
+	}
+	parts, err := tx.insert(tx.root, slices.Clone(key), v)
+	if err != nil {
+		return err
+	}
+	tx.root = parts[0]
+	if len(parts) > 1 {
+		root := &node{dirty: true, kids: parts}
+		for _, p := range parts {
+			root.keys = append(root.keys, p.keys[0])
+			root.ids = append(root.ids, p.pgid)
+		}
+		tx.root = root
+	}
+	tx.changed = true
+	return nil
+}
+
+// Delete removes the given key, deleting a missing key is not an error.
+func (tx *Tx) Delete(key []byte) error {
+	if err := tx.checkWrite(key); err != nil {
+		return err
+	}
+	if tx.root == nil {
+		return nil
+	}
+	found, err := tx.remove(tx.root, key)
+	if err != nil || !found {
+		return err
+	}
+	tx.changed = true
+	for !tx.root.leaf && len(tx.root.ids) <= 1 {
+		tx.freePage(tx.root.pgid)
+		if len(tx.root.ids) == 0 {
+			tx.root = nil
+			return nil
+		}
+		root, err := tx.child(tx.root, 0)
+		if err != nil {
+			return err
+		}
+		tx.root = root
+	}
+	if len(tx.root.keys) == 0 {
+		tx.freePage(tx.root.pgid)
+		tx.root = nil
+	}
+	return nil
+}
+
+// Ascend calls fn with the keys in [lo, hi) in ascending order until fn
+// returns false or an error. A nil bound leaves the range open.
+func (tx *Tx) Ascend(lo, hi []byte, fn func(key, value []byte) (bool, error)) error {
+	if tx.root == nil {
+		return nil
+	}
+	_, err := tx.ascend(tx.root, lo, hi, fn)
+	return err
+}
+
+// Descend calls fn with the keys in [lo, hi) in descending order until fn
+// returns false or an error. A nil bound leaves the range open.
+func (tx *Tx) Descend(lo, hi []byte, fn func(key, value []byte) (bool, error)) error {
+	if tx.root == nil {
+		return nil
+	}
+	_, err := tx.descend(tx.root, lo, hi, fn)
+	return err
+}
+
+func (tx *Tx) ascend(n *node, lo, hi []byte, fn func(key, value []byte) (bool, error)) (bool, error) {
+	if n.leaf {
+		i := 0
+		if lo != nil {
+			i, _ = slices.BinarySearchFunc(n.keys, lo, bytes.Compare)
+		}
+		for ; i < len(n.keys); i++ {
+			if hi != nil && bytes.Compare(n.keys[i], hi) >= 0 {
+				return false, nil
+			}
+			v, err := tx.value(n.vals[i])
+			if err != nil {
+				return false, err
+			}
+			if more, err := fn(n.keys[i], v); err != nil || !more {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	i := 0
+	if lo != nil {
+		i = branchIndex(n.keys, lo)
+	}
+	for ; i < len(n.ids); i++ {
+		// keys[i] is a lower bound of the keys held by child i
+		if i > 0 && hi != nil && bytes.Compare(n.keys[i], hi) >= 0 {
+			return false, nil
+		}
+		kid, err := tx.child(n, i)
+		if err != nil {
+			return false, err
+		}
+		if more, err := tx.ascend(kid, lo, hi, fn); err != nil || !more {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (tx *Tx) descend(n *node, lo, hi []byte, fn func(key, value []byte) (bool, error)) (bool, error) {
+	if n.leaf {
+		i := len(n.keys) - 1
+		if hi != nil {
+			j, _ := slices.BinarySearchFunc(n.keys, hi, bytes.Compare)
+			i = j - 1
+		}
+		for ; i >= 0; i-- {
+			if lo != nil && bytes.Compare(n.keys[i], lo) < 0 {
+				return false, nil
+			}
+			v, err := tx.value(n.vals[i])
+			if err != nil {
+				return false, err
+			}
+			if more, err := fn(n.keys[i], v); err != nil || !more {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	i := len(n.ids) - 1
+	if hi != nil {
+		i = branchIndex(n.keys, hi)
+	}
+	for ; i >= 0; i-- {
+		kid, err := tx.child(n, i)
+		if err != nil {
+			return false, err
+		}
+		if more, err := tx.descend(kid, lo, hi, fn); err != nil || !more {
+			return false, err
+		}
+		// the previous children only hold keys lower than keys[i]
+		if i > 0 && lo != nil && bytes.Compare(n.keys[i], lo) <= 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (tx *Tx) checkWrite(key []byte) error {
+	switch {
+	case !tx.writable:
+		return ErrTxNotWritable
+	case len(key) == 0:
+		return ErrKeyRequired
+	case len(key) > MaxKeySize:
+		return ErrKeyTooLarge
+	}
+	return nil
+}
+
+// load returns a copy of the node held in the given page.
+func (tx *Tx) load(id pgid) (*node, error) {
+	n, err := tx.db.cache.Get(id)
+	if err != nil {
+		buf, err := tx.db.readPage(id)
+		if err != nil {
+			return nil, err
+		}
+		if n, err = decodeNode(id, buf); err != nil {
+			return nil, err
+		}
+		tx.db.cache.Put(id, n)
+	}
+	cp := *n
+	return &cp, nil
+}
+
+// child returns the child i of a branch node. Writable transactions keep
+// the children they load so that modifications can be written back.
+func (tx *Tx) child(n *node, i int) (*node, error) {
+	if n.kids != nil && n.kids[i] != nil {
+		return n.kids[i], nil
+	}
+	kid, err := tx.load(n.ids[i])
+	if err != nil || !tx.writable {
+		return kid, err
+	}
+	if n.kids == nil {
+		n.kids = make([]*node, len(n.ids))
+	}
+	n.kids[i] = kid
+	return kid, nil
+}
+
+func (tx *Tx) insert(n *node, key []byte, v value) ([]*node, error) {
+	n.own()
+	if n.leaf {
+		i, found := slices.BinarySearchFunc(n.keys, key, bytes.Compare)
+		if found {
+			if err := tx.freeValue(n.vals[i]); err != nil {
+				return nil, err
+			}
+			n.vals[i] = v
+		} else {
+			n.keys = slices.Insert(n.keys, i, key)
+			n.vals = slices.Insert(n.vals, i, v)
+		}
+		return n.split(), nil
+	}
+	i := branchIndex(n.keys, key)
+	if i == 0 && bytes.Compare(key, n.keys[0]) < 0 {
+		// keep keys[0] a lower bound of the first child
+		n.keys[0] = key
+	}
+	kid, err := tx.child(n, i)
+	if err != nil {
+		return nil, err
+	}
+	parts, err := tx.insert(kid, key, v)
+	if err != nil {
+		return nil, err
+	}
+	n.replaceChild(i, parts)
+	return n.split(), nil
+}
+
+func (tx *Tx) remove(n *node, key []byte) (bool, error) {
+	if n.leaf {
+		i, found := slices.BinarySearchFunc(n.keys, key, bytes.Compare)
+		if !found {
+			return false, nil
+		}
+		if err := tx.freeValue(n.vals[i]); err != nil {
+			return false, err
+		}
+		n.own()
+		n.keys = slices.Delete(n.keys, i, i+1)
+		n.vals = slices.Delete(n.vals, i, i+1)
+		return true, nil
+	}
+	i := branchIndex(n.keys, key)
+	kid, err := tx.child(n, i)
+	if err != nil {
+		return false, err
+	}
+	found, err := tx.remove(kid, key)
+	if err != nil || !found {
+		return found, err
+	}
+	n.own()
+	return true, tx.rebalance(n, i)
+}
+
+// rebalance drops the child i of n if it is empty, or merges it with a
+// sibling if it is underfilled.
+func (tx *Tx) rebalance(n *node, i int) error {
+	kid := n.kids[i]
+	if len(kid.keys) == 0 {
+		tx.freePage(kid.pgid)
+		n.removeChild(i)
+		return nil
+	}
+	if kid.size() >= minFill || len(n.ids) < 2 {
+		return nil
+	}
+	l, r := i-1, i
+	if i == 0 {
+		l, r = 0, 1
+	}
+	left, err := tx.child(n, l)
+	if err != nil {
+		return err
+	}
+	right, err := tx.child(n, r)
+	if err != nil {
+		return err
+	}
+	left.own()
+	if left.leaf {
+		left.keys = append(left.keys, right.keys...)
+		left.vals = append(left.vals, right.vals...)
+	} else {
+		// the separator held by the parent bounds the whole right node,
+		// which may be lower than its own first key
+		left.keys = append(left.keys, n.keys[r])
+		left.keys = append(left.keys, right.keys[1:]...)
+		left.ids = append(left.ids, right.ids...)
+		kids := right.kids
+		if kids == nil {
+			kids = make([]*node, len(right.ids))
+		}
+		left.kids = append(left.kids, kids...)
+	}
+	tx.freePage(right.pgid)
+	n.removeChild(r)
+	n.replaceChild(l, left.split())
+	return nil
+}
+
+func (tx *Tx) value(v value) ([]byte, error) {
+	if v.overflow == 0 {
+		return v.data, nil
+	}
+	data := make([]byte, 0, v.size)
+	for id := v.overflow; id != 0 && len(data) < v.size; {
+		buf, err := tx.db.readPage(id)
+		if err != nil {
+			return nil, err
+		}
+		typ, count, next := readHeader(buf)
+		if typ != pageOverflow || count > overflowCapacity {
+			return nil, fmt.Errorf("kv: page %d is not an overflow page", id)
+		}
+		data = append(data, buf[pageHeaderSize:pageHeaderSize+count]...)
+		id = next
+	}
+	if len(data) != v.size {
+		return nil, fmt.Errorf("kv: overflow chain %d is truncated", v.overflow)
+	}
+	return data, nil
+}
+
+func (tx *Tx) writeOverflow(data []byte) (pgid, error) {
+	ids := make([]pgid, (len(data)+overflowCapacity-1)/overflowCapacity)
+	for i := range ids {
+		ids[i] = tx.allocate()
+	}
+	buf := make([]byte, pageSize)
+	for i, id := range ids {
+		var next pgid
+		if i+1 < len(ids) {
+			next = ids[i+1]
+		}
+		chunk := data[i*overflowCapacity : min((i+1)*overflowCapacity, len(data))]
+		clear(buf)
+		putHeader(buf, pageOverflow, uint32(len(chunk)), next)
+		copy(buf[pageHeaderSize:], chunk)
+		if err := tx.db.writePage(id, buf); err != nil {
+			return 0, err
+		}
+	}
+	return ids[0], nil
+}
+
+// freeValue releases the overflow pages of a value.
+func (tx *Tx) freeValue(v value) error {
+	for id := v.overflow; id != 0; {
+		buf, err := tx.db.readPage(id)
+		if err != nil {
+			return err
+		}
+		_, _, next := readHeader(buf)
+		tx.freePage(id)
+		id = next
+	}
+	return nil
+}
+
+// allocate returns a page which is not used by the last commit, growing
+// the file if there is no free page left.
+func (tx *Tx) allocate() pgid {
+	if len(tx.free) > 0 {
+		id := tx.free[0]
+		tx.free = tx.free[1:]
+		return id
+	}
+	id := tx.pageCount
+	tx.pageCount++
+	return id
+}
+
+// freePage releases a page. The page is still used by the last commit so it
+// only becomes available to the next transactions.
+func (tx *Tx) freePage(id pgid) {
+	if id != 0 {
+		tx.pending = append(tx.pending, id)
+	}
+}
+
+// spill writes the modified nodes of the tree rooted at n to new pages and
+// returns the page of n.
+func (tx *Tx) spill(n *node) (pgid, error) {
+	if !n.dirty {
+		return n.pgid, nil
+	}
+	for i, kid := range n.kids {
+		if kid == nil {
+			continue
+		}
+		id, err := tx.spill(kid)
+		if err != nil {
+			return 0, err
+		}
+		n.ids[i] = id
+	}
+	tx.freePage(n.pgid)
+	id := tx.allocate()
+	buf := make([]byte, pageSize)
+	n.encode(buf)
+	if err := tx.db.writePage(id, buf); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// commit writes the modified tree and the freelist, syncs them, then
+// switches to the new tree by writing and syncing the meta page which was
+// not used by the last commit.
+func (tx *Tx) commit() error {
+	if !tx.changed {
+		return nil
+	}
+	db := tx.db
+	m := meta{txid: db.meta.txid + 1}
+	if tx.root != nil {
+		root, err := tx.spill(tx.root)
+		if err != nil {
+			return err
+		}
+		m.root = root
+	}
+	tx.pending = append(tx.pending, db.freelistPages...)
+	pages := make([]pgid, (len(tx.free)+len(tx.pending)+freelistCapacity-1)/freelistCapacity)
+	for i := range pages {
+		pages[i] = tx.allocate()
+	}
+	free := append(slices.Clone(tx.free), tx.pending...)
+	slices.Sort(free)
+	buf := make([]byte, pageSize)
+	for i, id := range pages {
+		var next pgid
+		if i+1 < len(pages) {
+			next = pages[i+1]
+		}
+		chunk := free[min(i*freelistCapacity, len(free)):min((i+1)*freelistCapacity, len(free))]
+		clear(buf)
+		putHeader(buf, pageFreelist, uint32(len(chunk)), next)
+		for j, f := range chunk {
+			binary.LittleEndian.PutUint64(buf[pageHeaderSize+j*8:], uint64(f))
+		}
+		if err := db.writePage(id, buf); err != nil {
+			return err
+		}
+	}
+	if len(pages) > 0 {
+		m.freelist = pages[0]
+	}
+	m.pageCount = tx.pageCount
+	if err := db.f.Sync(); err != nil {
+		return fmt.Errorf("kv: unable to sync database: %w", err)
+	}
+	clear(buf)
+	m.write(buf)
+	if err := db.writePage(pgid(m.txid%2), buf); err != nil {
+		return err
+	}
+	if err := db.f.Sync(); err != nil {
+		return fmt.Errorf("kv: unable to sync database: %w", err)
+	}
+	db.meta = m
+	db.free = free
+	db.freelistPages = pages
+	return nil
+}
+
+// own makes the node writable, copying the slices it shares with the node
+// cache.
+func (n *node) own() {
+	if n.dirty {
+		return
+	}
+	n.dirty = true
+	n.keys = slices.Clone(n.keys)
+	if n.leaf {
+		n.vals = slices.Clone(n.vals)
+		return
+	}
+	n.ids = slices.Clone(n.ids)
+	kids := make([]*node, len(n.ids))
+	copy(kids, n.kids)
+	n.kids = kids
+}
+
+// split splits a node which does not fit in a page.
+func (n *node) split() []*node {
+	size := n.size()
+	if size <= pageSize || len(n.keys) < 2 {
+		return []*node{n}
+	}
+	half := (size - pageHeaderSize) / 2
+	m, acc := 0, 0
+	for m < len(n.keys)-1 {
+		acc += n.entrySize(m)
+		m++
+		if acc >= half {
+			break
+		}
+	}
+	right := &node{leaf: n.leaf, dirty: true, keys: slices.Clone(n.keys[m:])}
+	n.keys = n.keys[:m:m]
+	if n.leaf {
+		right.vals = slices.Clone(n.vals[m:])
+		n.vals = n.vals[:m:m]
+	} else {
+		right.ids = slices.Clone(n.ids[m:])
+		right.kids = slices.Clone(n.kids[m:])
+		n.ids = n.ids[:m:m]
+		n.kids = n.kids[:m:m]
+	}
+	return append(n.split(), right.split()...)
+}
+
+// replaceChild replaces the child i of a branch with the given nodes.
+func (n *node) replaceChild(i int, parts []*node) {
+	n.kids[i] = parts[0]
+	for j, p := range parts[1:] {
+		n.keys = slices.Insert(n.keys, i+1+j, p.keys[0])
+		n.ids = slices.Insert(n.ids, i+1+j, p.pgid)
+		n.kids = slices.Insert(n.kids, i+1+j, p)
+	}
+}
+
+func (n *node) removeChild(i int) {
+	n.keys = slices.Delete(n.keys, i, i+1)
+	n.ids = slices.Delete(n.ids, i, i+1)
+	n.kids = slices.Delete(n.kids, i, i+1)
+}
+
+// branchIndex returns the child of a branch which may hold the given key.
+func branchIndex(keys [][]byte, key []byte) int {
+	i, found := slices.BinarySearchFunc(keys, key, bytes.Compare)
+	if found || i == 0 {
+		return i
+	}
+	return i - 1
+}
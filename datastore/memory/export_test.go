@@ -0,0 +1,11 @@
+package memory
+
+// Waiting returns the number of callers queued for lockID.
+func (r *Synchroniser) Waiting(lockID string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if state, exists := r.locks[lockID]; exists {
+		return len(state.waiters)
+	}
+	return 0
+}
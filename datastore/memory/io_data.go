@@ -4,17 +4,44 @@ import (
 	"errors"
 	"io"
 	"sync"
+	"time"
 )
 
 func NewIOData() *IOData {
 	return &IOData{
-		d: []byte{},
+		d:  []byte{},
+		mt: time.Now(),
 	}
 }
 
 type IOData struct {
 	mu sync.RWMutex
 	d  []byte
+	// mt is the last modification time
+	mt time.Time
+}
+
+// Stat returns the size and the last modification time of the data.
+func (d *IOData) Stat() (int64, time.Time) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return int64(len(d.d)), d.mt
+}
+
+// Truncate changes the size of the data, extending it with zeroes if needed.
+func (d *IOData) Truncate(size int64) error {
+	if size < 0 {
+		return errors.New("truncate to negative size")
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if size <= int64(len(d.d)) {
+		d.d = d.d[:size]
+	} else {
+		d.d = append(d.d, make([]byte, size-int64(len(d.d)))...)
+	}
+	d.mt = time.Now()
+	return nil
 }
 
 func (d *IOData) ReadAt(p []byte, off int64) (n int, err error) {
@@ -53,6 +80,7 @@ func (d *IOData) WriteAt(p []byte, off int64) (n int, err error) {
 	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
+	d.mt = time.Now()
 	for off > int64(len(d.d)) {
 		d.d = append(d.d, 0)
 	}
@@ -66,3 +94,11 @@ func (d *IOData) WriteAt(p []byte, off int64) (n int, err error) {
 	}
 	return n, nil
 }
+
+// Replace swaps the content of the data.
+func (d *IOData) Replace(p []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.d = p
+	d.mt = time.Now()
+}
@@ -3,9 +3,12 @@ package memory
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
 	"sync"
 
 	cache "github.com/slawo/go-cache"
+	"github.com/slawo/go-cache/datastore"
 )
 
 const (
@@ -29,9 +32,16 @@ func (s *IOProvider) GetReaderAt(ctx context.Context, ID string, position int64)
 	if ID == "" {
 		return nil, errors.New("io provider: missing ID")
 	}
+	s.mu.RLock()
+	data, exists := s.data[ID]
+	s.mu.RUnlock()
+	if !exists {
+		// the data has not been written yet, it reads as empty data
+		data = NewIOData()
+	}
 	return &IOReader{
 		p: position,
-		d: s.getIOData(ID),
+		d: data,
 	}, nil
 }
 
@@ -45,6 +55,71 @@ func (s *IOProvider) GetWriterAt(ctx context.Context, ID string, position int64)
 	}, nil
 }
 
+// Delete removes the data with the given ID.
+func (s *IOProvider) Delete(ctx context.Context, ID string) error {
+	if ID == "" {
+		return errors.New("io provider: missing ID")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.data[ID]; !exists {
+		return fmt.Errorf("%w: %s", datastore.ErrFileNotFound, ID)
+	}
+	delete(s.data, ID)
+	return nil
+}
+
+// Stat returns the size and modification time of the data with the given ID.
+func (s *IOProvider) Stat(ctx context.Context, ID string) (*datastore.FileInfo, error) {
+	data, err := s.lookup(ID)
+	if err != nil {
+		return nil, err
+	}
+	size, mt := data.Stat()
+	return &datastore.FileInfo{
+		FileId:     ID,
+		Size:       size,
+		ModifiedAt: mt,
+	}, nil
+}
+
+// List returns the IDs of the data held by the provider.
+func (s *IOProvider) List(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	ids := make([]string, 0, len(s.data))
+	for id := range s.data {
+		ids = append(ids, id)
+	}
+	s.mu.RUnlock()
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// Truncate changes the size of the data with the given ID.
+func (s *IOProvider) Truncate(ctx context.Context, ID string, size int64) error {
+	data, err := s.lookup(ID)
+	if err != nil {
+		return err
+	}
+	if err := data.Truncate(size); err != nil {
+		return fmt.Errorf("io provider: %w", err)
+	}
+	return nil
+}
+
+func (s *IOProvider) lookup(ID string) (*IOData, error) {
+	if ID == "" {
+		return nil, errors.New("io provider: missing ID")
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, exists := s.data[ID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", datastore.ErrFileNotFound, ID)
+	}
+	return data, nil
+}
+
 func (s *IOProvider) getIOData(fileId string) *IOData {
 	s.mu.RLock()
 	data, exists := s.data[fileId]
@@ -52,10 +127,10 @@ func (s *IOProvider) getIOData(fileId string) *IOData {
 	if !exists {
 		s.mu.Lock()
 		defer s.mu.Unlock()
-		data = &IOData{
-			d: []byte{},
+		if data, exists = s.data[fileId]; !exists {
+			data = NewIOData()
+			s.data[fileId] = data
 		}
-		s.data[fileId] = data
 	}
 	return data
 }
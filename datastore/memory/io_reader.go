@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/slawo/go-cache/datastore/internal/chunked"
 	"sync"
 )
 
@@ -25,9 +26,11 @@ func (r *IOReader) Read(ctx context.Context, p []byte) (n int, err error) {
 	if r.d == nil {
 		return 0, errors.New("file writer: file is not open")
 	}
-	n, err = r.d.ReadAt(p, r.p)
-	r.p += int64(n)
-	return
+	return chunked.Do(ctx, p, func(c []byte) (int, error) {
+		n, err := r.d.ReadAt(c, r.p)
+		r.p += int64(n)
+		return n, err
+	})
 }
 
 func (r *IOReader) Close() error {
@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/slawo/go-cache/datastore"
+	"github.com/slawo/go-cache/datastore/internal/chunked"
+)
+
+// GetTxWriterAt returns a transactional writer for the given ID. The data is
+// buffered and replaces the existing data on commit, starting with a copy of
+// its first position bytes.
+func (s *IOProvider) GetTxWriterAt(ctx context.Context, ID string, position int64) (datastore.TxWriteCloser, error) {
+	if ID == "" {
+		return nil, errors.New("io provider: missing ID")
+	}
+	if position < 0 {
+		return nil, errors.New("io provider: position cannot be negative")
+	}
+	buf := NewIOData()
+	s.mu.RLock()
+	data, exists := s.data[ID]
+	s.mu.RUnlock()
+	if exists && position > 0 {
+		data.mu.RLock()
+		buf.d = append(buf.d, data.d[:min(position, int64(len(data.d)))]...)
+		data.mu.RUnlock()
+	}
+	if err := buf.Truncate(position); err != nil {
+		return nil, fmt.Errorf("io provider: %w", err)
+	}
+	return &IOTxWriter{
+		s:  s,
+		id: ID,
+		p:  position,
+		d:  buf,
+	}, nil
+}
+
+// IOTxWriter is a transactional writer buffering the data until it is
+// committed.
+type IOTxWriter struct {
+	mu sync.Mutex
+	s  *IOProvider
+	id string
+	p  int64
+	d  *IOData
+}
+
+func (w *IOTxWriter) GetPosition(ctx context.Context) int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.p
+}
+
+func (w *IOTxWriter) Write(ctx context.Context, p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.d == nil {
+		return 0, datastore.ErrWriterClosed
+	}
+	return chunked.Do(ctx, p, func(c []byte) (int, error) {
+		n, err := w.d.WriteAt(c, w.p)
+		w.p += int64(n)
+		return n, err
+	})
+}
+
+// Commit replaces the data of the provider with the buffered data.
+func (w *IOTxWriter) Commit(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.d == nil {
+		return datastore.ErrWriterClosed
+	}
+	buf := w.d
+	w.d = nil
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("io provider: %w", err)
+	}
+	w.s.getIOData(w.id).Replace(buf.d)
+	return nil
+}
+
+// Abort discards the buffered data.
+func (w *IOTxWriter) Abort() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.d == nil {
+		return datastore.ErrWriterClosed
+	}
+	w.d = nil
+	return nil
+}
+
+// Close discards the buffered data if the writer was neither committed nor
+// aborted.
+func (w *IOTxWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.d = nil
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/slawo/go-cache/datastore/internal/chunked"
 	"sync"
 )
 
@@ -25,9 +26,11 @@ func (w *IOWriter) Write(ctx context.Context, p []byte) (n int, err error) {
 	if w.d == nil {
 		return 0, errors.New("file writer: file is not open")
 	}
-	n, err = w.d.WriteAt(p, w.p)
-	w.p += int64(n) // Update the position after reading
-	return
+	return chunked.Do(ctx, p, func(c []byte) (int, error) {
+		n, err := w.d.WriteAt(c, w.p)
+		w.p += int64(n) // Update the position after writing
+		return n, err
+	})
 }
 
 func (w *IOWriter) Close() error {
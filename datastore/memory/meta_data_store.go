@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/slawo/go-cache/datastore"
 )
@@ -57,6 +58,20 @@ func (s *MetaDataStore) SaveFileMeta(ctx context.Context, fileMeta *datastore.Fi
 	return nil
 }
 
+// DeleteFileMeta removes the metadata and completion data of a file.
+func (s *MetaDataStore) DeleteFileMeta(ctx context.Context, fileId string) error {
+	if strings.TrimSpace(fileId) == "" {
+		return fmt.Errorf("%w: empty file ID", datastore.ErrInvalidFileID)
+	}
+	s.muFileMeta.Lock()
+	delete(s.mapFileMeta, fileId)
+	s.muFileMeta.Unlock()
+	s.muFileCompletionData.Lock()
+	delete(s.mapFileCompletionData, fileId)
+	s.muFileCompletionData.Unlock()
+	return nil
+}
+
 // GetFileCompletionData retrieves completion data for a file by its ID.
 func (s *MetaDataStore) GetFileCompletionData(ctx context.Context, fileId string) (*datastore.FileCompletionData, error) {
 	if strings.TrimSpace(fileId) == "" {
@@ -64,9 +79,8 @@ func (s *MetaDataStore) GetFileCompletionData(ctx context.Context, fileId string
 	}
 	s.muFileCompletionData.RLock()
 	defer s.muFileCompletionData.RUnlock()
-	if fileMeta, exists := s.mapFileCompletionData[fileId]; exists {
-		completionCopy := *fileMeta // Create a copy to avoid external modifications
-		return &completionCopy, nil
+	if completionData, exists := s.mapFileCompletionData[fileId]; exists {
+		return completionData.Clone(), nil // Create a copy to avoid external modifications
 	}
 	return nil, fmt.Errorf("%w: %s", datastore.ErrFileNotFound, fileId)
 }
@@ -83,9 +97,31 @@ func (s *MetaDataStore) SaveFileCompletionData(ctx context.Context, completionDa
 	if s.mapFileCompletionData == nil {
 		s.mapFileCompletionData = make(map[string]*datastore.FileCompletionData)
 	}
-	// Create a copy of the fileMeta to avoid external modifications
-	completionCopy := *completionData
-	s.mapFileCompletionData[completionData.FileId] = &completionCopy
+	// Create a copy of the completion data to avoid external modifications
+	s.mapFileCompletionData[completionData.FileId] = completionData.Clone()
 
 	return nil
 }
+
+// MarkPartCompleted sets a part in the completion data of a file.
+func (s *MetaDataStore) MarkPartCompleted(ctx context.Context, fileId string, part int) (*datastore.FileCompletionData, error) {
+	if strings.TrimSpace(fileId) == "" {
+		return nil, fmt.Errorf("%w: empty file ID", datastore.ErrInvalidFileID)
+	}
+	s.muFileCompletionData.Lock()
+	defer s.muFileCompletionData.Unlock()
+	completionData, exists := s.mapFileCompletionData[fileId]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", datastore.ErrFileNotFound, fileId)
+	}
+	updated := completionData.Clone()
+	set, err := updated.SetPartCompleted(part)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %d", err, part)
+	}
+	if set {
+		updated.UpdatedAt = time.Now()
+		s.mapFileCompletionData[fileId] = updated
+	}
+	return updated.Clone(), nil
+}
@@ -1,10 +1,12 @@
 package memory_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/slawo/go-cache/datastore"
 	"github.com/slawo/go-cache/datastore/memory"
+	"github.com/slawo/go-cache/datastore/tests"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -110,3 +112,11 @@ func TestMemoryMetaDataStoreGetFileCompletionDataSuccess(t *testing.T) {
 	assert.Equal(t, int64(1024), m.PartSize)
 	assert.Equal(t, 5, m.PartsCompleted)
 }
+
+func TestMemoryMetaDataStoreTests(t *testing.T) {
+	tests.RunMetaDataStoreTests(t, tests.MetaDataStoreTestsOpts{
+		NewMetaDataStore: func(ctx context.Context, t *testing.T) (datastore.MetaDataStore, error) {
+			return memory.NewMetaDataStore(), nil
+		},
+	})
+}
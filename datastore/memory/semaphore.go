@@ -0,0 +1,255 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slawo/go-cache/datastore"
+)
+
+// SemaphoreOption configures a Semaphore.
+type SemaphoreOption func(*Semaphore)
+
+// WithPermitLease limits the permits to the given lease, a permit which is
+// not renewed before its lease expires is released and reported as lost. A
+// zero duration disables the leases.
+func WithPermitLease(lease time.Duration) SemaphoreOption {
+	return func(s *Semaphore) {
+		s.lease = lease
+	}
+}
+
+// WithSemaphoreClock sets the clock of the leases, the system clock is used
+// by default.
+func WithSemaphoreClock(clock datastore.Clock) SemaphoreOption {
+	return func(s *Semaphore) {
+		s.clock = clock
+	}
+}
+
+// NewSemaphore creates a new Semaphore instance.
+func NewSemaphore(opts ...SemaphoreOption) (*Semaphore, error) {
+	s := &Semaphore{
+		keys:  make(map[string]*semaphoreState),
+		clock: datastore.SystemClock,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Semaphore is an in-memory datastore.DataSemaphore, the waiting callers
+// acquire the permits in FIFO order.
+type Semaphore struct {
+	mu    sync.Mutex
+	keys  map[string]*semaphoreState
+	lease time.Duration
+	clock datastore.Clock
+}
+
+// semaphoreState holds the permits of a key and the callers waiting for
+// them in arrival order.
+type semaphoreState struct {
+	permits map[*Permit]struct{}
+	waiters []*permitWaiter
+}
+
+type permitWaiter struct {
+	permits int
+	// granted receives the permit once it is handed over to the waiter
+	granted chan *Permit
+}
+
+func (s *Semaphore) Acquire(ctx context.Context, key string, permits int) (datastore.SemaphorePermit, error) {
+	if err := validatePermits(key, permits); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.canAcquire(key, permits) {
+		return nil, fmt.Errorf("%w: %s", datastore.ErrNoPermitAvailable, key)
+	}
+	return s.newPermit(key), nil
+}
+
+// Wait blocks until a permit is acquired or ctx is done, waiting callers
+// acquire the permits in FIFO order.
+func (s *Semaphore) Wait(ctx context.Context, key string, permits int) (datastore.SemaphorePermit, error) {
+	if err := validatePermits(key, permits); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, key)
+	}
+	s.mu.Lock()
+	if s.canAcquire(key, permits) {
+		defer s.mu.Unlock()
+		return s.newPermit(key), nil
+	}
+	waiter := &permitWaiter{permits: permits, granted: make(chan *Permit, 1)}
+	state := s.state(key)
+	state.waiters = append(state.waiters, waiter)
+	s.mu.Unlock()
+
+	select {
+	case p := <-waiter.granted:
+		return p, nil
+	case <-ctx.Done():
+	}
+	s.mu.Lock()
+	idx := slices.Index(state.waiters, waiter)
+	if idx >= 0 {
+		state.waiters = slices.Delete(state.waiters, idx, idx+1)
+		s.grant(key)
+	}
+	s.mu.Unlock()
+	if idx < 0 {
+		// the permit was handed over while ctx expired, pass it on
+		(<-waiter.granted).Release()
+	}
+	return nil, fmt.Errorf("%w: %s", ctx.Err(), key)
+}
+
+func validatePermits(key string, permits int) error {
+	if strings.TrimSpace(key) == "" {
+		return datastore.ErrInvalidLockID
+	}
+	if permits < 1 {
+		return datastore.ErrInvalidPermits
+	}
+	return nil
+}
+
+// state returns the state of a key, the caller must hold s.mu.
+func (s *Semaphore) state(key string) *semaphoreState {
+	state, exists := s.keys[key]
+	if !exists {
+		state = &semaphoreState{permits: make(map[*Permit]struct{})}
+		s.keys[key] = state
+	}
+	return state
+}
+
+// canAcquire reports whether a permit can be acquired without waiting, the
+// caller must hold s.mu.
+func (s *Semaphore) canAcquire(key string, permits int) bool {
+	state, exists := s.keys[key]
+	return !exists || (len(state.permits) < permits && len(state.waiters) == 0)
+}
+
+// newPermit registers a new permit, the caller must hold s.mu.
+func (s *Semaphore) newPermit(key string) *Permit {
+	p := &Permit{
+		s:        s,
+		key:      key,
+		released: make(chan struct{}),
+		lost:     make(chan struct{}),
+	}
+	if s.lease > 0 {
+		p.lease = s.clock.AfterFunc(s.lease, func() { s.expire(p) })
+	}
+	s.state(key).permits[p] = struct{}{}
+	return p
+}
+
+// grant hands the permits over to the waiters at the front of the queue and
+// drops the state once it is unused, the caller must hold s.mu.
+func (s *Semaphore) grant(key string) {
+	state := s.keys[key]
+	for len(state.waiters) > 0 && len(state.permits) < state.waiters[0].permits {
+		w := state.waiters[0]
+		state.waiters = state.waiters[1:]
+		w.granted <- s.newPermit(key)
+	}
+	if len(state.permits) == 0 && len(state.waiters) == 0 {
+		delete(s.keys, key)
+	}
+}
+
+func (s *Semaphore) release(p *Permit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if isClosed(p.lost) {
+		return fmt.Errorf("%w: %s", datastore.ErrLockLost, p.key)
+	}
+	state, exists := s.keys[p.key]
+	if !exists {
+		return datastore.ErrLockNotHeld
+	}
+	if _, held := state.permits[p]; !held {
+		return datastore.ErrLockNotHeld
+	}
+	delete(state.permits, p)
+	if p.lease != nil {
+		p.lease.Stop()
+	}
+	close(p.released)
+	s.grant(p.key)
+	return nil
+}
+
+// expire releases a permit whose lease expired.
+func (s *Semaphore) expire(p *Permit) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, exists := s.keys[p.key]
+	if !exists {
+		return
+	}
+	if _, held := state.permits[p]; !held {
+		return
+	}
+	delete(state.permits, p)
+	close(p.lost)
+	close(p.released)
+	s.grant(p.key)
+}
+
+func (s *Semaphore) renew(p *Permit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if isClosed(p.released) {
+		return fmt.Errorf("%w: %s", datastore.ErrLockLost, p.key)
+	}
+	if p.lease != nil && !p.lease.Reset(s.lease) {
+		// the lease expired, the permit is being released
+		return fmt.Errorf("%w: %s", datastore.ErrLockLost, p.key)
+	}
+	return nil
+}
+
+// Permit is a permit held on a Semaphore.
+type Permit struct {
+	s        *Semaphore
+	key      string
+	released chan struct{}
+	lost     chan struct{}
+	// lease expires the permit, it is nil without lease
+	lease datastore.ClockTimer
+}
+
+func (p *Permit) Release() error {
+	return p.s.release(p)
+}
+
+func (p *Permit) Released() bool {
+	return isClosed(p.released)
+}
+
+func (p *Permit) WaitReleased() <-chan struct{} {
+	return p.released
+}
+
+func (p *Permit) Lost() <-chan struct{} {
+	return p.lost
+}
+
+// Renew extends the lease of the permit.
+func (p *Permit) Renew(ctx context.Context) error {
+	return p.s.renew(p)
+}
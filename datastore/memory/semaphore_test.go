@@ -0,0 +1,49 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/slawo/go-cache/datastore"
+	"github.com/slawo/go-cache/datastore/memory"
+	"github.com/slawo/go-cache/datastore/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSemaphoreTests(t *testing.T) {
+	s, err := memory.NewSemaphore(memory.WithPermitLease(time.Second))
+	require.NoError(t, err)
+	tests.RunSemaphoreTests(t, tests.SemaphoreTestsOpts{
+		NewDataSemaphore: func(ctx context.Context, t *testing.T) (datastore.DataSemaphore, error) {
+			return s, nil
+		},
+		// a dead holder does not renew its permits
+		Crash: func(t *testing.T, s datastore.DataSemaphore) {},
+	})
+}
+
+func TestSemaphorePermitLeaseRenewal(t *testing.T) {
+	clock := tests.NewFakeClock()
+	s, err := memory.NewSemaphore(memory.WithPermitLease(100*time.Millisecond), memory.WithSemaphoreClock(clock))
+	require.NoError(t, err)
+	p, err := s.Acquire(t.Context(), "key", 1)
+	require.NoError(t, err)
+	for range 3 {
+		clock.Advance(50 * time.Millisecond)
+		require.NoError(t, p.(*memory.Permit).Renew(t.Context()))
+	}
+	clock.Advance(99 * time.Millisecond)
+	assert.False(t, p.Released())
+
+	clock.Advance(time.Millisecond)
+	select {
+	case <-p.Lost():
+	default:
+		t.Fatal("the lease of the permit did not expire")
+	}
+	assert.True(t, p.Released())
+	assert.ErrorIs(t, p.Release(), datastore.ErrLockLost)
+	assert.ErrorIs(t, p.(*memory.Permit).Renew(t.Context()), datastore.ErrLockLost)
+}
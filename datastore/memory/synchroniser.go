@@ -2,24 +2,96 @@ package memory
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"os"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/slawo/go-cache/datastore"
 )
 
+// SynchroniserOption configures a Synchroniser.
+type SynchroniserOption func(*Synchroniser)
+
+// WithWriterPreference makes read locks wait, or fail, while a caller of
+// WaitWriteLock is waiting, so that a steady flow of readers cannot starve
+// the writers.
+func WithWriterPreference() SynchroniserOption {
+	return func(s *Synchroniser) {
+		s.writerPreference = true
+	}
+}
+
+// WithLease limits the locks to the given lease, a lock which is not renewed
+// before its lease expires is released and reported as lost. It protects
+// against the holders which never unlock, for example after a panic. A
+// zero duration disables the leases.
+func WithLease(lease time.Duration) SynchroniserOption {
+	return func(s *Synchroniser) {
+		s.lease = lease
+	}
+}
+
+// WithClock sets the clock of the leases, the system clock is used by
+// default.
+func WithClock(clock datastore.Clock) SynchroniserOption {
+	return func(s *Synchroniser) {
+		s.clock = clock
+	}
+}
+
 // NewSynchroniser creates a new Synchroniser instance.
-func NewSynchroniser() (*Synchroniser, error) {
-	return &Synchroniser{
-		locks: make(map[string]*MutexWriteLock),
-	}, nil
+func NewSynchroniser(opts ...SynchroniserOption) (*Synchroniser, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("synchroniser: unable to generate ID: %w", err)
+	}
+	hostname, _ := os.Hostname()
+	s := &Synchroniser{
+		locks:    make(map[string]*lockState),
+		id:       hex.EncodeToString(id),
+		hostname: hostname,
+		clock:    datastore.SystemClock,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
 }
 
 type Synchroniser struct {
-	mu    sync.Mutex
-	locks map[string]*MutexWriteLock
+	mu               sync.Mutex
+	locks            map[string]*lockState
+	writerPreference bool
+	lease            time.Duration
+	clock            datastore.Clock
+	// fence is the last fencing token issued
+	fence uint64
+	// id and hostname identify the synchroniser in the lock holders
+	id       string
+	hostname string
+	// acquisitions counts the locks handed out, to identify their holders
+	acquisitions uint64
+}
+
+// lockState holds the locks with a given ID and the callers waiting for
+// them in arrival order.
+type lockState struct {
+	writer  *MutexWriteLock
+	readers map[*MutexReadLock]struct{}
+	waiters []*lockWaiter
+}
+
+type lockWaiter struct {
+	write   bool
+	purpose string
+	// granted receives the lock once it is handed over to the waiter
+	granted chan any
 }
 
 func (r *Synchroniser) GetWriteLock(ctx context.Context, lockID string) (datastore.DataWriteLock, error) {
@@ -28,37 +100,376 @@ func (r *Synchroniser) GetWriteLock(ctx context.Context, lockID string) (datasto
 	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if _, exists := r.locks[lockID]; exists {
+	if !r.canWrite(lockID) {
 		return nil, fmt.Errorf("%w: %s", datastore.ErrLockAlreadyHeld, lockID)
 	}
+	return r.newWriteLock(lockID, datastore.LockPurposeFromContext(ctx)), nil
+}
+
+// WaitWriteLock blocks until the lock is acquired or ctx is done, waiting
+// callers acquire the lock in FIFO order.
+func (r *Synchroniser) WaitWriteLock(ctx context.Context, lockID string) (datastore.DataWriteLock, error) {
+	lock, err := r.wait(ctx, lockID, true)
+	if err != nil {
+		return nil, err
+	}
+	return lock.(*MutexWriteLock), nil
+}
+
+// GetReadLock acquires a shared lock, it fails if a write lock is held or,
+// with writer preference, if a writer is waiting.
+func (r *Synchroniser) GetReadLock(ctx context.Context, lockID string) (datastore.DataReadLock, error) {
+	if strings.TrimSpace(lockID) == "" {
+		return nil, datastore.ErrInvalidLockID
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.canRead(lockID) {
+		return nil, fmt.Errorf("%w: %s", datastore.ErrLockAlreadyHeld, lockID)
+	}
+	return r.newReadLock(lockID, datastore.LockPurposeFromContext(ctx)), nil
+}
+
+// WaitReadLock blocks until a shared lock is acquired or ctx is done.
+func (r *Synchroniser) WaitReadLock(ctx context.Context, lockID string) (datastore.DataReadLock, error) {
+	lock, err := r.wait(ctx, lockID, false)
+	if err != nil {
+		return nil, err
+	}
+	return lock.(*MutexReadLock), nil
+}
+
+func (r *Synchroniser) wait(ctx context.Context, lockID string, write bool) (any, error) {
+	if strings.TrimSpace(lockID) == "" {
+		return nil, datastore.ErrInvalidLockID
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, lockID)
+	}
+	purpose := datastore.LockPurposeFromContext(ctx)
+	r.mu.Lock()
+	if write && r.canWrite(lockID) {
+		defer r.mu.Unlock()
+		return r.newWriteLock(lockID, purpose), nil
+	} else if !write && r.canRead(lockID) {
+		defer r.mu.Unlock()
+		return r.newReadLock(lockID, purpose), nil
+	}
+	waiter := &lockWaiter{write: write, purpose: purpose, granted: make(chan any, 1)}
+	state := r.state(lockID)
+	state.waiters = append(state.waiters, waiter)
+	r.mu.Unlock()
+
+	select {
+	case lock := <-waiter.granted:
+		return lock, nil
+	case <-ctx.Done():
+	}
+	r.mu.Lock()
+	idx := slices.Index(state.waiters, waiter)
+	if idx >= 0 {
+		state.waiters = slices.Delete(state.waiters, idx, idx+1)
+		// the waiters queued behind a writer may now proceed
+		r.grant(lockID)
+	}
+	r.mu.Unlock()
+	if idx < 0 {
+		// the lock was handed over while ctx expired, pass it on
+		switch lock := (<-waiter.granted).(type) {
+		case *MutexWriteLock:
+			lock.Unlock()
+		case *MutexReadLock:
+			lock.Unlock()
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ctx.Err(), lockID)
+}
+
+// state returns the state of a lock ID, the caller must hold r.mu.
+func (r *Synchroniser) state(lockID string) *lockState {
+	state, exists := r.locks[lockID]
+	if !exists {
+		state = &lockState{readers: make(map[*MutexReadLock]struct{})}
+		r.locks[lockID] = state
+	}
+	return state
+}
+
+// canWrite reports whether a write lock can be acquired without waiting,
+// the caller must hold r.mu.
+func (r *Synchroniser) canWrite(lockID string) bool {
+	state, exists := r.locks[lockID]
+	return !exists || (state.writer == nil && len(state.readers) == 0 && len(state.waiters) == 0)
+}
+
+// canRead reports whether a read lock can be acquired without waiting, the
+// caller must hold r.mu.
+func (r *Synchroniser) canRead(lockID string) bool {
+	state, exists := r.locks[lockID]
+	if !exists {
+		return true
+	}
+	if state.writer != nil {
+		return false
+	}
+	if r.writerPreference {
+		return !slices.ContainsFunc(state.waiters, func(w *lockWaiter) bool { return w.write })
+	}
+	return true
+}
+
+// newWriteLock registers a new write lock, the caller must hold r.mu.
+func (r *Synchroniser) newWriteLock(lockID, purpose string) *MutexWriteLock {
+	r.fence++
 	lock := &MutexWriteLock{
 		s:        r,
 		lockID:   lockID,
+		token:    r.fence,
+		holder:   r.newHolder(purpose),
 		unlocked: make(chan struct{}),
+		lost:     make(chan struct{}),
+	}
+	if r.lease > 0 {
+		lock.lease = r.clock.AfterFunc(r.lease, func() { r.expireWriteLock(lock) })
+		lock.deadline = lock.holder.AcquiredAt.Add(r.lease)
+	}
+	r.state(lockID).writer = lock
+	return lock
+}
+
+// newReadLock registers a new read lock, the caller must hold r.mu.
+func (r *Synchroniser) newReadLock(lockID, purpose string) *MutexReadLock {
+	lock := &MutexReadLock{
+		s:        r,
+		lockID:   lockID,
+		holder:   r.newHolder(purpose),
+		unlocked: make(chan struct{}),
+		lost:     make(chan struct{}),
+	}
+	if r.lease > 0 {
+		lock.lease = r.clock.AfterFunc(r.lease, func() { r.expireReadLock(lock) })
+		lock.deadline = lock.holder.AcquiredAt.Add(r.lease)
+	}
+	r.state(lockID).readers[lock] = struct{}{}
+	return lock
+}
+
+// newHolder describes the holder of a new lock, the caller must hold r.mu.
+func (r *Synchroniser) newHolder(purpose string) datastore.LockHolder {
+	r.acquisitions++
+	return datastore.LockHolder{
+		ID:             fmt.Sprintf("%s-%d", r.id, r.acquisitions),
+		SynchroniserID: r.id,
+		Hostname:       r.hostname,
+		AcquiredAt:     r.clock.Now(),
+		Purpose:        purpose,
+	}
+}
+
+// GetLockInfo returns the locks held with the given ID.
+func (r *Synchroniser) GetLockInfo(ctx context.Context, lockID string) ([]datastore.LockInfo, error) {
+	if strings.TrimSpace(lockID) == "" {
+		return nil, datastore.ErrInvalidLockID
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, exists := r.locks[lockID]
+	if !exists || (state.writer == nil && len(state.readers) == 0) {
+		return nil, fmt.Errorf("%w: %s", datastore.ErrLockNotHeld, lockID)
+	}
+	var locks []datastore.LockInfo
+	now := r.clock.Now()
+	if state.writer != nil {
+		locks = append(locks, lockInfo(lockID, state.writer.holder, false, state.writer.deadline, now))
+	}
+	for l := range state.readers {
+		locks = append(locks, lockInfo(lockID, l.holder, true, l.deadline, now))
+	}
+	slices.SortFunc(locks, func(a, b datastore.LockInfo) int {
+		return a.Holder.AcquiredAt.Compare(b.Holder.AcquiredAt)
+	})
+	return locks, nil
+}
+
+func lockInfo(lockID string, holder datastore.LockHolder, shared bool, deadline, now time.Time) datastore.LockInfo {
+	info := datastore.LockInfo{LockID: lockID, Holder: holder, Shared: shared}
+	if !deadline.IsZero() {
+		info.TTL = max(deadline.Sub(now), 0)
+	}
+	return info
+}
+
+// ForceUnlock releases every lock held with the given ID, their holders are
+// notified through Lost and the waiting callers acquire the lock.
+func (r *Synchroniser) ForceUnlock(ctx context.Context, lockID string) error {
+	if strings.TrimSpace(lockID) == "" {
+		return datastore.ErrInvalidLockID
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, exists := r.locks[lockID]
+	if !exists || (state.writer == nil && len(state.readers) == 0) {
+		return fmt.Errorf("%w: %s", datastore.ErrLockNotHeld, lockID)
+	}
+	if l := state.writer; l != nil {
+		state.writer = nil
+		l.lose()
+	}
+	for l := range state.readers {
+		delete(state.readers, l)
+		l.lose()
+	}
+	r.grant(lockID)
+	return nil
+}
+
+// grant hands the lock over to the waiters at the front of the queue and
+// drops the state once it is unused, the caller must hold r.mu.
+func (r *Synchroniser) grant(lockID string) {
+	state := r.locks[lockID]
+	for len(state.waiters) > 0 && state.writer == nil {
+		w := state.waiters[0]
+		if w.write {
+			if len(state.readers) > 0 {
+				break
+			}
+			state.waiters = state.waiters[1:]
+			w.granted <- r.newWriteLock(lockID, w.purpose)
+		} else {
+			state.waiters = state.waiters[1:]
+			w.granted <- r.newReadLock(lockID, w.purpose)
+		}
+	}
+	if state.writer == nil && len(state.readers) == 0 && len(state.waiters) == 0 {
+		delete(r.locks, lockID)
 	}
-	r.locks[lockID] = lock
-	return lock, nil
 }
 
 func (r *Synchroniser) removeWriteLock(l *MutexWriteLock) (bool, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	lock, exists := r.locks[l.lockID]
-	if !exists {
-		return false, errors.New("lock is not held")
+	if isClosed(l.lost) {
+		return false, fmt.Errorf("%w: %s", datastore.ErrLockLost, l.lockID)
+	}
+	state, exists := r.locks[l.lockID]
+	if !exists || state.writer == nil {
+		return false, datastore.ErrLockNotHeld
 	}
-	if l != lock {
+	if l != state.writer {
 		return false, errors.New("lock does not match the held lock")
 	}
-	delete(r.locks, l.lockID)
+	state.writer = nil
+	if l.lease != nil {
+		l.lease.Stop()
+	}
 	close(l.unlocked)
+	r.grant(l.lockID)
 	return true, nil
 }
 
+// expireWriteLock releases a write lock whose lease expired.
+func (r *Synchroniser) expireWriteLock(l *MutexWriteLock) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, exists := r.locks[l.lockID]
+	if !exists || state.writer != l {
+		return
+	}
+	state.writer = nil
+	l.lose()
+	r.grant(l.lockID)
+}
+
+// renew extends the lease of a lock and moves its deadline, the lease of a
+// lock released by Unlock cannot be renewed either.
+func (r *Synchroniser) renew(lockID string, lease datastore.ClockTimer, deadline *time.Time, unlocked chan struct{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if isClosed(unlocked) {
+		return fmt.Errorf("%w: %s", datastore.ErrLockLost, lockID)
+	}
+	if lease != nil && !lease.Reset(r.lease) {
+		// the lease expired, the lock is being released
+		return fmt.Errorf("%w: %s", datastore.ErrLockLost, lockID)
+	}
+	if lease != nil {
+		*deadline = r.clock.Now().Add(r.lease)
+	}
+	return nil
+}
+
+func (r *Synchroniser) removeReadLock(l *MutexReadLock) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if isClosed(l.lost) {
+		return false, fmt.Errorf("%w: %s", datastore.ErrLockLost, l.lockID)
+	}
+	state, exists := r.locks[l.lockID]
+	if !exists {
+		return false, datastore.ErrLockNotHeld
+	}
+	if _, held := state.readers[l]; !held {
+		return false, datastore.ErrLockNotHeld
+	}
+	delete(state.readers, l)
+	if l.lease != nil {
+		l.lease.Stop()
+	}
+	close(l.unlocked)
+	r.grant(l.lockID)
+	return true, nil
+}
+
+// expireReadLock releases a read lock whose lease expired.
+func (r *Synchroniser) expireReadLock(l *MutexReadLock) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, exists := r.locks[l.lockID]
+	if !exists {
+		return
+	}
+	if _, held := state.readers[l]; !held {
+		return
+	}
+	delete(state.readers, l)
+	l.lose()
+	r.grant(l.lockID)
+}
+
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
 type MutexWriteLock struct {
 	s        *Synchroniser
 	lockID   string
+	token    uint64
+	holder   datastore.LockHolder
 	unlocked chan struct{}
+	lost     chan struct{}
+	// lease expires the lock at deadline, it is nil without lease
+	lease    datastore.ClockTimer
+	deadline time.Time
+}
+
+// lose signals the loss of the lock, the caller must hold the lock of the
+// synchroniser.
+func (l *MutexWriteLock) lose() {
+	if l.lease != nil {
+		l.lease.Stop()
+	}
+	close(l.lost)
+	close(l.unlocked)
+}
+
+// Holder returns the description of the holder of the lock.
+func (l *MutexWriteLock) Holder() datastore.LockHolder {
+	return l.holder
 }
 
 func (l *MutexWriteLock) Unlock() error {
@@ -78,3 +489,71 @@ func (l *MutexWriteLock) Unlocked() bool {
 func (l *MutexWriteLock) WaitUnlocked() <-chan struct{} {
 	return l.unlocked
 }
+
+func (l *MutexWriteLock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Renew extends the lease of the lock.
+func (l *MutexWriteLock) Renew(ctx context.Context) error {
+	return l.s.renew(l.lockID, l.lease, &l.deadline, l.unlocked)
+}
+
+// FencingToken returns the token issued when the lock was acquired, the
+// tokens are shared by every lock of the synchroniser.
+func (l *MutexWriteLock) FencingToken() uint64 {
+	return l.token
+}
+
+type MutexReadLock struct {
+	s        *Synchroniser
+	lockID   string
+	holder   datastore.LockHolder
+	unlocked chan struct{}
+	lost     chan struct{}
+	// lease expires the lock at deadline, it is nil without lease
+	lease    datastore.ClockTimer
+	deadline time.Time
+}
+
+// lose signals the loss of the lock, the caller must hold the lock of the
+// synchroniser.
+func (l *MutexReadLock) lose() {
+	if l.lease != nil {
+		l.lease.Stop()
+	}
+	close(l.lost)
+	close(l.unlocked)
+}
+
+// Holder returns the description of the holder of the lock.
+func (l *MutexReadLock) Holder() datastore.LockHolder {
+	return l.holder
+}
+
+func (l *MutexReadLock) Unlock() error {
+	_, err := l.s.removeReadLock(l)
+	return err
+}
+
+func (l *MutexReadLock) Unlocked() bool {
+	select {
+	case <-l.unlocked:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *MutexReadLock) WaitUnlocked() <-chan struct{} {
+	return l.unlocked
+}
+
+func (l *MutexReadLock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Renew extends the lease of the lock.
+func (l *MutexReadLock) Renew(ctx context.Context) error {
+	return l.s.renew(l.lockID, l.lease, &l.deadline, l.unlocked)
+}
@@ -2,12 +2,15 @@ package memory_test
 
 import (
 	"context"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/slawo/go-cache/datastore"
 	"github.com/slawo/go-cache/datastore/memory"
 	"github.com/slawo/go-cache/datastore/tests"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewSynchroniser(t *testing.T) {
@@ -63,6 +66,11 @@ func TestSynchroniserGetLockErrorOnSecondCall(t *testing.T) {
 	assert.NotNil(t, lock3)
 }
 
+// waiting returns the number of callers queued for lockID on s.
+func waiting(t *testing.T, s datastore.DataSynchroniser, lockID string) int {
+	return s.(*memory.Synchroniser).Waiting(lockID)
+}
+
 func TestSynchroniserLockTests(t *testing.T) {
 	create := func(ctx context.Context, t *testing.T) (datastore.DataSynchroniser, error) {
 		return memory.NewSynchroniser()
@@ -72,5 +80,152 @@ func TestSynchroniserLockTests(t *testing.T) {
 		MaxTries:            30,
 		MaxLocks:            500,
 		NewDataSynchroniser: create,
+		Waiting:             waiting,
+	})
+}
+
+func TestSynchroniserWaitLockTests(t *testing.T) {
+	tests.RunWaitLockTests(t, tests.WaitLockTestsOpts{
+		NewDataSynchroniser: func(ctx context.Context, t *testing.T) (datastore.BlockingDataSynchroniser, error) {
+			return memory.NewSynchroniser()
+		},
+		Waiting: waiting,
+	})
+}
+
+func TestSynchroniserWriterPreferenceLockTests(t *testing.T) {
+	create := func(ctx context.Context, t *testing.T) (datastore.DataSynchroniser, error) {
+		return memory.NewSynchroniser(memory.WithWriterPreference())
+	}
+	tests.RunParallelLockTests(t, tests.ParallelLockTestsOpts{
+		MaxSyncs:            1,
+		MaxTries:            30,
+		MaxLocks:            50,
+		WriterPreference:    true,
+		NewDataSynchroniser: create,
+		Waiting:             waiting,
+	})
+}
+
+func TestSynchroniserReadersWaitForWriter(t *testing.T) {
+	s, err := memory.NewSynchroniser()
+	require.NoError(t, err)
+	write, err := s.GetWriteLock(t.Context(), "memKey")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock, err := s.WaitReadLock(t.Context(), "memKey")
+			if assert.NoError(t, err) {
+				assert.True(t, write.Unlocked(), "readers wait for the writer")
+				<-time.After(10 * time.Millisecond)
+				assert.NoError(t, lock.Unlock())
+			}
+		}()
+	}
+	require.Eventually(t, func() bool { return s.Waiting("memKey") == 3 }, 5*time.Second, time.Millisecond)
+	require.NoError(t, write.Unlock())
+	wg.Wait()
+
+	lock, err := s.GetWriteLock(t.Context(), "memKey")
+	require.NoError(t, err)
+	require.NoError(t, lock.Unlock())
+}
+
+func TestSynchroniserLeaseExpires(t *testing.T) {
+	s, err := memory.NewSynchroniser(memory.WithLease(50 * time.Millisecond))
+	require.NoError(t, err)
+	lock, err := s.GetWriteLock(t.Context(), "memKey")
+	require.NoError(t, err)
+	read, err := s.GetReadLock(t.Context(), "memReadKey")
+	require.NoError(t, err)
+
+	// a waiter gets the lock once the lease expired
+	waiting, err := s.WaitWriteLock(t.Context(), "memKey")
+	require.NoError(t, err)
+	select {
+	case <-lock.(datastore.LosableLock).Lost():
+	default:
+		t.Fatal("the expired lock is not lost")
+	}
+	assert.True(t, lock.Unlocked())
+	assert.ErrorIs(t, lock.Unlock(), datastore.ErrLockLost)
+	assert.ErrorIs(t, lock.(datastore.RenewableLock).Renew(t.Context()), datastore.ErrLockLost)
+	require.NoError(t, waiting.Unlock())
+
+	<-read.WaitUnlocked()
+	assert.ErrorIs(t, read.Unlock(), datastore.ErrLockLost)
+	write, err := s.GetWriteLock(t.Context(), "memReadKey")
+	require.NoError(t, err)
+	require.NoError(t, write.Unlock())
+}
+
+func TestSynchroniserLeaseRenewal(t *testing.T) {
+	clock := tests.NewFakeClock()
+	s, err := memory.NewSynchroniser(memory.WithLease(50*time.Millisecond), memory.WithClock(clock))
+	require.NoError(t, err)
+	lock, err := s.GetWriteLock(t.Context(), "memKey")
+	require.NoError(t, err)
+	for range 10 {
+		clock.Advance(40 * time.Millisecond)
+		require.NoError(t, lock.(datastore.RenewableLock).Renew(t.Context()))
+	}
+	info, err := s.GetLockInfo(t.Context(), "memKey")
+	require.NoError(t, err)
+	require.Len(t, info, 1)
+	assert.Equal(t, 50*time.Millisecond, info[0].TTL)
+	assert.False(t, lock.Unlocked())
+	require.NoError(t, lock.Unlock())
+	assert.ErrorIs(t, lock.(datastore.RenewableLock).Renew(t.Context()), datastore.ErrLockLost)
+	select {
+	case <-lock.(datastore.LosableLock).Lost():
+		t.Fatal("a released lock is not lost")
+	default:
+	}
+}
+
+func TestSynchroniserLeaseLockTests(t *testing.T) {
+	tests.RunWaitLockTests(t, tests.WaitLockTestsOpts{
+		NewDataSynchroniser: func(ctx context.Context, t *testing.T) (datastore.BlockingDataSynchroniser, error) {
+			return memory.NewSynchroniser(memory.WithLease(time.Minute))
+		},
+		Waiting: waiting,
+	})
+}
+
+func TestSynchroniserInspectionTests(t *testing.T) {
+	tests.RunInspectionTests(t, tests.InspectionTestsOpts{
+		NewDataSynchroniser: func(ctx context.Context, t *testing.T) (datastore.InspectableDataSynchroniser, error) {
+			return memory.NewSynchroniser(memory.WithLease(time.Minute))
+		},
+	})
+}
+
+func TestSynchroniserLockHarness(t *testing.T) {
+	s, err := memory.NewSynchroniser()
+	require.NoError(t, err)
+	tests.RunLockHarness(t, tests.LockHarnessOpts{
+		NewDataSynchroniser: func(ctx context.Context, t *testing.T) (datastore.DataSynchroniser, error) {
+			return s, nil
+		},
+	})
+}
+
+func TestSynchroniserLeaseLockHarness(t *testing.T) {
+	clock := tests.NewFakeClock()
+	s, err := memory.NewSynchroniser(memory.WithLease(time.Second), memory.WithClock(clock))
+	require.NoError(t, err)
+	tests.RunLockHarness(t, tests.LockHarnessOpts{
+		NewDataSynchroniser: func(ctx context.Context, t *testing.T) (datastore.DataSynchroniser, error) {
+			return s, nil
+		},
+		Clock: clock,
+		Lease: time.Second,
+		// the locks are only kept by the renewals of their holder, which the
+		// harness stops once the holder crashed
+		Crash: func(t *testing.T, s datastore.DataSynchroniser, lock datastore.DataWriteLock) {},
 	})
 }
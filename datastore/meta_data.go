@@ -0,0 +1,136 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrInvalidPart is returned when a part index is out of range.
+	ErrInvalidPart = errors.New("invalid part")
+)
+
+// MaxParts bounds the part indices of the files whose number of parts is
+// unknown, so that their bitmap cannot grow without bound.
+const MaxParts = 1 << 20
+
+// FileMeta describes a file held by a DataIOProvider.
+type FileMeta struct {
+	// FileId is the ID of the file in the DataIOProvider.
+	FileId string
+	// FileSize is the expected size of the file in bytes.
+	FileSize int64
+	// Checksum is the checksum of the complete file, as given by the source.
+	Checksum string
+	// ContentType is the media type of the file.
+	ContentType string
+	// SourceURI is the location the file is fetched from.
+	SourceURI string
+	// CreatedAt is the time the file was first stored.
+	CreatedAt time.Time
+	// ModifiedAt is the time the file content was last changed.
+	ModifiedAt time.Time
+	// LastAccessedAt is the time the file was last read.
+	LastAccessedAt time.Time
+	// ExpiresAt is the time after which the file should be dropped, the
+	// zero value means the file does not expire.
+	ExpiresAt time.Time
+	// Complete reports whether the whole file has been written.
+	Complete bool
+}
+
+// FileCompletionData tracks which parts of a file have been written.
+type FileCompletionData struct {
+	// FileId is the ID of the file in the DataIOProvider.
+	FileId string
+	// PartSize is the size in bytes of every part but the last one.
+	PartSize int64
+	// TotalParts is the number of parts of the file, zero when unknown in
+	// which case the parts are bounded by MaxParts.
+	TotalParts int
+	// PartsCompleted is the number of parts set in Parts.
+	PartsCompleted int
+	// Parts is a bitmap of the completed parts. Part 0 is the most
+	// significant bit of the first byte, which is the layout used by redis
+	// bitmaps.
+	Parts []byte
+	// UpdatedAt is the time the completion data was last changed.
+	UpdatedAt time.Time
+}
+
+// IsPartCompleted reports whether the given part is set in the bitmap.
+func (c *FileCompletionData) IsPartCompleted(part int) bool {
+	if part < 0 || part/8 >= len(c.Parts) {
+		return false
+	}
+	return c.Parts[part/8]&(0x80>>(part%8)) != 0
+}
+
+// SetPartCompleted sets the given part in the bitmap and updates
+// PartsCompleted. It reports whether the part was not already set.
+func (c *FileCompletionData) SetPartCompleted(part int) (bool, error) {
+	if !ValidPart(c.TotalParts, part) {
+		return false, ErrInvalidPart
+	}
+	if c.IsPartCompleted(part) {
+		return false, nil
+	}
+	for part/8 >= len(c.Parts) {
+		c.Parts = append(c.Parts, 0)
+	}
+	c.Parts[part/8] |= 0x80 >> (part % 8)
+	c.PartsCompleted++
+	return true, nil
+}
+
+// ValidPart reports whether part is a valid index for a file of totalParts
+// parts, or of an unknown number of parts when totalParts is zero.
+func ValidPart(totalParts, part int) bool {
+	if totalParts <= 0 {
+		totalParts = MaxParts
+	}
+	return part >= 0 && part < totalParts
+}
+
+// Completed reports whether all the parts of a file of known size have
+// been written.
+func (c *FileCompletionData) Completed() bool {
+	return c.TotalParts > 0 && c.PartsCompleted >= c.TotalParts
+}
+
+// Clone returns a deep copy of the completion data.
+func (c *FileCompletionData) Clone() *FileCompletionData {
+	cp := *c
+	if c.Parts != nil {
+		cp.Parts = append([]byte(nil), c.Parts...)
+	}
+	return &cp
+}
+
+// MetaDataStore stores the metadata of the files held by a DataIOProvider.
+//
+// Stores return copies of the data they hold, the values they return can be
+// modified freely by the caller.
+//
+//go:generate mockery --name MetaDataStore --output mocks
+type MetaDataStore interface {
+	// GetFileMeta returns the metadata of the file with the given ID or
+	// ErrFileNotFound.
+	GetFileMeta(ctx context.Context, fileId string) (*FileMeta, error)
+	// SaveFileMeta creates or replaces the metadata of a file.
+	SaveFileMeta(ctx context.Context, fileMeta *FileMeta) error
+	// DeleteFileMeta removes both the metadata and the completion data of
+	// the file with the given ID. Deleting a missing file is not an error.
+	DeleteFileMeta(ctx context.Context, fileId string) error
+	// GetFileCompletionData returns the completion data of the file with the
+	// given ID or ErrFileNotFound.
+	GetFileCompletionData(ctx context.Context, fileId string) (*FileCompletionData, error)
+	// SaveFileCompletionData creates or replaces the completion data of a
+	// file.
+	SaveFileCompletionData(ctx context.Context, completionData *FileCompletionData) error
+	// MarkPartCompleted atomically sets a part in the completion data of the
+	// file with the given ID and returns the updated completion data. It
+	// returns ErrFileNotFound if the file has no completion data.
+	MarkPartCompleted(ctx context.Context, fileId string, part int) (*FileCompletionData, error)
+}
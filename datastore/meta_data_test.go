@@ -0,0 +1,63 @@
+package datastore_test
+
+import (
+	"testing"
+
+	"github.com/slawo/go-cache/datastore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileCompletionDataParts(t *testing.T) {
+	c := &datastore.FileCompletionData{FileId: "file", PartSize: 10, TotalParts: 10}
+	assert.False(t, c.IsPartCompleted(0))
+	assert.False(t, c.Completed())
+
+	set, err := c.SetPartCompleted(0)
+	assert.NoError(t, err)
+	assert.True(t, set)
+	set, err = c.SetPartCompleted(9)
+	assert.NoError(t, err)
+	assert.True(t, set)
+	set, err = c.SetPartCompleted(9)
+	assert.NoError(t, err)
+	assert.False(t, set)
+
+	// redis bitmap layout: part 0 is the most significant bit
+	assert.Equal(t, []byte{0x80, 0x40}, c.Parts)
+	assert.Equal(t, 2, c.PartsCompleted)
+	assert.True(t, c.IsPartCompleted(9))
+	assert.False(t, c.IsPartCompleted(8))
+	assert.False(t, c.IsPartCompleted(100))
+
+	_, err = c.SetPartCompleted(10)
+	assert.ErrorIs(t, err, datastore.ErrInvalidPart)
+	_, err = c.SetPartCompleted(-1)
+	assert.ErrorIs(t, err, datastore.ErrInvalidPart)
+
+	for i := 1; i < 9; i++ {
+		c.SetPartCompleted(i)
+	}
+	assert.True(t, c.Completed())
+}
+
+func TestFileCompletionDataUnknownTotalParts(t *testing.T) {
+	c := &datastore.FileCompletionData{FileId: "file", PartSize: 10}
+	set, err := c.SetPartCompleted(datastore.MaxParts - 1)
+	assert.NoError(t, err)
+	assert.True(t, set)
+	assert.Len(t, c.Parts, datastore.MaxParts/8)
+
+	_, err = c.SetPartCompleted(datastore.MaxParts)
+	assert.ErrorIs(t, err, datastore.ErrInvalidPart)
+	assert.Len(t, c.Parts, datastore.MaxParts/8, "the bitmap does not grow past MaxParts")
+}
+
+func TestFileCompletionDataClone(t *testing.T) {
+	c := &datastore.FileCompletionData{FileId: "file", TotalParts: 8}
+	c.SetPartCompleted(0)
+	cp := c.Clone()
+	cp.SetPartCompleted(1)
+	assert.False(t, c.IsPartCompleted(1))
+	assert.Equal(t, 1, c.PartsCompleted)
+	assert.Equal(t, 2, cp.PartsCompleted)
+}
@@ -0,0 +1,49 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrNoPermitAvailable is returned when every permit of a semaphore is
+	// held.
+	ErrNoPermitAvailable = errors.New("no permit available")
+	// ErrInvalidPermits is returned when a semaphore is used with less than
+	// one permit.
+	ErrInvalidPermits = errors.New("invalid number of permits")
+)
+
+// DataSemaphore is a counting semaphore limiting the number of concurrent
+// holders of a key, for example the fills fetching from the same origin
+// host. The number of permits is given by the callers, the callers sharing
+// a key are expected to use the same number.
+//
+//go:generate mockery --name DataSemaphore --output mocks
+type DataSemaphore interface {
+	// Acquire takes one of the permits of key, it fails with
+	// ErrNoPermitAvailable when all of them are held.
+	Acquire(ctx context.Context, key string, permits int) (SemaphorePermit, error)
+	// Wait blocks until one of the permits of key is acquired or ctx is
+	// done.
+	Wait(ctx context.Context, key string, permits int) (SemaphorePermit, error)
+}
+
+// SemaphorePermit is a permit held on a DataSemaphore. The permits are held
+// for a lease, so that the permits of the holders which die are eventually
+// released.
+//
+//go:generate mockery --name SemaphorePermit --output mocks
+type SemaphorePermit interface {
+	// Release gives the permit back, it returns ErrLockLost if the lease of
+	// the permit expired before.
+	Release() error
+	// Released checks if the permit was given back or lost.
+	Released() bool
+	// WaitReleased returns a channel that will be closed when the permit is
+	// given back or lost.
+	WaitReleased() <-chan struct{}
+	// Lost returns a channel that will be closed if the lease of the permit
+	// expires before it is given back.
+	Lost() <-chan struct{}
+}
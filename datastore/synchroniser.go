@@ -3,6 +3,7 @@ package datastore
 import (
 	"context"
 	"errors"
+	"time"
 )
 
 const (
@@ -15,6 +16,12 @@ var (
 	ErrInvalidLockID = errors.New(InvalidLockIDError)
 	// ErrLockAlreadyHeld is returned when a lock is already held.
 	ErrLockAlreadyHeld = errors.New(LockAlreadyHeldError)
+	// ErrLockLost is returned when a lock expired or was taken over before
+	// being released.
+	ErrLockLost = errors.New("lock lost")
+	// ErrLockNotHeld is returned when releasing or describing a lock which
+	// is not held.
+	ErrLockNotHeld = errors.New("lock is not held")
 )
 
 //go:generate mockery --name DataSynchroniser --output mocks
@@ -22,6 +29,18 @@ type DataSynchroniser interface {
 	GetWriteLock(ctx context.Context, lockID string) (DataWriteLock, error)
 }
 
+// BlockingDataSynchroniser is a DataSynchroniser which can wait for a lock to
+// be released instead of failing with ErrLockAlreadyHeld.
+//
+//go:generate mockery --name BlockingDataSynchroniser --output mocks
+type BlockingDataSynchroniser interface {
+	DataSynchroniser
+	// WaitWriteLock blocks until the lock is acquired or ctx is done. The
+	// callers waiting for the same lock acquire it in the order they
+	// started waiting.
+	WaitWriteLock(ctx context.Context, lockID string) (DataWriteLock, error)
+}
+
 //go:generate mockery --name DataWriteLock --output mocks
 type DataWriteLock interface {
 	// Unlock releases the lock. A new lock will need to be created.
@@ -31,3 +50,105 @@ type DataWriteLock interface {
 	// WaitUnlocked returns a channel that will be closed when the lock is released.
 	WaitUnlocked() <-chan struct{}
 }
+
+// LosableLock is implemented by the locks which can be lost before they are
+// released, for example when their lease expires or when they are taken
+// over.
+type LosableLock interface {
+	// Lost returns a channel that will be closed if the lock is lost before
+	// being released, Unlock then returns ErrLockLost.
+	Lost() <-chan struct{}
+}
+
+// FencedLock is implemented by the write locks issuing fencing tokens.
+type FencedLock interface {
+	// FencingToken returns the token issued when the lock was acquired. The
+	// successive holders of a lock get increasing tokens, so that a store
+	// can refuse the writes of a holder which lost the lock.
+	FencingToken() uint64
+}
+
+// RenewableLock is implemented by the locks held for a limited lease, they
+// are lost if they are not renewed in time.
+type RenewableLock interface {
+	// Renew extends the lease of the lock, it fails with ErrLockLost if the
+	// lease already expired.
+	Renew(ctx context.Context) error
+}
+
+// RWDataSynchroniser is a BlockingDataSynchroniser which also hands out
+// shared read locks. Any number of read locks can be held at once, a write
+// lock excludes every other lock with the same ID.
+//
+//go:generate mockery --name RWDataSynchroniser --output mocks
+type RWDataSynchroniser interface {
+	BlockingDataSynchroniser
+	// GetReadLock acquires a read lock, it fails with ErrLockAlreadyHeld if
+	// a write lock is held.
+	GetReadLock(ctx context.Context, lockID string) (DataReadLock, error)
+	// WaitReadLock blocks until a read lock is acquired or ctx is done.
+	WaitReadLock(ctx context.Context, lockID string) (DataReadLock, error)
+}
+
+//go:generate mockery --name DataReadLock --output mocks
+type DataReadLock interface {
+	// Unlock releases the lock. A new lock will need to be created.
+	Unlock() error
+	// Unlocked checks if the lock is currently held.
+	Unlocked() bool
+	// WaitUnlocked returns a channel that will be closed when the lock is released.
+	WaitUnlocked() <-chan struct{}
+}
+
+// LockHolder identifies the holder of a lock, for diagnostics.
+type LockHolder struct {
+	// ID identifies the acquisition of the lock.
+	ID string
+	// SynchroniserID identifies the synchroniser which handed out the lock.
+	SynchroniserID string
+	Hostname       string
+	AcquiredAt     time.Time
+	// Purpose is the purpose given with WithLockPurpose when acquiring the
+	// lock.
+	Purpose string
+}
+
+// LockInfo describes a held lock.
+type LockInfo struct {
+	LockID string
+	Holder LockHolder
+	// Shared is true for the read locks.
+	Shared bool
+	// TTL is the time left before the lock expires unless it is renewed, it
+	// is zero for the locks which do not expire.
+	TTL time.Duration
+}
+
+type lockPurposeKey struct{}
+
+// WithLockPurpose returns a context recording purpose in the holder of the
+// locks acquired with it.
+func WithLockPurpose(ctx context.Context, purpose string) context.Context {
+	return context.WithValue(ctx, lockPurposeKey{}, purpose)
+}
+
+// LockPurposeFromContext returns the purpose carried by ctx, if any.
+func LockPurposeFromContext(ctx context.Context) string {
+	purpose, _ := ctx.Value(lockPurposeKey{}).(string)
+	return purpose
+}
+
+// InspectableDataSynchroniser is a DataSynchroniser which can describe the
+// holders of its locks and release them by force, to diagnose and unblock
+// stuck holders.
+//
+//go:generate mockery --name InspectableDataSynchroniser --output mocks
+type InspectableDataSynchroniser interface {
+	DataSynchroniser
+	// GetLockInfo returns the locks held with the given ID, it fails with
+	// ErrLockNotHeld if none is held.
+	GetLockInfo(ctx context.Context, lockID string) ([]LockInfo, error)
+	// ForceUnlock releases every lock held with the given ID, their holders
+	// lose them. It fails with ErrLockNotHeld if none is held.
+	ForceUnlock(ctx context.Context, lockID string) error
+}
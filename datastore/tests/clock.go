@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/slawo/go-cache/datastore"
+)
+
+// FakeClock is a datastore.Clock which only moves when it is advanced, the
+// timers due fire synchronously within Advance in the order of their
+// deadlines.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock returns a FakeClock set to a fixed date.
+func NewFakeClock() *FakeClock {
+	return &FakeClock{now: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) datastore.ClockTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := &fakeTimer{c: c, f: f, when: c.now.Add(d), active: true}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+// Advance moves the clock forward by d and fires the timers which are due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	for {
+		next := c.next(target)
+		if next == nil {
+			break
+		}
+		c.now = next.when
+		next.active = false
+		c.mu.Unlock()
+		next.f()
+		c.mu.Lock()
+	}
+	c.now = target
+	c.mu.Unlock()
+}
+
+// next returns the first active timer due before target and drops the
+// stopped ones, the caller must hold c.mu.
+func (c *FakeClock) next(target time.Time) *fakeTimer {
+	var next *fakeTimer
+	active := c.timers[:0]
+	for _, timer := range c.timers {
+		if !timer.active {
+			continue
+		}
+		active = append(active, timer)
+		if !timer.when.After(target) && (next == nil || timer.when.Before(next.when)) {
+			next = timer
+		}
+	}
+	c.timers = active
+	return next
+}
+
+type fakeTimer struct {
+	c      *FakeClock
+	f      func()
+	when   time.Time
+	active bool
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	active := t.active
+	t.active = false
+	return active
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	active := t.active
+	t.when = t.c.now.Add(d)
+	if !slices.Contains(t.c.timers, t) {
+		t.c.timers = append(t.c.timers, t)
+	}
+	t.active = true
+	return active
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slawo/go-cache/datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// longIOSize is large enough for providers to check their context several
+// times during a single read or write.
+const longIOSize = 4 << 20
+
+// countingContext is a context which reports itself as cancelled once its
+// Err method has been called a given number of times. It lets the tests
+// cancel an operation in the middle of its I/O, providers are expected to
+// poll Err between the chunks of long reads and writes.
+type countingContext struct {
+	context.Context
+	mu        sync.Mutex
+	remaining int
+	calls     int
+}
+
+func newCountingContext(ctx context.Context, checks int) *countingContext {
+	return &countingContext{Context: ctx, remaining: checks}
+}
+
+func (c *countingContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	if c.remaining <= 0 {
+		return context.Canceled
+	}
+	c.remaining--
+	return c.Context.Err()
+}
+
+func (c *countingContext) Calls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
+
+func runContextTests(t *testing.T, p datastore.DataIOProvider) {
+	data := make([]byte, longIOSize)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	t.Run("WriteCancelled", func(t *testing.T) {
+		fn := generateFileName()
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		writer, err := p.GetWriterAt(t.Context(), fn, 0)
+		require.NoError(t, err)
+		defer writer.Close()
+		n, err := writer.Write(ctx, data)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 0, n)
+		assert.Equal(t, int64(0), writer.GetPosition(t.Context()))
+	})
+	t.Run("WriteDeadlineExceeded", func(t *testing.T) {
+		ctx, cancel := context.WithDeadline(t.Context(), time.Now().Add(-time.Second))
+		defer cancel()
+		writer, err := p.GetWriterAt(t.Context(), generateFileName(), 0)
+		require.NoError(t, err)
+		defer writer.Close()
+		_, err = writer.Write(ctx, data)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+	t.Run("LongWriteInterrupted", func(t *testing.T) {
+		fn := generateFileName()
+		writer, err := p.GetWriterAt(t.Context(), fn, 0)
+		require.NoError(t, err)
+		ctx := newCountingContext(t.Context(), 2)
+		n, err := writer.Write(ctx, data)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Greater(t, n, 0)
+		assert.Less(t, n, len(data))
+		assert.Equal(t, int64(n), writer.GetPosition(t.Context()))
+		require.NoError(t, writer.Close())
+		assert.Equal(t, data[:n], readTestFile(t, p, fn))
+	})
+	t.Run("ReadCancelled", func(t *testing.T) {
+		fn := generateFileName()
+		writeTestFile(t, p, fn, data)
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		reader, err := p.GetReaderAt(t.Context(), fn, 0)
+		require.NoError(t, err)
+		defer reader.Close()
+		n, err := reader.Read(ctx, make([]byte, 16))
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 0, n)
+		assert.Equal(t, int64(0), reader.GetPosition(t.Context()))
+	})
+	t.Run("ReadMissingFileCancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		reader, err := p.GetReaderAt(t.Context(), generateFileName(), 0)
+		require.NoError(t, err)
+		defer reader.Close()
+		_, err = reader.Read(ctx, make([]byte, 16))
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+	t.Run("LongReadInterrupted", func(t *testing.T) {
+		fn := generateFileName()
+		writeTestFile(t, p, fn, data)
+		reader, err := p.GetReaderAt(t.Context(), fn, 0)
+		require.NoError(t, err)
+		defer reader.Close()
+		ctx := newCountingContext(t.Context(), 2)
+		buf := make([]byte, len(data))
+		n, err := reader.Read(ctx, buf)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Greater(t, ctx.Calls(), 2)
+		assert.Greater(t, n, 0)
+		assert.Less(t, n, len(data))
+		assert.Equal(t, data[:n], buf[:n])
+		assert.Equal(t, int64(n), reader.GetPosition(t.Context()))
+
+		// the reader can resume with a live context
+		m, err := reader.Read(t.Context(), buf[n:])
+		require.NoError(t, err)
+		assert.Equal(t, len(data), n+m)
+		assert.Equal(t, data, buf)
+	})
+}
@@ -0,0 +1,308 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/slawo/go-cache/datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type LockHarnessOpts struct {
+	// NewDataSynchroniser returns the synchroniser of a node, the nodes
+	// share their locks, either through the same instance or the same
+	// backend.
+	NewDataSynchroniser func(ctx context.Context, t *testing.T) (datastore.DataSynchroniser, error)
+	// Clock is the clock of the synchronisers when they accept one, the
+	// harness then advances it instead of waiting for the leases to expire.
+	Clock *FakeClock
+	// Lease is how long the lock of a holder which stopped refreshing it
+	// survives, zero when the locks never expire and are only freed by the
+	// death of their holder.
+	Lease time.Duration
+	// Crash simulates the death of the holder of lock, acquired from s: the
+	// lock is neither refreshed nor released by its holder any more. It can
+	// do nothing for the backends whose locks are only kept by the explicit
+	// renewals of their holder, the harness never renews the lock of a
+	// crashed holder. The HolderCrash test is skipped when it is nil.
+	Crash func(t *testing.T, s datastore.DataSynchroniser, lock datastore.DataWriteLock)
+	// Partition cuts s off from the backend until heal is called, it only
+	// applies to the backends reached over the network. The Partition test
+	// is skipped when it is nil.
+	Partition func(t *testing.T, s datastore.DataSynchroniser) (heal func())
+	// SlowRefresh delays every exchange of s with the backend by d until
+	// restore is called, it only applies to the backends reached over the
+	// network. The SlowRefresh test is skipped when it is nil.
+	SlowRefresh func(t *testing.T, s datastore.DataSynchroniser, d time.Duration) (restore func())
+	// Seed seeds the schedule of the Safety test, a failure is replayed by
+	// running the test again with the seed it reports.
+	Seed uint64
+	// Steps is the number of operations of the Safety test, 200 by default.
+	Steps int
+}
+
+// RunLockHarness runs the write locks of a DataSynchroniser through a
+// deterministic schedule and through injected faults. It checks that a lock
+// is never granted while another holder still believes it holds it, and
+// that a lock which is free, released or abandoned by a dead holder is
+// eventually granted.
+//
+// The operations run sequentially. When opts.Clock is set the leases only
+// expire when the harness advances it, otherwise the harness sleeps.
+func RunLockHarness(t *testing.T, opts LockHarnessOpts) {
+	require.NotNil(t, opts.NewDataSynchroniser, "NewDataSynchroniser function must be provided")
+	if opts.Steps <= 0 {
+		opts.Steps = 200
+	}
+	h := &harness{opts: opts}
+	t.Run("Safety", h.runSafety)
+	t.Run("HolderCrash", func(t *testing.T) {
+		if opts.Crash == nil {
+			t.Skip("the holders cannot be crashed")
+		}
+		h.runHolderCrash(t)
+	})
+	t.Run("Partition", func(t *testing.T) {
+		if opts.Partition == nil || opts.Lease <= 0 {
+			t.Skip("the nodes cannot be partitioned")
+		}
+		h.runPartition(t)
+	})
+	t.Run("SlowRefresh", func(t *testing.T) {
+		if opts.SlowRefresh == nil || opts.Lease <= 0 {
+			t.Skip("the refreshes cannot be slowed down")
+		}
+		h.runSlowRefresh(t)
+	})
+}
+
+type harness struct {
+	opts LockHarnessOpts
+}
+
+func (h *harness) newSynchroniser(t *testing.T) datastore.DataSynchroniser {
+	s, err := h.opts.NewDataSynchroniser(t.Context(), t)
+	require.NoError(t, err)
+	return s
+}
+
+// advance lets d elapse for the leases.
+func (h *harness) advance(d time.Duration) {
+	if h.opts.Clock != nil {
+		h.opts.Clock.Advance(d)
+		return
+	}
+	time.Sleep(d)
+}
+
+// acquireEventually retries to acquire lockID until it is granted, or until
+// three leases elapsed. It tries once when the locks do not expire.
+func (h *harness) acquireEventually(t *testing.T, m *lockModel, s datastore.DataSynchroniser, lockID string) datastore.DataWriteLock {
+	step := h.opts.Lease / 10
+	for elapsed := time.Duration(0); ; elapsed += step {
+		lock, err := s.GetWriteLock(t.Context(), lockID)
+		if err == nil {
+			m.grant(lockID, lock)
+			return lock
+		}
+		require.ErrorIs(t, err, datastore.ErrLockAlreadyHeld)
+		if step <= 0 || elapsed >= 3*h.opts.Lease {
+			return nil
+		}
+		h.advance(step)
+	}
+}
+
+// runSafety applies a seeded sequence of acquisitions, releases, renewals
+// and lease expiries to a few locks shared by several nodes.
+func (h *harness) runSafety(t *testing.T) {
+	r := rand.New(rand.NewPCG(h.opts.Seed, h.opts.Seed))
+	nodes := make([]datastore.DataSynchroniser, 3)
+	for i := range nodes {
+		nodes[i] = h.newSynchroniser(t)
+	}
+	lockIDs := make([]string, 4)
+	prefix := "harnessSafety" + randomString(8)
+	for i := range lockIDs {
+		lockIDs[i] = fmt.Sprintf("%s%02d", prefix, i)
+	}
+	m := newLockModel(t)
+	defer m.releaseAll()
+
+	for step := range h.opts.Steps {
+		m.context = fmt.Sprintf("seed %d, step %d", h.opts.Seed, step)
+		lockID := lockIDs[r.IntN(len(lockIDs))]
+		switch r.IntN(4) {
+		case 0:
+			s := nodes[r.IntN(len(nodes))]
+			lock, err := s.GetWriteLock(t.Context(), lockID)
+			switch {
+			case err == nil:
+				m.grant(lockID, lock)
+			case errors.Is(err, datastore.ErrLockAlreadyHeld):
+				assert.NotNil(t, m.holder(lockID), "%s: the free lock %s was refused", m.context, lockID)
+			default:
+				require.NoError(t, err, m.context)
+			}
+		case 1:
+			if lock := m.holder(lockID); lock != nil {
+				if err := lock.Unlock(); err != nil {
+					assert.ErrorIs(t, err, datastore.ErrLockLost, m.context)
+				}
+				assert.True(t, lock.Unlocked(), "%s: the released lock %s is still held", m.context, lockID)
+			}
+		case 2:
+			if lock, ok := m.holder(lockID).(datastore.RenewableLock); ok {
+				if err := lock.Renew(t.Context()); err != nil {
+					assert.ErrorIs(t, err, datastore.ErrLockLost, m.context)
+				}
+			}
+		case 3:
+			if h.opts.Clock != nil && h.opts.Lease > 0 {
+				h.opts.Clock.Advance(h.opts.Lease / 4)
+			}
+		}
+	}
+}
+
+// runHolderCrash checks that the lock of a dead holder is taken over, once
+// its lease expired when the locks expire, and not before the holder lost
+// it.
+func (h *harness) runHolderCrash(t *testing.T) {
+	crashed, other := h.newSynchroniser(t), h.newSynchroniser(t)
+	lockID := "harnessCrash" + randomString(8)
+	m := newLockModel(t)
+	lock, err := crashed.GetWriteLock(t.Context(), lockID)
+	require.NoError(t, err)
+	m.grant(lockID, lock)
+
+	h.opts.Crash(t, crashed, lock)
+	taken := h.acquireEventually(t, m, other, lockID)
+	require.NotNil(t, taken, "the lock of the crashed holder was never released")
+	assertLost(t, lock)
+	require.NoError(t, taken.Unlock())
+}
+
+// runPartition checks that a partitioned holder gives its lock up before it
+// is granted to another node, and cannot release it once the partition
+// heals.
+func (h *harness) runPartition(t *testing.T) {
+	isolated, other := h.newSynchroniser(t), h.newSynchroniser(t)
+	lockID := "harnessPartition" + randomString(8)
+	m := newLockModel(t)
+	lock, err := isolated.GetWriteLock(t.Context(), lockID)
+	require.NoError(t, err)
+	m.grant(lockID, lock)
+
+	heal := h.opts.Partition(t, isolated)
+	taken := h.acquireEventually(t, m, other, lockID)
+	heal()
+	require.NotNil(t, taken, "the lock of the partitioned holder was never released")
+	assertLost(t, lock)
+	assert.ErrorIs(t, lock.Unlock(), datastore.ErrLockLost)
+
+	_, err = isolated.GetWriteLock(t.Context(), lockID)
+	assert.ErrorIs(t, err, datastore.ErrLockAlreadyHeld, "the healed node took the lock back")
+	assertHeld(t, taken)
+	require.NoError(t, taken.Unlock())
+	relocked := h.acquireEventually(t, m, isolated, lockID)
+	require.NotNil(t, relocked, "the healed node cannot acquire the lock")
+	require.NoError(t, relocked.Unlock())
+}
+
+// runSlowRefresh checks that refreshes slowed down well within the lease
+// keep the lock, and that refreshes slower than the lease lose it before it
+// is granted to another node.
+func (h *harness) runSlowRefresh(t *testing.T) {
+	slow, other := h.newSynchroniser(t), h.newSynchroniser(t)
+	lockID := "harnessSlow" + randomString(8)
+	m := newLockModel(t)
+	lock, err := slow.GetWriteLock(t.Context(), lockID)
+	require.NoError(t, err)
+	m.grant(lockID, lock)
+
+	restore := h.opts.SlowRefresh(t, slow, h.opts.Lease/20)
+	for elapsed := time.Duration(0); elapsed < 2*h.opts.Lease; elapsed += h.opts.Lease / 4 {
+		h.advance(h.opts.Lease / 4)
+		assertHeld(t, lock)
+		_, err := other.GetWriteLock(t.Context(), lockID)
+		require.ErrorIs(t, err, datastore.ErrLockAlreadyHeld, "the lock was granted while refreshed")
+	}
+	restore()
+
+	restore = h.opts.SlowRefresh(t, slow, 3*h.opts.Lease)
+	taken := h.acquireEventually(t, m, other, lockID)
+	restore()
+	require.NotNil(t, taken, "the lock which could not be refreshed was never released")
+	assertLost(t, lock)
+	assert.ErrorIs(t, lock.Unlock(), datastore.ErrLockLost)
+	require.NoError(t, taken.Unlock())
+}
+
+// lockModel records the write locks granted by the synchronisers under
+// test, and checks that a lock is never granted while its former holder has
+// neither released nor lost it.
+type lockModel struct {
+	t *testing.T
+	// context describes the current operation in the failures
+	context string
+	held    map[string]datastore.DataWriteLock
+}
+
+func newLockModel(t *testing.T) *lockModel {
+	return &lockModel{t: t, context: t.Name(), held: make(map[string]datastore.DataWriteLock)}
+}
+
+func (m *lockModel) grant(lockID string, lock datastore.DataWriteLock) {
+	if prev := m.holder(lockID); prev != nil {
+		m.t.Errorf("%s: %s was granted while another holder still holds it", m.context, lockID)
+	}
+	assertHeld(m.t, lock)
+	m.held[lockID] = lock
+}
+
+// holder returns the lock held on lockID, nil if it was released or lost.
+func (m *lockModel) holder(lockID string) datastore.DataWriteLock {
+	lock := m.held[lockID]
+	if lock == nil || lock.Unlocked() {
+		return nil
+	}
+	return lock
+}
+
+func (m *lockModel) releaseAll() {
+	for lockID := range m.held {
+		if lock := m.holder(lockID); lock != nil {
+			lock.Unlock()
+		}
+	}
+}
+
+func assertHeld(t *testing.T, lock datastore.DataWriteLock) {
+	t.Helper()
+	assert.False(t, lock.Unlocked(), "the lock is released")
+	if losable, ok := lock.(datastore.LosableLock); ok {
+		select {
+		case <-losable.Lost():
+			t.Error("the lock is lost")
+		default:
+		}
+	}
+}
+
+func assertLost(t *testing.T, lock datastore.DataWriteLock) {
+	t.Helper()
+	assert.True(t, lock.Unlocked(), "the lock is still held")
+	if losable, ok := lock.(datastore.LosableLock); ok {
+		select {
+		case <-losable.Lost():
+		default:
+			t.Error("the lock was not signalled lost")
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/slawo/go-cache/datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type InspectionTestsOpts struct {
+	NewDataSynchroniser func(ctx context.Context, t *testing.T) (datastore.InspectableDataSynchroniser, error)
+	// LostTimeout is how long the holders of a lock released by force may
+	// take to notice the loss, 10 seconds by default.
+	LostTimeout time.Duration
+}
+
+// RunInspectionTests checks the description and the forced release of the
+// locks.
+func RunInspectionTests(t *testing.T, opts InspectionTestsOpts) {
+	require.NotNil(t, opts.NewDataSynchroniser, "NewDataSynchroniser function must be provided")
+	if opts.LostTimeout <= 0 {
+		opts.LostTimeout = 10 * time.Second
+	}
+	newSynchroniser := func(t *testing.T) datastore.InspectableDataSynchroniser {
+		s, err := opts.NewDataSynchroniser(t.Context(), t)
+		require.NoError(t, err)
+		return s
+	}
+	t.Run("NotHeld", func(t *testing.T) {
+		t.Parallel()
+		s := newSynchroniser(t)
+		lockID := "inspectFree" + randomString(8)
+		_, err := s.GetLockInfo(t.Context(), lockID)
+		assert.ErrorIs(t, err, datastore.ErrLockNotHeld)
+		assert.ErrorIs(t, s.ForceUnlock(t.Context(), lockID), datastore.ErrLockNotHeld)
+		_, err = s.GetLockInfo(t.Context(), "")
+		assert.ErrorIs(t, err, datastore.ErrInvalidLockID)
+	})
+	t.Run("Holder", func(t *testing.T) {
+		t.Parallel()
+		s := newSynchroniser(t)
+		lockID := "inspectHolder" + randomString(8)
+		before := time.Now()
+		lock, err := s.GetWriteLock(datastore.WithLockPurpose(t.Context(), "fill"), lockID)
+		require.NoError(t, err)
+		defer lock.Unlock()
+
+		locks, err := s.GetLockInfo(t.Context(), lockID)
+		require.NoError(t, err)
+		require.Len(t, locks, 1)
+		info := locks[0]
+		assert.Equal(t, lockID, info.LockID)
+		assert.False(t, info.Shared)
+		assert.NotEmpty(t, info.Holder.ID)
+		assert.NotEmpty(t, info.Holder.SynchroniserID)
+		assert.Equal(t, "fill", info.Holder.Purpose)
+		hostname, _ := os.Hostname()
+		assert.Equal(t, hostname, info.Holder.Hostname)
+		assert.WithinRange(t, info.Holder.AcquiredAt, before.Add(-time.Second), time.Now().Add(time.Second))
+		assert.GreaterOrEqual(t, info.TTL, time.Duration(0))
+
+		require.NoError(t, lock.Unlock())
+		_, err = s.GetLockInfo(t.Context(), lockID)
+		assert.ErrorIs(t, err, datastore.ErrLockNotHeld)
+	})
+	t.Run("ReadLockHolders", func(t *testing.T) {
+		t.Parallel()
+		s := newSynchroniser(t)
+		rw, ok := s.(datastore.RWDataSynchroniser)
+		if !ok {
+			t.Skip("the synchroniser does not hand out read locks")
+		}
+		lockID := "inspectReaders" + randomString(8)
+		for range 2 {
+			lock, err := rw.GetReadLock(t.Context(), lockID)
+			require.NoError(t, err)
+			defer lock.Unlock()
+		}
+		locks, err := s.GetLockInfo(t.Context(), lockID)
+		require.NoError(t, err)
+		require.Len(t, locks, 2)
+		assert.True(t, locks[0].Shared)
+		assert.True(t, locks[1].Shared)
+		assert.NotEqual(t, locks[0].Holder.ID, locks[1].Holder.ID)
+	})
+	t.Run("ForceUnlock", func(t *testing.T) {
+		t.Parallel()
+		s := newSynchroniser(t)
+		lockID := "inspectForce" + randomString(8)
+		lock, err := s.GetWriteLock(t.Context(), lockID)
+		require.NoError(t, err)
+
+		require.NoError(t, s.ForceUnlock(t.Context(), lockID))
+		other, err := s.GetWriteLock(t.Context(), lockID)
+		require.NoError(t, err)
+		losable, ok := lock.(datastore.LosableLock)
+		require.True(t, ok, "locks released by force must implement datastore.LosableLock")
+		select {
+		case <-losable.Lost():
+		case <-time.After(opts.LostTimeout):
+			t.Fatal("the holder did not lose the lock")
+		}
+		assert.True(t, lock.Unlocked())
+		assert.ErrorIs(t, lock.Unlock(), datastore.ErrLockLost)
+
+		locks, err := s.GetLockInfo(t.Context(), lockID)
+		require.NoError(t, err)
+		require.Len(t, locks, 1)
+		require.NoError(t, other.Unlock())
+	})
+}
@@ -2,10 +2,13 @@ package tests
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/slawo/go-cache/datastore"
 	"github.com/stretchr/testify/assert"
@@ -282,6 +285,124 @@ func RunBaseIOProviderTests(t *testing.T, opts BaseIOProviderTestsOpts) {
 			})
 		}
 	})
+	t.Run("DataManager", func(t *testing.T) {
+		t.Parallel()
+		p, err := opts.NewIOProvider(context.Background(), t)
+		require.NoError(t, err)
+		m, ok := p.(datastore.DataManager)
+		if !ok {
+			t.Skip("provider does not implement datastore.DataManager")
+		}
+		runDataManagerTests(t, p, m)
+	})
+	t.Run("Context", func(t *testing.T) {
+		t.Parallel()
+		p, err := opts.NewIOProvider(context.Background(), t)
+		require.NoError(t, err)
+		runContextTests(t, p)
+	})
+	t.Run("TxWriter", func(t *testing.T) {
+		t.Parallel()
+		p, err := opts.NewIOProvider(context.Background(), t)
+		require.NoError(t, err)
+		tp, ok := p.(datastore.TxDataIOProvider)
+		if !ok {
+			t.Skip("provider does not implement datastore.TxDataIOProvider")
+		}
+		runTxWriterTests(t, tp)
+	})
+}
+
+func runDataManagerTests(t *testing.T, p datastore.DataIOProvider, m datastore.DataManager) {
+	t.Run("InvalidID", func(t *testing.T) {
+		_, err := m.Stat(t.Context(), "")
+		assert.Error(t, err)
+		assert.Error(t, m.Delete(t.Context(), ""))
+		assert.Error(t, m.Truncate(t.Context(), "", 0))
+	})
+	t.Run("MissingFile", func(t *testing.T) {
+		fn := generateFileName()
+		_, err := m.Stat(t.Context(), fn)
+		assert.ErrorIs(t, err, datastore.ErrFileNotFound)
+		assert.ErrorIs(t, m.Delete(t.Context(), fn), datastore.ErrFileNotFound)
+		assert.ErrorIs(t, m.Truncate(t.Context(), fn, 10), datastore.ErrFileNotFound)
+
+		// reading a missing file does not create it
+		reader, err := p.GetReaderAt(t.Context(), fn, 0)
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		_, err = m.Stat(t.Context(), fn)
+		assert.ErrorIs(t, err, datastore.ErrFileNotFound)
+	})
+	t.Run("StatListDelete", func(t *testing.T) {
+		before := time.Now().Add(-time.Second)
+		names := []string{generateFileName(), generateFileName(), generateFileName()}
+		for i, fn := range names {
+			writeTestFile(t, p, fn, make([]byte, 10*(i+1)))
+		}
+		for i, fn := range names {
+			info, err := m.Stat(t.Context(), fn)
+			require.NoError(t, err)
+			assert.Equal(t, fn, info.FileId)
+			assert.Equal(t, int64(10*(i+1)), info.Size)
+			assert.True(t, info.ModifiedAt.After(before))
+		}
+		list, err := m.List(t.Context())
+		require.NoError(t, err)
+		for _, fn := range names {
+			assert.Contains(t, list, fn)
+		}
+		assert.True(t, slices.IsSorted(list))
+
+		require.NoError(t, m.Delete(t.Context(), names[1]))
+		_, err = m.Stat(t.Context(), names[1])
+		assert.ErrorIs(t, err, datastore.ErrFileNotFound)
+		list, err = m.List(t.Context())
+		require.NoError(t, err)
+		assert.NotContains(t, list, names[1])
+		assert.Contains(t, list, names[0])
+		assert.Equal(t, []byte{}, readTestFile(t, p, names[1]))
+	})
+	t.Run("Truncate", func(t *testing.T) {
+		fn := generateFileName()
+		writeTestFile(t, p, fn, []byte("partially written file"))
+		require.NoError(t, m.Truncate(t.Context(), fn, 9))
+		assert.Equal(t, []byte("partially"), readTestFile(t, p, fn))
+		info, err := m.Stat(t.Context(), fn)
+		require.NoError(t, err)
+		assert.Equal(t, int64(9), info.Size)
+
+		require.NoError(t, m.Truncate(t.Context(), fn, 12))
+		assert.Equal(t, []byte("partially\x00\x00\x00"), readTestFile(t, p, fn))
+		assert.Error(t, m.Truncate(t.Context(), fn, -1))
+	})
+}
+
+func writeTestFile(t *testing.T, p datastore.DataIOProvider, fn string, data []byte) {
+	t.Helper()
+	writer, err := p.GetWriterAt(t.Context(), fn, 0)
+	require.NoError(t, err)
+	n, err := writer.Write(t.Context(), data)
+	require.NoError(t, err)
+	require.Equal(t, len(data), n)
+	require.NoError(t, writer.Close())
+}
+
+func readTestFile(t *testing.T, p datastore.DataIOProvider, fn string) []byte {
+	t.Helper()
+	reader, err := p.GetReaderAt(t.Context(), fn, 0)
+	require.NoError(t, err)
+	defer reader.Close()
+	data := []byte{}
+	buf := make([]byte, 1024)
+	for {
+		n, err := reader.Read(t.Context(), buf)
+		data = append(data, buf[:n]...)
+		if errors.Is(err, io.EOF) {
+			return data
+		}
+		require.NoError(t, err)
+	}
 }
 
 func generateFileName() string {
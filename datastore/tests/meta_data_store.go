@@ -0,0 +1,260 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slawo/go-cache/datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type MetaDataStoreTestsOpts struct {
+	NewMetaDataStore func(ctx context.Context, t *testing.T) (datastore.MetaDataStore, error)
+}
+
+// RunMetaDataStoreTests checks that a MetaDataStore implementation behaves
+// as defined by the datastore.MetaDataStore interface.
+func RunMetaDataStoreTests(t *testing.T, opts MetaDataStoreTestsOpts) {
+	require.NotNil(t, opts.NewMetaDataStore, "NewMetaDataStore function must be provided")
+	newStore := func(t *testing.T) datastore.MetaDataStore {
+		s, err := opts.NewMetaDataStore(context.Background(), t)
+		require.NoError(t, err)
+		require.NotNil(t, s)
+		return s
+	}
+
+	t.Run("InvalidFileID", func(t *testing.T) {
+		t.Parallel()
+		s := newStore(t)
+		_, err := s.GetFileMeta(t.Context(), " 	")
+		assert.ErrorIs(t, err, datastore.ErrInvalidFileID)
+		assert.ErrorIs(t, s.SaveFileMeta(t.Context(), &datastore.FileMeta{FileId: ""}), datastore.ErrInvalidFileID)
+		assert.ErrorIs(t, s.DeleteFileMeta(t.Context(), ""), datastore.ErrInvalidFileID)
+		_, err = s.GetFileCompletionData(t.Context(), "")
+		assert.ErrorIs(t, err, datastore.ErrInvalidFileID)
+		assert.ErrorIs(t, s.SaveFileCompletionData(t.Context(), &datastore.FileCompletionData{FileId: " "}), datastore.ErrInvalidFileID)
+		_, err = s.MarkPartCompleted(t.Context(), "", 0)
+		assert.ErrorIs(t, err, datastore.ErrInvalidFileID)
+	})
+	t.Run("NilData", func(t *testing.T) {
+		t.Parallel()
+		s := newStore(t)
+		assert.Error(t, s.SaveFileMeta(t.Context(), nil))
+		assert.Error(t, s.SaveFileCompletionData(t.Context(), nil))
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		t.Parallel()
+		s := newStore(t)
+		fileId := generateFileName()
+		m, err := s.GetFileMeta(t.Context(), fileId)
+		assert.ErrorIs(t, err, datastore.ErrFileNotFound)
+		assert.Nil(t, m)
+		c, err := s.GetFileCompletionData(t.Context(), fileId)
+		assert.ErrorIs(t, err, datastore.ErrFileNotFound)
+		assert.Nil(t, c)
+		c, err = s.MarkPartCompleted(t.Context(), fileId, 0)
+		assert.ErrorIs(t, err, datastore.ErrFileNotFound)
+		assert.Nil(t, c)
+		assert.NoError(t, s.DeleteFileMeta(t.Context(), fileId))
+	})
+	t.Run("FileMetaRoundTrip", func(t *testing.T) {
+		t.Parallel()
+		s := newStore(t)
+		meta := newTestFileMeta(generateFileName())
+		require.NoError(t, s.SaveFileMeta(t.Context(), meta))
+
+		got, err := s.GetFileMeta(t.Context(), meta.FileId)
+		require.NoError(t, err)
+		AssertFileMetaEqual(t, meta, got)
+
+		meta.FileSize = 2048
+		meta.Complete = false
+		meta.ExpiresAt = time.Time{}
+		require.NoError(t, s.SaveFileMeta(t.Context(), meta))
+		got, err = s.GetFileMeta(t.Context(), meta.FileId)
+		require.NoError(t, err)
+		AssertFileMetaEqual(t, meta, got)
+	})
+	t.Run("FileMetaIsCopied", func(t *testing.T) {
+		t.Parallel()
+		s := newStore(t)
+		meta := newTestFileMeta(generateFileName())
+		require.NoError(t, s.SaveFileMeta(t.Context(), meta))
+		meta.Checksum = "modified"
+
+		got, err := s.GetFileMeta(t.Context(), meta.FileId)
+		require.NoError(t, err)
+		assert.Equal(t, "sha256:abc123", got.Checksum)
+		got.Checksum = "modified"
+		got, err = s.GetFileMeta(t.Context(), meta.FileId)
+		require.NoError(t, err)
+		assert.Equal(t, "sha256:abc123", got.Checksum)
+	})
+	t.Run("CompletionDataRoundTrip", func(t *testing.T) {
+		t.Parallel()
+		s := newStore(t)
+		completion := newTestCompletionData(generateFileName())
+		require.NoError(t, s.SaveFileCompletionData(t.Context(), completion))
+
+		got, err := s.GetFileCompletionData(t.Context(), completion.FileId)
+		require.NoError(t, err)
+		AssertCompletionDataEqual(t, completion, got)
+
+		completion.Parts[0] = 0xff
+		got.Parts[0] = 0xff
+		got, err = s.GetFileCompletionData(t.Context(), completion.FileId)
+		require.NoError(t, err)
+		assert.False(t, got.IsPartCompleted(0), "stored parts should be copied")
+	})
+	t.Run("DeleteFileMeta", func(t *testing.T) {
+		t.Parallel()
+		s := newStore(t)
+		fileId := generateFileName()
+		require.NoError(t, s.SaveFileMeta(t.Context(), newTestFileMeta(fileId)))
+		require.NoError(t, s.SaveFileCompletionData(t.Context(), newTestCompletionData(fileId)))
+		other := newTestFileMeta(generateFileName())
+		require.NoError(t, s.SaveFileMeta(t.Context(), other))
+
+		require.NoError(t, s.DeleteFileMeta(t.Context(), fileId))
+		_, err := s.GetFileMeta(t.Context(), fileId)
+		assert.ErrorIs(t, err, datastore.ErrFileNotFound)
+		_, err = s.GetFileCompletionData(t.Context(), fileId)
+		assert.ErrorIs(t, err, datastore.ErrFileNotFound)
+		_, err = s.GetFileMeta(t.Context(), other.FileId)
+		assert.NoError(t, err)
+	})
+	t.Run("MarkPartCompleted", func(t *testing.T) {
+		t.Parallel()
+		s := newStore(t)
+		completion := &datastore.FileCompletionData{
+			FileId:     generateFileName(),
+			PartSize:   1024,
+			TotalParts: 10,
+		}
+		require.NoError(t, s.SaveFileCompletionData(t.Context(), completion))
+
+		got, err := s.MarkPartCompleted(t.Context(), completion.FileId, 9)
+		require.NoError(t, err)
+		assert.True(t, got.IsPartCompleted(9))
+		assert.Equal(t, 1, got.PartsCompleted)
+		assert.Equal(t, int64(1024), got.PartSize)
+
+		got, err = s.MarkPartCompleted(t.Context(), completion.FileId, 9)
+		require.NoError(t, err)
+		assert.Equal(t, 1, got.PartsCompleted, "marking a part twice should not count it twice")
+
+		_, err = s.MarkPartCompleted(t.Context(), completion.FileId, 10)
+		assert.ErrorIs(t, err, datastore.ErrInvalidPart)
+		_, err = s.MarkPartCompleted(t.Context(), completion.FileId, -1)
+		assert.ErrorIs(t, err, datastore.ErrInvalidPart)
+
+		unknown := &datastore.FileCompletionData{FileId: "unknownParts.bin", PartSize: 1024}
+		require.NoError(t, s.SaveFileCompletionData(t.Context(), unknown))
+		got, err = s.MarkPartCompleted(t.Context(), unknown.FileId, datastore.MaxParts-1)
+		require.NoError(t, err)
+		assert.Equal(t, 1, got.PartsCompleted)
+		_, err = s.MarkPartCompleted(t.Context(), unknown.FileId, datastore.MaxParts)
+		assert.ErrorIs(t, err, datastore.ErrInvalidPart, "the parts of a file of unknown size are bounded")
+
+		got, err = s.GetFileCompletionData(t.Context(), completion.FileId)
+		require.NoError(t, err)
+		assert.Equal(t, 1, got.PartsCompleted)
+		assert.True(t, got.IsPartCompleted(9))
+		assert.False(t, got.IsPartCompleted(0))
+	})
+	t.Run("MarkPartCompletedConcurrently", func(t *testing.T) {
+		t.Parallel()
+		s := newStore(t)
+		const parts = 64
+		completion := &datastore.FileCompletionData{
+			FileId:     generateFileName(),
+			PartSize:   1024,
+			TotalParts: parts,
+		}
+		require.NoError(t, s.SaveFileCompletionData(t.Context(), completion))
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < parts; i++ {
+			wg.Add(1)
+			go func(part int) {
+				defer wg.Done()
+				_, err := s.MarkPartCompleted(context.Background(), completion.FileId, part)
+				assert.NoError(t, err, fmt.Sprintf("part %d", part))
+			}(i)
+		}
+		wg.Wait()
+
+		got, err := s.GetFileCompletionData(t.Context(), completion.FileId)
+		require.NoError(t, err)
+		assert.Equal(t, parts, got.PartsCompleted)
+		assert.True(t, got.Completed())
+	})
+}
+
+// AssertFileMetaEqual compares two FileMeta, times are compared with
+// time.Time.Equal as stores are not expected to keep locations.
+func AssertFileMetaEqual(t *testing.T, expected, actual *datastore.FileMeta) {
+	t.Helper()
+	require.NotNil(t, actual)
+	assert.Equal(t, expected.FileId, actual.FileId)
+	assert.Equal(t, expected.FileSize, actual.FileSize)
+	assert.Equal(t, expected.Checksum, actual.Checksum)
+	assert.Equal(t, expected.ContentType, actual.ContentType)
+	assert.Equal(t, expected.SourceURI, actual.SourceURI)
+	assertTimeEqual(t, expected.CreatedAt, actual.CreatedAt, "CreatedAt")
+	assertTimeEqual(t, expected.ModifiedAt, actual.ModifiedAt, "ModifiedAt")
+	assertTimeEqual(t, expected.LastAccessedAt, actual.LastAccessedAt, "LastAccessedAt")
+	assertTimeEqual(t, expected.ExpiresAt, actual.ExpiresAt, "ExpiresAt")
+	assert.Equal(t, expected.Complete, actual.Complete)
+}
+
+// AssertCompletionDataEqual compares two FileCompletionData.
+func AssertCompletionDataEqual(t *testing.T, expected, actual *datastore.FileCompletionData) {
+	t.Helper()
+	require.NotNil(t, actual)
+	assert.Equal(t, expected.FileId, actual.FileId)
+	assert.Equal(t, expected.PartSize, actual.PartSize)
+	assert.Equal(t, expected.TotalParts, actual.TotalParts)
+	assert.Equal(t, expected.PartsCompleted, actual.PartsCompleted)
+	for i := 0; i < len(expected.Parts)*8 || i < len(actual.Parts)*8; i++ {
+		assert.Equal(t, expected.IsPartCompleted(i), actual.IsPartCompleted(i), "part %d", i)
+	}
+	assertTimeEqual(t, expected.UpdatedAt, actual.UpdatedAt, "UpdatedAt")
+}
+
+func assertTimeEqual(t *testing.T, expected, actual time.Time, field string) {
+	t.Helper()
+	assert.True(t, expected.Equal(actual), "%s: expected %v, got %v", field, expected, actual)
+}
+
+func newTestFileMeta(fileId string) *datastore.FileMeta {
+	now := time.Now()
+	return &datastore.FileMeta{
+		FileId:         fileId,
+		FileSize:       1024,
+		Checksum:       "sha256:abc123",
+		ContentType:    "application/octet-stream",
+		SourceURI:      "https://example.com/files/" + fileId,
+		CreatedAt:      now.Add(-time.Hour),
+		ModifiedAt:     now.Add(-time.Minute),
+		LastAccessedAt: now,
+		ExpiresAt:      now.Add(24 * time.Hour),
+		Complete:       true,
+	}
+}
+
+func newTestCompletionData(fileId string) *datastore.FileCompletionData {
+	c := &datastore.FileCompletionData{
+		FileId:     fileId,
+		PartSize:   512,
+		TotalParts: 12,
+		UpdatedAt:  time.Now(),
+	}
+	c.SetPartCompleted(1)
+	c.SetPartCompleted(10)
+	return c
+}
@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/slawo/go-cache/datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type SemaphoreTestsOpts struct {
+	// NewDataSemaphore returns the semaphores sharing the permits, either
+	// the same instance or instances connected to the same backend.
+	NewDataSemaphore func(ctx context.Context, t *testing.T) (datastore.DataSemaphore, error)
+	// Crash simulates the death of the holders of the permits of s, the
+	// HolderDeath test is skipped when it is nil.
+	Crash func(t *testing.T, s datastore.DataSemaphore)
+	// LeaseTimeout is how long the permits of a dead holder may take to be
+	// released, 10 seconds by default.
+	LeaseTimeout time.Duration
+}
+
+// RunSemaphoreTests checks the acquisition and the release of the permits
+// of a DataSemaphore.
+func RunSemaphoreTests(t *testing.T, opts SemaphoreTestsOpts) {
+	require.NotNil(t, opts.NewDataSemaphore, "NewDataSemaphore function must be provided")
+	if opts.LeaseTimeout <= 0 {
+		opts.LeaseTimeout = 10 * time.Second
+	}
+	newSemaphore := func(t *testing.T) datastore.DataSemaphore {
+		s, err := opts.NewDataSemaphore(t.Context(), t)
+		require.NoError(t, err)
+		return s
+	}
+	t.Run("InvalidArguments", func(t *testing.T) {
+		t.Parallel()
+		s := newSemaphore(t)
+		_, err := s.Acquire(t.Context(), "", 1)
+		assert.ErrorIs(t, err, datastore.ErrInvalidLockID)
+		_, err = s.Acquire(t.Context(), "semInvalid"+randomString(8), 0)
+		assert.ErrorIs(t, err, datastore.ErrInvalidPermits)
+		_, err = s.Wait(t.Context(), "semInvalid"+randomString(8), -1)
+		assert.ErrorIs(t, err, datastore.ErrInvalidPermits)
+	})
+	t.Run("Permits", func(t *testing.T) {
+		t.Parallel()
+		s := newSemaphore(t)
+		key := "semPermits" + randomString(8)
+		permits := make([]datastore.SemaphorePermit, 3)
+		for i := range permits {
+			p, err := s.Acquire(t.Context(), key, 3)
+			require.NoError(t, err)
+			permits[i] = p
+		}
+		_, err := s.Acquire(t.Context(), key, 3)
+		assert.ErrorIs(t, err, datastore.ErrNoPermitAvailable)
+
+		require.NoError(t, permits[0].Release())
+		assert.True(t, permits[0].Released())
+		p, err := s.Acquire(t.Context(), key, 3)
+		require.NoError(t, err)
+		require.NoError(t, p.Release())
+		for _, p := range permits[1:] {
+			assert.False(t, p.Released())
+			require.NoError(t, p.Release())
+		}
+		// the keys are independent
+		other, err := s.Acquire(t.Context(), "semOther"+randomString(8), 1)
+		require.NoError(t, err)
+		require.NoError(t, other.Release())
+	})
+	t.Run("WaitsForRelease", func(t *testing.T) {
+		t.Parallel()
+		s := newSemaphore(t)
+		key := "semWait" + randomString(8)
+		held, err := s.Acquire(t.Context(), key, 1)
+		require.NoError(t, err)
+
+		acquired := make(chan datastore.SemaphorePermit)
+		go func() {
+			p, err := s.Wait(t.Context(), key, 1)
+			assert.NoError(t, err)
+			acquired <- p
+		}()
+		select {
+		case <-acquired:
+			t.Fatal("the permit was acquired while none was available")
+		case <-time.After(100 * time.Millisecond):
+		}
+		require.NoError(t, held.Release())
+		select {
+		case p := <-acquired:
+			require.NotNil(t, p)
+			require.NoError(t, p.Release())
+		case <-time.After(10 * time.Second):
+			t.Fatal("the permit was not acquired after the release")
+		}
+	})
+	t.Run("ContextExpires", func(t *testing.T) {
+		t.Parallel()
+		s := newSemaphore(t)
+		key := "semContext" + randomString(8)
+		held, err := s.Acquire(t.Context(), key, 1)
+		require.NoError(t, err)
+		defer held.Release()
+
+		ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+		defer cancel()
+		p, err := s.Wait(ctx, key, 1)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Nil(t, p)
+	})
+	t.Run("ConcurrentHolders", func(t *testing.T) {
+		t.Parallel()
+		key := "semConcurrent" + randomString(8)
+		const permits = 3
+		var holders, maxHolders atomic.Int32
+		var wg sync.WaitGroup
+		for range 4 {
+			s := newSemaphore(t)
+			for range 5 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					p, err := s.Wait(t.Context(), key, permits)
+					if !assert.NoError(t, err) {
+						return
+					}
+					n := holders.Add(1)
+					for {
+						m := maxHolders.Load()
+						if n <= m || maxHolders.CompareAndSwap(m, n) {
+							break
+						}
+					}
+					time.Sleep(5 * time.Millisecond)
+					holders.Add(-1)
+					assert.NoError(t, p.Release())
+				}()
+			}
+		}
+		wg.Wait()
+		assert.LessOrEqual(t, maxHolders.Load(), int32(permits))
+		assert.Positive(t, maxHolders.Load())
+	})
+	t.Run("HolderDeath", func(t *testing.T) {
+		if opts.Crash == nil {
+			t.Skip("the holders cannot be crashed")
+		}
+		t.Parallel()
+		key := "semDeath" + randomString(8)
+		dead := newSemaphore(t)
+		_, err := dead.Acquire(t.Context(), key, 1)
+		require.NoError(t, err)
+		opts.Crash(t, dead)
+
+		s := newSemaphore(t)
+		ctx, cancel := context.WithTimeout(t.Context(), opts.LeaseTimeout)
+		defer cancel()
+		p, err := s.Wait(ctx, key, 1)
+		require.NoError(t, err, "the permit of the dead holder was not released")
+		require.NoError(t, p.Release())
+	})
+}
@@ -4,8 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strconv"
-	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -16,10 +14,16 @@ import (
 )
 
 type ParallelLockTestsOpts struct {
-	MaxSyncs            int
-	MaxTries            int
-	MaxLocks            int
+	MaxSyncs int
+	MaxTries int
+	MaxLocks int
+	// WriterPreference tells that the synchronisers refuse read locks while
+	// a writer is waiting.
+	WriterPreference    bool
 	NewDataSynchroniser func(ctx context.Context, t *testing.T) (datastore.DataSynchroniser, error)
+	// Waiting returns the number of callers of s queued for lockID. The
+	// WaitingWriter test is skipped when it is nil.
+	Waiting func(t *testing.T, s datastore.DataSynchroniser, lockID string) int
 }
 
 func RunParallelLockTests(t *testing.T, opts ParallelLockTestsOpts) {
@@ -40,12 +44,14 @@ func RunParallelLockTests(t *testing.T, opts ParallelLockTestsOpts) {
 		syncs = append(syncs, s)
 	}
 
-	errs := make(chan error)
-	rets := make(chan struct {
-		syncIdx int
-		idx     int
-		lock    datastore.DataWriteLock
-	})
+	// attempt is the result of one acquisition, the lock index is carried
+	// along rather than recovered from the error
+	type attempt struct {
+		idx  int
+		lock datastore.DataWriteLock
+		err  error
+	}
+	attempts := make(chan attempt)
 
 	errsCt := make(map[int]int, opts.MaxLocks)
 	validCt := make(map[int]int, opts.MaxLocks)
@@ -55,28 +61,15 @@ func RunParallelLockTests(t *testing.T, opts ParallelLockTestsOpts) {
 	wgr.Add(1)
 	go func() {
 		defer wgr.Done()
-		for err := range errs {
-			if err != nil {
-				msg := err.Error()
-				if errors.Is(err, datastore.ErrLockAlreadyHeld) || strings.Contains(err.Error(), datastore.LockAlreadyHeldError) {
-					stid := err.Error()[24:29]
-					idx, err := strconv.Atoi(stid)
-					require.NoError(t, err)
-					errsCt[idx]++
-					fmt.Printf("Error on %s: %s, ct: %d\n", stid, msg, errsCt[idx])
-				}
-			}
-		}
-	}()
-
-	wgr.Add(1)
-	go func() {
-		defer wgr.Done()
-		for ret := range rets {
-			if ret.lock != nil {
-				fmt.Printf("Success on synchroniser: %02d, lock: %06d\n", ret.syncIdx, ret.idx)
-				validCt[ret.idx]++
-				locks = append(locks, ret.lock)
+		for a := range attempts {
+			switch {
+			case a.err == nil:
+				validCt[a.idx]++
+				locks = append(locks, a.lock)
+			case errors.Is(a.err, datastore.ErrLockAlreadyHeld):
+				errsCt[a.idx]++
+			default:
+				assert.NoError(t, a.err, "lock %d", a.idx)
 			}
 		}
 	}()
@@ -84,37 +77,23 @@ func RunParallelLockTests(t *testing.T, opts ParallelLockTestsOpts) {
 	wg := sync.WaitGroup{}
 	st := sync.RWMutex{}
 	st.Lock()
-	for i, s := range syncs {
+	for _, s := range syncs {
 		wg.Add(1)
-		go func(s datastore.DataSynchroniser, index int) {
+		go func(s datastore.DataSynchroniser) {
 			defer wg.Done()
 			st.RLock()
 			defer st.RUnlock()
 			for j := 0; j < opts.MaxLocks; j++ {
 				for i := 0; i < opts.MaxTries; i++ {
 					lock, err := s.GetWriteLock(context.Background(), fmt.Sprintf("multiTestKey%06d", j))
-					if err != nil {
-						errs <- fmt.Errorf("synchroniser: %02d, lock: %05d: %w", index, j, err)
-					} else {
-						rets <- struct {
-							syncIdx int
-							idx     int
-							lock    datastore.DataWriteLock
-						}{
-							syncIdx: index,
-							idx:     j,
-							lock:    lock,
-						}
-						time.Sleep(1 * time.Millisecond)
-					}
+					attempts <- attempt{idx: j, lock: lock, err: err}
 				}
 			}
-		}(s, i)
+		}(s)
 	}
 	st.Unlock()
 	wg.Wait()
-	close(rets)
-	close(errs)
+	close(attempts)
 	wgr.Wait()
 
 	for i := 0; i < opts.MaxLocks; i++ {
@@ -146,4 +125,250 @@ func RunParallelLockTests(t *testing.T, opts ParallelLockTestsOpts) {
 	}
 	st.Unlock()
 	wg.Wait()
+
+	rwSyncs := make([]datastore.RWDataSynchroniser, 0, len(syncs))
+	for _, s := range syncs {
+		if rw, ok := s.(datastore.RWDataSynchroniser); ok {
+			rwSyncs = append(rwSyncs, rw)
+		}
+	}
+	if len(rwSyncs) == len(syncs) {
+		t.Run("ReadLocks", func(t *testing.T) {
+			runParallelReadLockTests(t, rwSyncs, opts)
+		})
+	}
+}
+
+// runParallelReadLockTests checks that read locks are shared by every
+// synchroniser and exclude the write locks.
+func runParallelReadLockTests(t *testing.T, syncs []datastore.RWDataSynchroniser, opts ParallelLockTestsOpts) {
+	for j := 0; j < opts.MaxLocks; j++ {
+		lockID := fmt.Sprintf("multiTestReadKey%06d", j)
+		var (
+			mu    sync.Mutex
+			locks []datastore.DataReadLock
+			wg    sync.WaitGroup
+		)
+		for _, s := range syncs {
+			for i := 0; i < opts.MaxTries; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					lock, err := s.GetReadLock(context.Background(), lockID)
+					if assert.NoError(t, err) {
+						mu.Lock()
+						locks = append(locks, lock)
+						mu.Unlock()
+					}
+				}()
+			}
+		}
+		wg.Wait()
+		require.Len(t, locks, len(syncs)*opts.MaxTries, "every read lock is granted on %s", lockID)
+
+		for _, s := range syncs {
+			_, err := s.GetWriteLock(context.Background(), lockID)
+			assert.ErrorIs(t, err, datastore.ErrLockAlreadyHeld, "a write lock is refused while read locks are held")
+		}
+		for _, l := range locks[1:] {
+			require.NoError(t, l.Unlock())
+			assert.True(t, l.Unlocked())
+		}
+		_, err := syncs[0].GetWriteLock(context.Background(), lockID)
+		assert.ErrorIs(t, err, datastore.ErrLockAlreadyHeld, "a single read lock still excludes writers")
+		require.NoError(t, locks[0].Unlock())
+		<-locks[0].WaitUnlocked()
+
+		write, err := syncs[len(syncs)-1].GetWriteLock(context.Background(), lockID)
+		require.NoError(t, err)
+		for _, s := range syncs {
+			_, err := s.GetReadLock(context.Background(), lockID)
+			assert.ErrorIs(t, err, datastore.ErrLockAlreadyHeld, "a read lock is refused while a write lock is held")
+		}
+		require.NoError(t, write.Unlock())
+	}
+
+	t.Run("WaitingWriter", func(t *testing.T) {
+		if opts.Waiting == nil {
+			t.Skip("the waiters cannot be observed")
+		}
+		lockID := "multiTestWaitingWriter" + randomString(8)
+		read, err := syncs[0].GetReadLock(context.Background(), lockID)
+		require.NoError(t, err)
+
+		written := make(chan datastore.DataWriteLock)
+		go func() {
+			lock, err := syncs[len(syncs)-1].WaitWriteLock(context.Background(), lockID)
+			assert.NoError(t, err)
+			written <- lock
+		}()
+		waitQueued(t, func() int { return opts.Waiting(t, syncs[len(syncs)-1], lockID) }, 1)
+
+		second, err := syncs[0].GetReadLock(context.Background(), lockID)
+		if opts.WriterPreference {
+			assert.ErrorIs(t, err, datastore.ErrLockAlreadyHeld, "readers give way to a waiting writer")
+		} else {
+			require.NoError(t, err)
+			require.NoError(t, second.Unlock())
+		}
+		require.NoError(t, read.Unlock())
+		select {
+		case lock := <-written:
+			require.NoError(t, lock.Unlock())
+		case <-time.After(5 * time.Second):
+			t.Fatal("the writer did not acquire the lock once the readers left")
+		}
+	})
+}
+
+type WaitLockTestsOpts struct {
+	NewDataSynchroniser func(ctx context.Context, t *testing.T) (datastore.BlockingDataSynchroniser, error)
+	// Waiting returns the number of callers of s queued for lockID, the tests
+	// wait on it to order the waiters. The FIFO test is skipped when it is
+	// nil.
+	Waiting func(t *testing.T, s datastore.DataSynchroniser, lockID string) int
+}
+
+// RunWaitLockTests checks the blocking acquisition of write locks.
+func RunWaitLockTests(t *testing.T, opts WaitLockTestsOpts) {
+	require.NotNil(t, opts.NewDataSynchroniser, "NewDataSynchroniser function must be provided")
+	newSynchroniser := func(t *testing.T) datastore.BlockingDataSynchroniser {
+		s, err := opts.NewDataSynchroniser(t.Context(), t)
+		require.NoError(t, err)
+		return s
+	}
+	t.Run("InvalidID", func(t *testing.T) {
+		t.Parallel()
+		s := newSynchroniser(t)
+		lock, err := s.WaitWriteLock(t.Context(), "")
+		assert.ErrorIs(t, err, datastore.ErrInvalidLockID)
+		assert.Nil(t, lock)
+	})
+	t.Run("FreeLock", func(t *testing.T) {
+		t.Parallel()
+		s := newSynchroniser(t)
+		lockID := "waitFreeLock" + randomString(8)
+		lock, err := s.WaitWriteLock(t.Context(), lockID)
+		require.NoError(t, err)
+		_, err = s.GetWriteLock(t.Context(), lockID)
+		assert.ErrorIs(t, err, datastore.ErrLockAlreadyHeld)
+		require.NoError(t, lock.Unlock())
+	})
+	t.Run("FencingTokens", func(t *testing.T) {
+		t.Parallel()
+		s := newSynchroniser(t)
+		lockID := "waitFencing" + randomString(8)
+		var last uint64
+		for range 3 {
+			lock, err := s.WaitWriteLock(t.Context(), lockID)
+			require.NoError(t, err)
+			if losable, ok := lock.(datastore.LosableLock); ok {
+				select {
+				case <-losable.Lost():
+					t.Fatal("a held lock is not lost")
+				default:
+				}
+			}
+			fenced, ok := lock.(datastore.FencedLock)
+			if !ok {
+				require.NoError(t, lock.Unlock())
+				t.Skip("locks do not implement datastore.FencedLock")
+			}
+			assert.Greater(t, fenced.FencingToken(), last, "fencing tokens increase")
+			last = fenced.FencingToken()
+			require.NoError(t, lock.Unlock())
+		}
+	})
+	t.Run("WaitsForRelease", func(t *testing.T) {
+		t.Parallel()
+		s := newSynchroniser(t)
+		lockID := "waitRelease" + randomString(8)
+		held, err := s.GetWriteLock(t.Context(), lockID)
+		require.NoError(t, err)
+
+		acquired := make(chan datastore.DataWriteLock)
+		go func() {
+			lock, err := s.WaitWriteLock(t.Context(), lockID)
+			assert.NoError(t, err)
+			acquired <- lock
+		}()
+		if opts.Waiting != nil {
+			waitQueued(t, func() int { return opts.Waiting(t, s, lockID) }, 1)
+			select {
+			case <-acquired:
+				t.Fatal("the lock was acquired while held")
+			default:
+			}
+		}
+		require.NoError(t, held.Unlock())
+		select {
+		case lock := <-acquired:
+			require.NotNil(t, lock)
+			assert.False(t, lock.Unlocked())
+			require.NoError(t, lock.Unlock())
+		case <-time.After(5 * time.Second):
+			t.Fatal("the lock was not acquired after being released")
+		}
+	})
+	t.Run("ContextExpires", func(t *testing.T) {
+		t.Parallel()
+		s := newSynchroniser(t)
+		lockID := "waitExpires" + randomString(8)
+		held, err := s.GetWriteLock(t.Context(), lockID)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+		defer cancel()
+		lock, err := s.WaitWriteLock(ctx, lockID)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Nil(t, lock)
+
+		// the abandoned wait does not hold back other callers
+		require.NoError(t, held.Unlock())
+		lock, err = s.GetWriteLock(t.Context(), lockID)
+		require.NoError(t, err)
+		require.NoError(t, lock.Unlock())
+	})
+	t.Run("FIFO", func(t *testing.T) {
+		t.Parallel()
+		if opts.Waiting == nil {
+			t.Skip("the waiters cannot be observed")
+		}
+		s := newSynchroniser(t)
+		lockID := "waitFIFO" + randomString(8)
+		held, err := s.GetWriteLock(t.Context(), lockID)
+		require.NoError(t, err)
+
+		const waiters = 5
+		var (
+			mu    sync.Mutex
+			order []int
+			wg    sync.WaitGroup
+		)
+		for i := range waiters {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				lock, err := s.WaitWriteLock(t.Context(), lockID)
+				if !assert.NoError(t, err) {
+					return
+				}
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+				assert.NoError(t, lock.Unlock())
+			}()
+			// let the waiter queue up before starting the next one
+			waitQueued(t, func() int { return opts.Waiting(t, s, lockID) }, i+1)
+		}
+		require.NoError(t, held.Unlock())
+		wg.Wait()
+		assert.Equal(t, []int{0, 1, 2, 3, 4}, order)
+	})
+}
+
+// waitQueued waits until n callers are queued according to waiting.
+func waitQueued(t *testing.T, waiting func() int, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool { return waiting() >= n }, 5*time.Second, time.Millisecond, "%d callers are not queued", n)
 }
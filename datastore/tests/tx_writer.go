@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/slawo/go-cache/datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runTxWriterTests(t *testing.T, p datastore.TxDataIOProvider) {
+	m, _ := p.(datastore.DataManager)
+	t.Run("InvalidArguments", func(t *testing.T) {
+		_, err := p.GetTxWriterAt(t.Context(), "", 0)
+		assert.Error(t, err)
+		_, err = p.GetTxWriterAt(t.Context(), generateFileName(), -1)
+		assert.Error(t, err)
+	})
+	t.Run("CommitMakesDataVisible", func(t *testing.T) {
+		fn := generateFileName()
+		w, err := p.GetTxWriterAt(t.Context(), fn, 0)
+		require.NoError(t, err)
+		n, err := w.Write(t.Context(), []byte("transactional data"))
+		require.NoError(t, err)
+		assert.Equal(t, 18, n)
+		assert.Equal(t, int64(18), w.GetPosition(t.Context()))
+
+		assert.Equal(t, []byte{}, readTestFile(t, p, fn), "data is not visible before commit")
+		if m != nil {
+			_, err = m.Stat(t.Context(), fn)
+			assert.ErrorIs(t, err, datastore.ErrFileNotFound)
+		}
+		require.NoError(t, w.Commit(t.Context()))
+		assert.Equal(t, []byte("transactional data"), readTestFile(t, p, fn))
+		if m != nil {
+			info, err := m.Stat(t.Context(), fn)
+			require.NoError(t, err)
+			assert.Equal(t, int64(18), info.Size)
+		}
+
+		assert.NoError(t, w.Close(), "closing a committed writer is a no-op")
+		_, err = w.Write(t.Context(), []byte("more"))
+		assert.ErrorIs(t, err, datastore.ErrWriterClosed)
+		assert.ErrorIs(t, w.Commit(t.Context()), datastore.ErrWriterClosed)
+		assert.ErrorIs(t, w.Abort(), datastore.ErrWriterClosed)
+	})
+	t.Run("CommitKeepsPrefix", func(t *testing.T) {
+		fn := generateFileName()
+		writeTestFile(t, p, fn, []byte("0123456789"))
+		w, err := p.GetTxWriterAt(t.Context(), fn, 4)
+		require.NoError(t, err)
+		_, err = w.Write(t.Context(), []byte("ab"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("0123456789"), readTestFile(t, p, fn))
+		require.NoError(t, w.Commit(t.Context()))
+		assert.Equal(t, []byte("0123ab"), readTestFile(t, p, fn), "the file ends with the written data")
+	})
+	t.Run("CommitExtendsShortFile", func(t *testing.T) {
+		fn := generateFileName()
+		writeTestFile(t, p, fn, []byte("01"))
+		w, err := p.GetTxWriterAt(t.Context(), fn, 4)
+		require.NoError(t, err)
+		_, err = w.Write(t.Context(), []byte("ab"))
+		require.NoError(t, err)
+		require.NoError(t, w.Commit(t.Context()))
+		assert.Equal(t, []byte("01\x00\x00ab"), readTestFile(t, p, fn))
+	})
+	t.Run("AbortDiscardsData", func(t *testing.T) {
+		fn := generateFileName()
+		writeTestFile(t, p, fn, []byte("existing"))
+		w, err := p.GetTxWriterAt(t.Context(), fn, 0)
+		require.NoError(t, err)
+		_, err = w.Write(t.Context(), []byte("discarded"))
+		require.NoError(t, err)
+		require.NoError(t, w.Abort())
+		assert.Equal(t, []byte("existing"), readTestFile(t, p, fn))
+
+		assert.NoError(t, w.Close())
+		assert.ErrorIs(t, w.Commit(t.Context()), datastore.ErrWriterClosed)
+		assert.ErrorIs(t, w.Abort(), datastore.ErrWriterClosed)
+		_, err = w.Write(t.Context(), []byte("more"))
+		assert.ErrorIs(t, err, datastore.ErrWriterClosed)
+	})
+	t.Run("CloseDiscardsData", func(t *testing.T) {
+		fn := generateFileName()
+		w, err := p.GetTxWriterAt(t.Context(), fn, 0)
+		require.NoError(t, err)
+		_, err = w.Write(t.Context(), []byte("discarded"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		assert.ErrorIs(t, w.Commit(t.Context()), datastore.ErrWriterClosed)
+		assert.Equal(t, []byte{}, readTestFile(t, p, fn))
+		if m != nil {
+			_, err = m.Stat(t.Context(), fn)
+			assert.ErrorIs(t, err, datastore.ErrFileNotFound)
+		}
+	})
+	t.Run("CommitWithCancelledContext", func(t *testing.T) {
+		fn := generateFileName()
+		w, err := p.GetTxWriterAt(t.Context(), fn, 0)
+		require.NoError(t, err)
+		_, err = w.Write(t.Context(), []byte("discarded"))
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		assert.ErrorIs(t, w.Commit(ctx), context.Canceled)
+		assert.Equal(t, []byte{}, readTestFile(t, p, fn))
+		assert.NoError(t, w.Close())
+	})
+	t.Run("LongWriteInterrupted", func(t *testing.T) {
+		fn := generateFileName()
+		w, err := p.GetTxWriterAt(t.Context(), fn, 0)
+		require.NoError(t, err)
+		defer w.Close()
+		n, err := w.Write(newCountingContext(t.Context(), 2), make([]byte, longIOSize))
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Less(t, n, longIOSize)
+		assert.Equal(t, int64(n), w.GetPosition(t.Context()))
+	})
+	t.Run("LastCommitWins", func(t *testing.T) {
+		fn := generateFileName()
+		w1, err := p.GetTxWriterAt(t.Context(), fn, 0)
+		require.NoError(t, err)
+		w2, err := p.GetTxWriterAt(t.Context(), fn, 0)
+		require.NoError(t, err)
+		_, err = w1.Write(t.Context(), []byte("first writer"))
+		require.NoError(t, err)
+		_, err = w2.Write(t.Context(), []byte("second"))
+		require.NoError(t, err)
+		require.NoError(t, w1.Commit(t.Context()))
+		require.NoError(t, w2.Commit(t.Context()))
+		assert.Equal(t, []byte("second"), readTestFile(t, p, fn), "commits never interleave")
+	})
+}
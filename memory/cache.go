@@ -16,3 +16,23 @@ type Cache[K comparable, D any] interface {
 	Get(key K) (D, error)
 	Put(key K, data D) error
 }
+
+// Deleter is implemented by caches which can remove a single entry.
+type Deleter[K comparable] interface {
+	// Delete removes the entry for the given key, it returns ErrNotFound if
+	// there is none.
+	Delete(key K) error
+}
+
+// EvictionNotifier is implemented by caches which drop entries on their own
+// to make room for new ones. It allows layers built on top of such a cache to
+// keep their own state consistent with it.
+type EvictionNotifier[K comparable, D any] interface {
+	// OnEvict registers fn to be called with every evicted entry.
+	OnEvict(fn func(key K, data D))
+}
+
+// Clearer is implemented by caches which can drop all their entries at once.
+type Clearer interface {
+	Clear()
+}
@@ -2,6 +2,7 @@ package memory
 
 import (
 	"fmt"
+	"sync"
 )
 
 // NewLRU instantiates a LRU cache compatible with the Cache interface.
@@ -26,18 +27,22 @@ func NewLRU[K comparable, D any](capacity int) (*LRU[K, D], error) {
 // cache implementation. It uses a doubly-linked list to store the keys in order of
 // their last access time. The `NewLRU` function creates a new LRU cache with the
 // specified capacity, and the `Get` and `Put` methods retrieve and insert data into
-// the cache, respectively.
+// the cache, respectively. It is safe for concurrent use.
 type LRU[K comparable, D any] struct {
+	mu       sync.Mutex
 	capacity int
 	len      int
 	index    map[K]*llkv[K, D]
 	head     *llkv[K, D]
 	tail     *llkv[K, D]
 	free     *llkv[K, D]
+	onEvict  []func(key K, data D)
 }
 
 // Has reports whether the cache has a key
 func (c *LRU[K, D]) Has(key K) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	_, found := c.index[key]
 	return found, nil
 }
@@ -46,6 +51,8 @@ func (c *LRU[K, D]) Has(key K) (bool, error) {
 // there is no entry for the given key. The key/value pair is updated
 // to the top of the list
 func (c *LRU[K, D]) Get(key K) (D, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	var data D
 	e, found := c.index[key]
 	if !found {
@@ -61,6 +68,8 @@ func (c *LRU[K, D]) Get(key K) (D, error) {
 //
 // When the cache is full the oldest entry is evicted prior to insert.
 func (c *LRU[K, D]) Put(key K, data D) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	e, found := c.index[key]
 	if found {
 		e.data = data
@@ -69,6 +78,9 @@ func (c *LRU[K, D]) Put(key K, data D) error {
 			// repurpose bottom
 			e = c.tail
 			delete(c.index, e.key)
+			for _, fn := range c.onEvict {
+				fn(e.key, e.data)
+			}
 			e.key = key
 			e.data = data
 		}
@@ -86,6 +98,74 @@ func (c *LRU[K, D]) Put(key K, data D) error {
 	return nil
 }
 
+// Delete removes the entry for the given key or returns ErrNotFound if
+// there is no entry for the given key. Deleted entries are not reported
+// to the eviction callbacks.
+func (c *LRU[K, D]) Delete(key K) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, found := c.index[key]
+	if !found {
+		return ErrNotFound
+	}
+	delete(c.index, key)
+	c.unlinkNode(e)
+	var zeroKey K
+	var zeroData D
+	e.key = zeroKey
+	e.data = zeroData
+	e.next = c.free
+	c.free = e
+	c.len--
+	return nil
+}
+
+// Clear removes every entry, cleared entries are not reported to the
+// eviction callbacks.
+func (c *LRU[K, D]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for e := c.head; e != nil; {
+		next := e.next
+		var zeroKey K
+		var zeroData D
+		e.key = zeroKey
+		e.data = zeroData
+		e.prev = nil
+		e.next = c.free
+		c.free = e
+		e = next
+	}
+	clear(c.index)
+	c.head = nil
+	c.tail = nil
+	c.len = 0
+}
+
+// OnEvict registers fn to be called with every entry dropped from the
+// bottom of the list to make room for a new one. The callbacks run while
+// the cache is locked and must not call back into it.
+func (c *LRU[K, D]) OnEvict(fn func(key K, data D)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onEvict = append(c.onEvict, fn)
+}
+
+func (c *LRU[K, D]) unlinkNode(e *llkv[K, D]) {
+	if e.prev != nil {
+		e.prev.next = e.next
+	} else {
+		c.head = e.next
+	}
+	if e.next != nil {
+		e.next.prev = e.prev
+	} else {
+		c.tail = e.prev
+	}
+	e.prev = nil
+	e.next = nil
+}
+
 func (c *LRU[K, D]) moveNodeToTop(e *llkv[K, D]) error {
 	if e == c.head {
 		return nil //is already at the top
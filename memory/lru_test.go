@@ -1,6 +1,7 @@
 package memory_test
 
 import (
+	"sync"
 	"testing"
 
 	gocache "github.com/slawo/go-cache/memory"
@@ -130,3 +131,107 @@ func TestLRUGetEmpty(t *testing.T) {
 	_, err = lRUCache.Get(5)
 	assert.EqualError(t, err, "not found")
 }
+
+func TestLRUDelete(t *testing.T) {
+	lRUCache, err := gocache.NewLRU[int, int](2)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, lRUCache.Delete(1), gocache.ErrNotFound)
+
+	lRUCache.Put(1, 1)
+	lRUCache.Put(2, 2)
+	assert.NoError(t, lRUCache.Delete(1))
+	_, err = lRUCache.Get(1)
+	assert.ErrorIs(t, err, gocache.ErrNotFound)
+
+	// the freed slot is reused without evicting
+	lRUCache.Put(3, 3)
+	v, err := lRUCache.Get(2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, v)
+	v, err = lRUCache.Get(3)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, v)
+
+	assert.NoError(t, lRUCache.Delete(3))
+	assert.NoError(t, lRUCache.Delete(2))
+	_, err = lRUCache.Get(2)
+	assert.ErrorIs(t, err, gocache.ErrNotFound)
+
+	lRUCache.Put(4, 4)
+	lRUCache.Put(5, 5)
+	lRUCache.Put(6, 6) // evicts 4
+	_, err = lRUCache.Get(4)
+	assert.ErrorIs(t, err, gocache.ErrNotFound)
+	v, err = lRUCache.Get(5)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, v)
+}
+
+func TestLRUOnEvict(t *testing.T) {
+	lRUCache, err := gocache.NewLRU[int, int](2)
+	assert.NoError(t, err)
+
+	evicted := map[int]int{}
+	lRUCache.OnEvict(func(key int, data int) {
+		evicted[key] = data
+	})
+
+	lRUCache.Put(1, 10)
+	lRUCache.Put(2, 20)
+	lRUCache.Put(1, 11)
+	assert.Empty(t, evicted)
+
+	lRUCache.Put(3, 30) // evicts 2
+	assert.Equal(t, map[int]int{2: 20}, evicted)
+
+	assert.NoError(t, lRUCache.Delete(1))
+	assert.Equal(t, map[int]int{2: 20}, evicted, "deletes are not evictions")
+}
+
+func TestLRUClear(t *testing.T) {
+	lRUCache, err := gocache.NewLRU[int, int](3)
+	assert.NoError(t, err)
+	evicted := 0
+	lRUCache.OnEvict(func(key int, data int) {
+		evicted++
+	})
+
+	lRUCache.Put(1, 1)
+	lRUCache.Put(2, 2)
+	lRUCache.Clear()
+	assert.Equal(t, 0, evicted, "cleared entries are not evictions")
+	_, err = lRUCache.Get(1)
+	assert.ErrorIs(t, err, gocache.ErrNotFound)
+
+	// the whole capacity is available again
+	lRUCache.Put(3, 3)
+	lRUCache.Put(4, 4)
+	lRUCache.Put(5, 5)
+	assert.Equal(t, 0, evicted)
+	lRUCache.Put(6, 6)
+	assert.Equal(t, 1, evicted)
+	_, err = lRUCache.Get(3)
+	assert.ErrorIs(t, err, gocache.ErrNotFound)
+}
+
+func TestLRUConcurrentAccess(t *testing.T) {
+	lRUCache, err := gocache.NewLRU[int, int](16)
+	assert.NoError(t, err)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				lRUCache.Put(j%32, i)
+				lRUCache.Get((j + i) % 32)
+				if j%7 == 0 {
+					lRUCache.Delete(j % 32)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
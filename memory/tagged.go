@@ -0,0 +1,149 @@
+package memory
+
+import (
+	"errors"
+	"sync"
+)
+
+// TaggableCache is a Cache which can delete entries and reports its
+// evictions, it can therefore be wrapped by a Tagged layer.
+type TaggableCache[K comparable, D any] interface {
+	Cache[K, D]
+	Deleter[K]
+	EvictionNotifier[K, D]
+}
+
+// NewTagged instantiates a tagging layer on top of the given cache.
+// The wrapped cache must only be accessed through the returned Tagged
+// instance from then on, otherwise the tag index can drift from its content.
+func NewTagged[K comparable, D any, T comparable](c TaggableCache[K, D]) (*Tagged[K, D, T], error) {
+	if c == nil {
+		return nil, errors.New("cannot initialize tagged cache without a cache")
+	}
+	t := &Tagged[K, D, T]{
+		c:    c,
+		tags: make(map[T]map[K]struct{}),
+		keys: make(map[K][]T),
+	}
+	// Evictions happen while Put holds the lock, the index can be updated
+	// directly.
+	c.OnEvict(func(key K, _ D) {
+		t.untag(key)
+	})
+	return t, nil
+}
+
+// Tagged groups the entries of a cache under tags so that all the entries
+// sharing a tag can be dropped at once with InvalidateTag.
+//
+// Entries evicted by the wrapped cache are removed from the tag index as
+// well, so the index never outgrows the cache.
+//
+// Tagged is a type of its own rather than a Cache: its Put takes the tags of
+// the entry and its Clear fails when the wrapped cache is not a Clearer.
+type Tagged[K comparable, D any, T comparable] struct {
+	mu   sync.Mutex
+	c    TaggableCache[K, D]
+	tags map[T]map[K]struct{}
+	keys map[K][]T
+}
+
+// Has reports whether the cache has a key
+func (t *Tagged[K, D, T]) Has(key K) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.c.Has(key)
+}
+
+// Get returns either value for the given key or returns ErrNotFound if
+// there is no entry for the given key.
+func (t *Tagged[K, D, T]) Get(key K) (D, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.c.Get(key)
+}
+
+// Put inserts or updates the given key value pair and attaches it to the
+// given tags. The tags replace any tags previously attached to the key.
+func (t *Tagged[K, D, T]) Put(key K, data D, tags ...T) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.c.Put(key, data); err != nil {
+		return err
+	}
+	t.untag(key)
+	if len(tags) == 0 {
+		return nil
+	}
+	keyTags := make([]T, 0, len(tags))
+	for _, tag := range tags {
+		keys, found := t.tags[tag]
+		if !found {
+			keys = make(map[K]struct{})
+			t.tags[tag] = keys
+		}
+		if _, found := keys[key]; found {
+			continue // duplicated tag
+		}
+		keys[key] = struct{}{}
+		keyTags = append(keyTags, tag)
+	}
+	t.keys[key] = keyTags
+	return nil
+}
+
+// Delete removes the entry for the given key along with its tags.
+func (t *Tagged[K, D, T]) Delete(key K) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.untag(key)
+	return t.c.Delete(key)
+}
+
+// InvalidateTag removes every entry carrying the given tag.
+func (t *Tagged[K, D, T]) InvalidateTag(tag T) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var errs []error
+	for key := range t.tags[tag] {
+		t.untag(key)
+		if err := t.c.Delete(key); err != nil && !errors.Is(err, ErrNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Clear removes every entry and tag, it requires the wrapped cache to
+// implement Clearer.
+func (t *Tagged[K, D, T]) Clear() error {
+	c, ok := t.c.(Clearer)
+	if !ok {
+		return errors.New("tagged cache: wrapped cache cannot be cleared")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c.Clear()
+	clear(t.tags)
+	clear(t.keys)
+	return nil
+}
+
+// Tags returns the tags attached to the given key.
+func (t *Tagged[K, D, T]) Tags(key K) []T {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]T(nil), t.keys[key]...)
+}
+
+// untag removes the key from the tag index, the lock must be held.
+func (t *Tagged[K, D, T]) untag(key K) {
+	for _, tag := range t.keys[key] {
+		keys := t.tags[tag]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(t.tags, tag)
+		}
+	}
+	delete(t.keys, key)
+}
@@ -0,0 +1,104 @@
+package memory_test
+
+import (
+	"testing"
+
+	gocache "github.com/slawo/go-cache/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTagged(t *testing.T, capacity int) *gocache.Tagged[string, int, string] {
+	lru, err := gocache.NewLRU[string, int](capacity)
+	require.NoError(t, err)
+	tagged, err := gocache.NewTagged[string, int, string](lru)
+	require.NoError(t, err)
+	return tagged
+}
+
+func TestNewTaggedWithoutCache(t *testing.T) {
+	tagged, err := gocache.NewTagged[string, int, string](nil)
+	assert.EqualError(t, err, "cannot initialize tagged cache without a cache")
+	assert.Nil(t, tagged)
+}
+
+func TestTaggedInvalidateTag(t *testing.T) {
+	tagged := newTagged(t, 10)
+
+	assert.NoError(t, tagged.Put("a1", 1, "tenant-a"))
+	assert.NoError(t, tagged.Put("a2", 2, "tenant-a", "shared"))
+	assert.NoError(t, tagged.Put("b1", 3, "tenant-b", "shared"))
+	assert.NoError(t, tagged.Put("untagged", 4))
+
+	assert.NoError(t, tagged.InvalidateTag("tenant-a"))
+
+	for _, key := range []string{"a1", "a2"} {
+		found, err := tagged.Has(key)
+		assert.NoError(t, err)
+		assert.False(t, found, "%s should have been invalidated", key)
+	}
+	for _, key := range []string{"b1", "untagged"} {
+		found, err := tagged.Has(key)
+		assert.NoError(t, err)
+		assert.True(t, found, "%s should not have been invalidated", key)
+	}
+	assert.Equal(t, []string{"tenant-b", "shared"}, tagged.Tags("b1"))
+
+	assert.NoError(t, tagged.InvalidateTag("shared"))
+	_, err := tagged.Get("b1")
+	assert.ErrorIs(t, err, gocache.ErrNotFound)
+
+	assert.NoError(t, tagged.InvalidateTag("unknown"))
+}
+
+func TestTaggedPutReplacesTags(t *testing.T) {
+	tagged := newTagged(t, 10)
+
+	assert.NoError(t, tagged.Put("key", 1, "old", "old"))
+	assert.Equal(t, []string{"old"}, tagged.Tags("key"))
+	assert.NoError(t, tagged.Put("key", 2, "new"))
+	assert.Equal(t, []string{"new"}, tagged.Tags("key"))
+
+	assert.NoError(t, tagged.InvalidateTag("old"))
+	v, err := tagged.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, v)
+}
+
+func TestTaggedEvictionUpdatesIndex(t *testing.T) {
+	tagged := newTagged(t, 2)
+
+	assert.NoError(t, tagged.Put("k1", 1, "t1"))
+	assert.NoError(t, tagged.Put("k2", 2, "t2"))
+	assert.NoError(t, tagged.Put("k3", 3, "t3")) // evicts k1
+	assert.Empty(t, tagged.Tags("k1"))
+
+	// k1 comes back without tags, invalidating its former tag keeps it
+	assert.NoError(t, tagged.Put("k1", 10)) // evicts k2
+	assert.Empty(t, tagged.Tags("k2"))
+	assert.NoError(t, tagged.InvalidateTag("t1"))
+	v, err := tagged.Get("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, v)
+}
+
+func TestTaggedDelete(t *testing.T) {
+	tagged := newTagged(t, 2)
+
+	assert.NoError(t, tagged.Put("k1", 1, "t1"))
+	assert.NoError(t, tagged.Delete("k1"))
+	assert.Empty(t, tagged.Tags("k1"))
+	assert.ErrorIs(t, tagged.Delete("k1"), gocache.ErrNotFound)
+}
+
+func TestTaggedClear(t *testing.T) {
+	tagged := newTagged(t, 4)
+
+	assert.NoError(t, tagged.Put("k1", 1, "t1"))
+	assert.NoError(t, tagged.Put("k2", 2))
+	assert.NoError(t, tagged.Clear())
+	assert.Empty(t, tagged.Tags("k1"))
+	found, err := tagged.Has("k2")
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
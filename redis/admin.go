@@ -0,0 +1,86 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slawo/go-cache/datastore"
+)
+
+// ListLocks lists the locks currently held in the namespace and tenant of
+// the synchroniser, sorted by lock ID. The locks of the tenants are not
+// listed by the synchroniser they were created from.
+//
+// The keys are found with SCAN on every master, the listing is meant for
+// administration and is not a consistent snapshot.
+func (r *RedisSynchroniser) ListLocks(ctx context.Context) ([]datastore.LockInfo, error) {
+	now, err := r.client.Time(ctx).Result()
+	if err != nil {
+		return nil, fmt.Errorf("synchroniser: unable to get server time: %w", err)
+	}
+	keyPrefix := r.prefix + "lock:{"
+	var mu sync.Mutex
+	var locks []datastore.LockInfo
+	err = scanKeys(ctx, r.client, keyPrefix+"*}:write*", func(ctx context.Context, c redis.Cmdable, key string) error {
+		var found []datastore.LockInfo
+		var err error
+		switch {
+		case strings.HasSuffix(key, "}:write"):
+			found, err = writeLockInfo(ctx, c, key)
+		case strings.HasSuffix(key, "}:write:readers"):
+			found, err = readLockInfo(ctx, c, key, now)
+		default:
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		lockID := strings.TrimPrefix(key, keyPrefix)
+		lockID = hashTagUnescaper.Replace(lockID[:strings.LastIndex(lockID, "}:write")])
+		mu.Lock()
+		defer mu.Unlock()
+		for _, l := range found {
+			l.LockID = lockID
+			locks = append(locks, l)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("synchroniser: unable to list locks: %w", err)
+	}
+	sort.Slice(locks, func(i, j int) bool {
+		if locks[i].LockID != locks[j].LockID {
+			return locks[i].LockID < locks[j].LockID
+		}
+		return locks[i].Holder.ID < locks[j].Holder.ID
+	})
+	return locks, nil
+}
+
+// scanKeys calls fn with every key matching match, on every master of a
+// cluster. fn is called concurrently for the different masters.
+func scanKeys(ctx context.Context, client redis.UniversalClient, match string, fn func(context.Context, redis.Cmdable, string) error) error {
+	if shared, ok := client.(sharedClient); ok {
+		client = shared.UniversalClient
+	}
+	if cluster, ok := client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, c *redis.Client) error {
+			return scan(ctx, c, match, fn)
+		})
+	}
+	return scan(ctx, client, match, fn)
+}
+
+func scan(ctx context.Context, c redis.Cmdable, match string, fn func(context.Context, redis.Cmdable, string) error) error {
+	it := c.Scan(ctx, 0, match, 100).Iterator()
+	for it.Next(ctx) {
+		if err := fn(ctx, c, it.Val()); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
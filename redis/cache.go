@@ -0,0 +1,240 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slawo/go-cache/codec"
+	"github.com/slawo/go-cache/memory"
+)
+
+// CacheOptions configures how a Cache encodes its keys and values.
+type CacheOptions[K comparable, D any] struct {
+	// Codec encodes the values, wrap it with codec.NewVersioned to detect
+	// entries written with an older schema.
+	Codec codec.Codec[D]
+	// Keys encodes the keys.
+	Keys codec.KeyEncoder[K]
+	// Prefix is prepended to every key, it isolates caches sharing a
+	// database.
+	Prefix string
+	// TTL is the time to live of the entries written with Put and PutMany,
+	// zero disables expiry.
+	TTL time.Duration
+}
+
+// NewCache instantiates a generic key/value cache stored in redis.
+// The connection is configured with the same options as NewSynchroniser.
+func NewCache[K comparable, D any](ctx context.Context, co CacheOptions[K, D], opts ...SynchroniserOption) (*Cache[K, D], error) {
+	if co.Codec == nil {
+		return nil, errors.New("cache: missing codec")
+	}
+	if co.Keys == nil {
+		return nil, errors.New("cache: missing key encoder")
+	}
+	if co.TTL < 0 {
+		return nil, errors.New("cache: ttl cannot be negative")
+	}
+	o, err := applyOptions(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("cache: failed to apply option: %w", err)
+	}
+	if !o.hasServer() {
+		return nil, fmt.Errorf("cache: DSN cannot be empty")
+	}
+	client, err := newClient(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache[K, D]{
+		ctx:    context.Background(),
+		client: client,
+		opts:   co,
+	}, nil
+}
+
+// Cache is a redis backed implementation of memory.Cache. Besides the Cache
+// interface it supports per entry TTLs and pipelined batch operations.
+//
+// Entries which cannot be decoded, for instance because they were written
+// with another schema version, are deleted and reported as missing.
+type Cache[K comparable, D any] struct {
+	ctx    context.Context
+	client redis.UniversalClient
+	opts   CacheOptions[K, D]
+}
+
+// WithContext returns a copy of the cache using ctx for its redis calls.
+func (c *Cache[K, D]) WithContext(ctx context.Context) *Cache[K, D] {
+	cp := *c
+	cp.ctx = ctx
+	return &cp
+}
+
+// Close closes the connection to redis.
+func (c *Cache[K, D]) Close() error {
+	return c.client.Close()
+}
+
+// Has reports whether the cache has a valid entry for the key, the entries
+// Get would not return are not reported.
+func (c *Cache[K, D]) Has(key K) (bool, error) {
+	_, err := c.Get(key)
+	if errors.Is(err, memory.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Get returns the value for the given key or memory.ErrNotFound.
+func (c *Cache[K, D]) Get(key K) (D, error) {
+	var data D
+	k, err := c.key(key)
+	if err != nil {
+		return data, err
+	}
+	b, err := c.client.Get(c.ctx, k).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return data, memory.ErrNotFound
+	}
+	if err != nil {
+		return data, fmt.Errorf("cache: %w", err)
+	}
+	return c.decode(k, b)
+}
+
+// Put stores the value for the given key with the configured TTL.
+func (c *Cache[K, D]) Put(key K, data D) error {
+	return c.PutWithTTL(key, data, c.opts.TTL)
+}
+
+// PutWithTTL stores the value for the given key with the given TTL,
+// zero disables expiry.
+func (c *Cache[K, D]) PutWithTTL(key K, data D, ttl time.Duration) error {
+	k, err := c.key(key)
+	if err != nil {
+		return err
+	}
+	b, err := c.opts.Codec.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if err := c.client.Set(c.ctx, k, b, ttl).Err(); err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the value for the given key or returns memory.ErrNotFound.
+func (c *Cache[K, D]) Delete(key K) error {
+	k, err := c.key(key)
+	if err != nil {
+		return err
+	}
+	n, err := c.client.Del(c.ctx, k).Result()
+	if err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+	if n == 0 {
+		return memory.ErrNotFound
+	}
+	return nil
+}
+
+// GetMany fetches several keys in a single round trip. Missing keys are
+// left out of the returned map.
+func (c *Cache[K, D]) GetMany(keys []K) (map[K]D, error) {
+	encoded := make([]string, len(keys))
+	for i, key := range keys {
+		k, err := c.key(key)
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = k
+	}
+	cmds := make([]*redis.StringCmd, len(keys))
+	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		for i, k := range encoded {
+			cmds[i] = pipe.Get(c.ctx, k)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("cache: %w", err)
+	}
+	out := make(map[K]D, len(keys))
+	for i, cmd := range cmds {
+		b, err := cmd.Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cache: %w", err)
+		}
+		data, err := c.decode(encoded[i], b)
+		if errors.Is(err, memory.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out[keys[i]] = data
+	}
+	return out, nil
+}
+
+// PutMany stores several entries with the configured TTL in a single round
+// trip.
+func (c *Cache[K, D]) PutMany(entries map[K]D) error {
+	encoded := make(map[string][]byte, len(entries))
+	for key, data := range entries {
+		k, err := c.key(key)
+		if err != nil {
+			return err
+		}
+		b, err := c.opts.Codec.Marshal(data)
+		if err != nil {
+			return err
+		}
+		encoded[k] = b
+	}
+	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		for k, b := range encoded {
+			pipe.Set(c.ctx, k, b, c.opts.TTL)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+	return nil
+}
+
+func (c *Cache[K, D]) key(key K) (string, error) {
+	k, err := c.opts.Keys.EncodeKey(key)
+	if err != nil {
+		return "", err
+	}
+	return c.opts.Prefix + k, nil
+}
+
+// decode decodes a stored value, values which cannot be trusted are deleted.
+// The values written with a newer schema are left to the newer readers.
+func (c *Cache[K, D]) decode(k string, b []byte) (D, error) {
+	data, err := c.opts.Codec.Unmarshal(b)
+	if errors.Is(err, codec.ErrNewerSchema) {
+		var zero D
+		return zero, memory.ErrNotFound
+	}
+	if errors.Is(err, codec.ErrSchemaMismatch) || errors.Is(err, codec.ErrCorruptedData) {
+		var zero D
+		if err := c.client.Del(c.ctx, k).Err(); err != nil {
+			return zero, fmt.Errorf("cache: %w", err)
+		}
+		return zero, memory.ErrNotFound
+	}
+	return data, err
+}
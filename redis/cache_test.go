@@ -0,0 +1,169 @@
+package redis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/slawo/go-cache/codec"
+	"github.com/slawo/go-cache/memory"
+	"github.com/slawo/go-cache/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type cacheValue struct {
+	Name  string
+	Count int
+}
+
+func NewCache(t *testing.T, dsn string, co redis.CacheOptions[string, cacheValue]) *redis.Cache[string, cacheValue] {
+	if co.Codec == nil {
+		co.Codec = codec.NewVersioned[cacheValue](codec.JSON[cacheValue]{}, 1)
+	}
+	if co.Keys == nil {
+		co.Keys = codec.StringKeys[string]{}
+	}
+	c, err := redis.NewCache[string, cacheValue](t.Context(), co, redis.SynchroniserDSN(dsn))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, c.Close())
+	})
+	return c.WithContext(t.Context())
+}
+
+func TestNewCacheValidatesOptions(t *testing.T) {
+	ctx := t.Context()
+	keys := codec.StringKeys[string]{}
+	jsonCodec := codec.JSON[cacheValue]{}
+
+	_, err := redis.NewCache[string, cacheValue](ctx, redis.CacheOptions[string, cacheValue]{Keys: keys})
+	assert.EqualError(t, err, "cache: missing codec")
+	_, err = redis.NewCache[string, cacheValue](ctx, redis.CacheOptions[string, cacheValue]{Codec: jsonCodec})
+	assert.EqualError(t, err, "cache: missing key encoder")
+	_, err = redis.NewCache[string, cacheValue](ctx, redis.CacheOptions[string, cacheValue]{Codec: jsonCodec, Keys: keys, TTL: -1})
+	assert.EqualError(t, err, "cache: ttl cannot be negative")
+	_, err = redis.NewCache[string, cacheValue](ctx, redis.CacheOptions[string, cacheValue]{Codec: jsonCodec, Keys: keys})
+	assert.EqualError(t, err, "cache: DSN cannot be empty")
+}
+
+func TestCacheImplementsCache(t *testing.T) {
+	var c memory.Cache[string, cacheValue]
+	c = NewCache(t, NewServer(t), redis.CacheOptions[string, cacheValue]{})
+	assert.NotNil(t, c)
+}
+
+func TestCachePutGetDelete(t *testing.T) {
+	c := NewCache(t, NewServer(t), redis.CacheOptions[string, cacheValue]{Prefix: "test:"})
+
+	found, err := c.Has("key")
+	assert.NoError(t, err)
+	assert.False(t, found)
+	_, err = c.Get("key")
+	assert.ErrorIs(t, err, memory.ErrNotFound)
+
+	value := cacheValue{Name: "value", Count: 1}
+	require.NoError(t, c.Put("key", value))
+	found, err = c.Has("key")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	v, err := c.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, value, v)
+
+	require.NoError(t, c.Delete("key"))
+	assert.ErrorIs(t, c.Delete("key"), memory.ErrNotFound)
+}
+
+func TestCachePrefixIsolatesCaches(t *testing.T) {
+	dsn := NewServer(t)
+	a := NewCache(t, dsn, redis.CacheOptions[string, cacheValue]{Prefix: "a:"})
+	b := NewCache(t, dsn, redis.CacheOptions[string, cacheValue]{Prefix: "b:"})
+
+	require.NoError(t, a.Put("key", cacheValue{Name: "a"}))
+	_, err := b.Get("key")
+	assert.ErrorIs(t, err, memory.ErrNotFound)
+}
+
+func TestCacheTTL(t *testing.T) {
+	c := NewCache(t, NewServer(t), redis.CacheOptions[string, cacheValue]{TTL: 100 * time.Millisecond})
+
+	require.NoError(t, c.Put("key", cacheValue{Name: "short"}))
+	require.NoError(t, c.PutWithTTL("long", cacheValue{Name: "long"}, time.Minute))
+	require.Eventually(t, func() bool {
+		found, err := c.Has("key")
+		return err == nil && !found
+	}, 5*time.Second, 50*time.Millisecond)
+	found, err := c.Has("long")
+	assert.NoError(t, err)
+	assert.True(t, found)
+}
+
+func TestCacheGetManyPutMany(t *testing.T) {
+	c := NewCache(t, NewServer(t), redis.CacheOptions[string, cacheValue]{})
+
+	entries := map[string]cacheValue{
+		"k1": {Name: "one", Count: 1},
+		"k2": {Name: "two", Count: 2},
+		"k3": {Name: "three", Count: 3},
+	}
+	require.NoError(t, c.PutMany(entries))
+
+	got, err := c.GetMany([]string{"k1", "missing", "k2", "k3"})
+	require.NoError(t, err)
+	assert.Equal(t, entries, got)
+}
+
+func TestCacheDropsOldSchemaEntries(t *testing.T) {
+	dsn := NewServer(t)
+	v1 := NewCache(t, dsn, redis.CacheOptions[string, cacheValue]{Codec: codec.NewVersioned[cacheValue](codec.JSON[cacheValue]{}, 1)})
+	v2 := NewCache(t, dsn, redis.CacheOptions[string, cacheValue]{Codec: codec.NewVersioned[cacheValue](codec.Gob[cacheValue]{}, 2)})
+
+	require.NoError(t, v1.Put("key", cacheValue{Name: "v1"}))
+	require.NoError(t, v1.Put("other", cacheValue{Name: "v1"}))
+	_, err := v2.Get("key")
+	assert.ErrorIs(t, err, memory.ErrNotFound)
+	got, err := v2.GetMany([]string{"other"})
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	found, err := v1.Has("key")
+	assert.NoError(t, err)
+	assert.False(t, found, "old entries should have been dropped")
+}
+
+func TestCacheKeepsNewerSchemaEntries(t *testing.T) {
+	dsn := NewServer(t)
+	v1 := NewCache(t, dsn, redis.CacheOptions[string, cacheValue]{Codec: codec.NewVersioned[cacheValue](codec.JSON[cacheValue]{}, 1)})
+	v2 := NewCache(t, dsn, redis.CacheOptions[string, cacheValue]{Codec: codec.NewVersioned[cacheValue](codec.JSON[cacheValue]{}, 2)})
+
+	require.NoError(t, v2.Put("key", cacheValue{Name: "v2"}))
+	_, err := v1.Get("key")
+	assert.ErrorIs(t, err, memory.ErrNotFound)
+	got, err := v1.GetMany([]string{"key"})
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	// the older reader misses without dropping the entry
+	value, err := v2.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, cacheValue{Name: "v2"}, value)
+}
+
+func TestCacheHasSkipsStaleEntries(t *testing.T) {
+	dsn := NewServer(t)
+	v1 := NewCache(t, dsn, redis.CacheOptions[string, cacheValue]{Codec: codec.NewVersioned[cacheValue](codec.JSON[cacheValue]{}, 1)})
+	v2 := NewCache(t, dsn, redis.CacheOptions[string, cacheValue]{Codec: codec.NewVersioned[cacheValue](codec.JSON[cacheValue]{}, 2)})
+
+	require.NoError(t, v1.Put("old", cacheValue{Name: "v1"}))
+	require.NoError(t, v2.Put("new", cacheValue{Name: "v2"}))
+
+	found, err := v2.Has("old")
+	assert.NoError(t, err)
+	assert.False(t, found, "an entry of an older schema is not reported")
+	found, err = v1.Has("new")
+	assert.NoError(t, err)
+	assert.False(t, found, "an entry of a newer schema is not reported")
+	found, err = v2.Has("new")
+	assert.NoError(t, err)
+	assert.True(t, found)
+}
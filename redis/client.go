@@ -0,0 +1,79 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// applyOptions builds the options shared by the synchroniser and the cache.
+func applyOptions(opts ...SynchroniserOption) (SynchroniserOptions, error) {
+	o := SynchroniserOptions{}
+	for _, opt := range opts {
+		if err := opt.Apply(&o); err != nil {
+			return o, err
+		}
+	}
+	return o, nil
+}
+
+// hasServer checks whether the options describe a server to connect to.
+func (o SynchroniserOptions) hasServer() bool {
+	return o.DSN != "" || len(o.Addrs) > 0 || o.Client != nil
+}
+
+// universalOptions returns the connection options for the given addresses,
+// a single server, a cluster or the sentinels of a failover group depending
+// on the options. The commands honour the deadlines of their context, the
+// refreshes of the locks rely on it.
+func universalOptions(o SynchroniserOptions, addrs []string) *redis.UniversalOptions {
+	return &redis.UniversalOptions{
+		ContextTimeoutEnabled: true,
+		Addrs:                 addrs,
+		MasterName:            o.MasterName,
+		IsClusterMode:         o.Cluster,
+		Username:              o.Username,
+		Password:              o.Password,
+		SentinelUsername:      o.SentinelUsername,
+		SentinelPassword:      o.SentinelPassword,
+		DB:                    o.DB,
+		TLSConfig:             o.TLSConfig,
+		PoolSize:              o.PoolSize,
+		MinIdleConns:          o.MinIdleConns,
+		DialTimeout:           o.DialTimeout,
+		ReadTimeout:           o.ReadTimeout,
+		WriteTimeout:          o.WriteTimeout,
+	}
+}
+
+// newClient connects to the server described by the options and checks the
+// connection. An injected client is used as is and is not closed with the
+// value using it.
+func newClient(ctx context.Context, o SynchroniserOptions) (redis.UniversalClient, error) {
+	if o.Client != nil {
+		if err := o.Client.Ping(ctx).Err(); err != nil {
+			return nil, err
+		}
+		return sharedClient{o.Client}, nil
+	}
+	addrs := o.Addrs
+	if len(addrs) == 0 {
+		addrs = []string{o.DSN}
+	}
+	client := redis.NewUniversalClient(universalOptions(o, addrs))
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// sharedClient is a client provided by the caller, its owner closes it.
+type sharedClient struct {
+	redis.UniversalClient
+}
+
+func (sharedClient) Close() error {
+	return nil
+}
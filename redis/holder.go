@@ -0,0 +1,159 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/slawo/go-cache/datastore"
+)
+
+// holderValue describes the holder of a lock. It is stored, encoded in JSON,
+// as the value of the lock, or as the member of the set of the read locks,
+// so that it expires with the lock. The unique ID makes the value of every
+// acquisition different.
+type holderValue struct {
+	ID             string `json:"id"`
+	SynchroniserID string `json:"sid"`
+	Hostname       string `json:"host,omitempty"`
+	AcquiredAt     int64  `json:"at"`
+	Purpose        string `json:"purpose,omitempty"`
+}
+
+// newHolderValue returns the value of a new lock acquired by the given
+// synchroniser, with the purpose carried by ctx.
+func newHolderValue(ctx context.Context, synchroniserID, hostname string) (string, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+	v, err := json.Marshal(holderValue{
+		ID:             id.String(),
+		SynchroniserID: synchroniserID,
+		Hostname:       hostname,
+		AcquiredAt:     time.Now().UnixMilli(),
+		Purpose:        datastore.LockPurposeFromContext(ctx),
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(v), nil
+}
+
+// parseHolder decodes the value of a lock, the values which are not holder
+// descriptions, such as the values given to NewWriteLock, are used as ID.
+func parseHolder(value string) datastore.LockHolder {
+	var v holderValue
+	if err := json.Unmarshal([]byte(value), &v); err != nil || v.ID == "" {
+		return datastore.LockHolder{ID: value}
+	}
+	return datastore.LockHolder{
+		ID:             v.ID,
+		SynchroniserID: v.SynchroniserID,
+		Hostname:       v.Hostname,
+		AcquiredAt:     time.UnixMilli(v.AcquiredAt),
+		Purpose:        v.Purpose,
+	}
+}
+
+// GetLockInfo returns the write lock or the read locks held with the given
+// ID, with their holders and remaining TTL.
+func (r *RedisSynchroniser) GetLockInfo(ctx context.Context, lockID string) ([]datastore.LockInfo, error) {
+	if strings.TrimSpace(lockID) == "" || len(lockID) < 3 {
+		return nil, datastore.ErrInvalidLockID
+	}
+	now, err := r.client.Time(ctx).Result()
+	if err != nil {
+		return nil, fmt.Errorf("synchroniser: unable to get server time: %w", err)
+	}
+	key := writeLockKey(r.prefix, lockID)
+	locks, err := writeLockInfo(ctx, r.client, key)
+	if err != nil {
+		return nil, fmt.Errorf("synchroniser: %w", err)
+	}
+	readers, err := readLockInfo(ctx, r.client, lockKeys(key)[3], now)
+	if err != nil {
+		return nil, fmt.Errorf("synchroniser: %w", err)
+	}
+	locks = append(locks, readers...)
+	if len(locks) == 0 {
+		return nil, fmt.Errorf("%w: %s", datastore.ErrLockNotHeld, key)
+	}
+	for i := range locks {
+		locks[i].LockID = lockID
+	}
+	return locks, nil
+}
+
+// ForceUnlock deletes the write lock and the read locks held with the given
+// ID and wakes up the waiting callers. The holders notice the loss on their
+// next refresh.
+func (r *RedisSynchroniser) ForceUnlock(ctx context.Context, lockID string) error {
+	if strings.TrimSpace(lockID) == "" || len(lockID) < 3 {
+		return datastore.ErrInvalidLockID
+	}
+	key := writeLockKey(r.prefix, lockID)
+	held, err := r.client.Eval(ctx, forceUnlockScript, lockKeys(key), releasedChannel(key)).Int()
+	if err != nil {
+		err = fmt.Errorf("synchroniser: %w", err)
+	} else if held == 0 {
+		err = fmt.Errorf("%w: %s", datastore.ErrLockNotHeld, key)
+	}
+	r.obs.event(ctx, "lock force unlock", key, "", err)
+	return err
+}
+
+// writeLockInfo returns the holder of a write lock, nothing if it expired.
+func writeLockInfo(ctx context.Context, c redis.Cmdable, key string) ([]datastore.LockInfo, error) {
+	value, err := c.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	ttl, err := c.PTTL(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	return []datastore.LockInfo{{Holder: parseHolder(value), TTL: max(ttl, 0)}}, nil
+}
+
+// readLockInfo returns the holders of the read locks which did not expire.
+func readLockInfo(ctx context.Context, c redis.Cmdable, key string, now time.Time) ([]datastore.LockInfo, error) {
+	nowMs := now.UnixMilli()
+	readers, err := c.ZRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+		Min: fmt.Sprintf("(%d", nowMs),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	locks := make([]datastore.LockInfo, 0, len(readers))
+	for _, z := range readers {
+		locks = append(locks, datastore.LockInfo{
+			Holder: parseHolder(fmt.Sprint(z.Member)),
+			TTL:    time.Duration(int64(z.Score)-nowMs) * time.Millisecond,
+			Shared: true,
+		})
+	}
+	return locks, nil
+}
+
+// forceUnlockScript deletes a lock and its read locks, it returns the number
+// of locks which were held. The keys are the same as for lockScript, ARGV[1]
+// is the channel notified when the lock is released.
+const forceUnlockScript = `
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local held = redis.call("EXISTS", KEYS[1]) + redis.call("ZCOUNT", KEYS[4], "(" .. now, "+inf")
+redis.call("DEL", KEYS[1], KEYS[4])
+if held > 0 then
+	redis.call("PUBLISH", ARGV[1], "")
+end
+return held
+`
@@ -0,0 +1,270 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slawo/go-cache/memory"
+	"github.com/slawo/go-cache/tiered"
+)
+
+const (
+	// invalidationHealthCheck is the interval after which an idle
+	// subscription is pinged to detect dead connections.
+	invalidationHealthCheck = 5 * time.Second
+	// invalidationRetryDelay is the delay between two reconnection attempts.
+	invalidationRetryDelay = 100 * time.Millisecond
+)
+
+// TagListener is a tiered.Listener which also handles tag invalidations.
+type TagListener interface {
+	tiered.Listener[string]
+	// EvictTag drops every local entry carrying the tag.
+	EvictTag(tag string)
+}
+
+// NewInvalidationBus connects to redis and subscribes to the given channel.
+// The connection is configured with the same options as NewSynchroniser.
+//
+// The bus implements tiered.Invalidator so it can be plugged into a tiered
+// cache, plain local caches can be registered with CacheListener and
+// TaggedListener.
+func NewInvalidationBus(ctx context.Context, channel string, opts ...SynchroniserOption) (*InvalidationBus, error) {
+	if channel == "" {
+		return nil, errors.New("invalidation bus: channel cannot be empty")
+	}
+	o, err := applyOptions(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalidation bus: failed to apply option: %w", err)
+	}
+	if !o.hasServer() {
+		return nil, fmt.Errorf("invalidation bus: DSN cannot be empty")
+	}
+	client, err := newClient(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	ps := client.Subscribe(ctx, channel)
+	// wait for the subscription to be confirmed, invalidations published
+	// from now on are received
+	if _, err := ps.Receive(ctx); err != nil {
+		ps.Close()
+		client.Close()
+		return nil, fmt.Errorf("invalidation bus: unable to subscribe: %w", err)
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	b := &InvalidationBus{
+		client:    client,
+		ps:        ps,
+		channel:   channel,
+		listeners: make(map[string][]tiered.Listener[string]),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	go b.run(runCtx)
+	return b, nil
+}
+
+// InvalidationBus broadcasts key and tag invalidations over a redis pub/sub
+// channel and evicts them from the local caches registered with Subscribe.
+//
+// Pub/sub does not buffer messages, so whenever the subscription is lost
+// every registered cache is flushed, once when the loss is detected and
+// once when the subscription is restored.
+type InvalidationBus struct {
+	client  redis.UniversalClient
+	ps      *redis.PubSub
+	channel string
+
+	mu        sync.RWMutex
+	listeners map[string][]tiered.Listener[string]
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type invalidationMessage struct {
+	Source string `json:"s"`
+	Key    string `json:"k,omitempty"`
+	Tag    string `json:"t,omitempty"`
+}
+
+// Invalidate announces that key has been written by source.
+func (b *InvalidationBus) Invalidate(ctx context.Context, source string, key string) error {
+	return b.publish(ctx, invalidationMessage{Source: source, Key: key})
+}
+
+// InvalidateTag announces that every entry carrying tag is stale. It is
+// delivered to the listeners implementing TagListener.
+func (b *InvalidationBus) InvalidateTag(ctx context.Context, source string, tag string) error {
+	return b.publish(ctx, invalidationMessage{Source: source, Tag: tag})
+}
+
+// Subscribe registers l to receive the invalidations of other sources.
+func (b *InvalidationBus) Subscribe(source string, l tiered.Listener[string]) error {
+	if l == nil {
+		return errors.New("invalidation bus: missing listener")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners[source] = append(b.listeners[source], l)
+	return nil
+}
+
+// Unsubscribe stops the delivery of the invalidations to l.
+func (b *InvalidationBus) Unsubscribe(source string, l tiered.Listener[string]) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners[source] = slices.DeleteFunc(b.listeners[source], func(r tiered.Listener[string]) bool { return r == l })
+	if len(b.listeners[source]) == 0 {
+		delete(b.listeners, source)
+	}
+	return nil
+}
+
+// Close stops the subscription and closes the connection to redis.
+func (b *InvalidationBus) Close() error {
+	b.cancel()
+	err := b.ps.Close()
+	<-b.done
+	return errors.Join(err, b.client.Close())
+}
+
+func (b *InvalidationBus) publish(ctx context.Context, msg invalidationMessage) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("invalidation bus: %w", err)
+	}
+	if err := b.client.Publish(ctx, b.channel, payload).Err(); err != nil {
+		return fmt.Errorf("invalidation bus: unable to publish: %w", err)
+	}
+	return nil
+}
+
+func (b *InvalidationBus) run(ctx context.Context) {
+	defer close(b.done)
+	missed := false
+	for {
+		msg, err := b.ps.ReceiveTimeout(ctx, invalidationHealthCheck)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				if b.ps.Ping(ctx) == nil {
+					continue
+				}
+			}
+			// the connection is lost, invalidations may have been missed
+			if !missed {
+				missed = true
+				b.flush()
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(invalidationRetryDelay):
+			}
+			continue
+		}
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			// the subscription is restored, flush what may have been
+			// cached while it was down
+			if missed && m.Kind == "subscribe" {
+				missed = false
+				b.flush()
+			}
+		case *redis.Message:
+			b.dispatch(m.Payload)
+		}
+	}
+}
+
+func (b *InvalidationBus) dispatch(payload string) {
+	var msg invalidationMessage
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		return // not an invalidation
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for source, listeners := range b.listeners {
+		if source == msg.Source {
+			continue
+		}
+		for _, l := range listeners {
+			if msg.Tag != "" {
+				if tl, ok := l.(TagListener); ok {
+					tl.EvictTag(msg.Tag)
+				}
+				continue
+			}
+			l.Evict(msg.Key)
+		}
+	}
+}
+
+func (b *InvalidationBus) flush() {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, listeners := range b.listeners {
+		for _, l := range listeners {
+			l.Flush()
+		}
+	}
+}
+
+// ClearableCache is a local cache which can drop an entry or be flushed.
+type ClearableCache[D any] interface {
+	memory.Cache[string, D]
+	memory.Deleter[string]
+	memory.Clearer
+}
+
+// CacheListener adapts a local cache, such as a memory.LRU, so it can be
+// registered on an InvalidationBus.
+func CacheListener[D any](c ClearableCache[D]) tiered.Listener[string] {
+	return &cacheListener[D]{c: c}
+}
+
+type cacheListener[D any] struct {
+	c ClearableCache[D]
+}
+
+func (l *cacheListener[D]) Evict(key string) {
+	l.c.Delete(key)
+}
+
+func (l *cacheListener[D]) Flush() {
+	l.c.Clear()
+}
+
+// TaggedListener adapts a memory.Tagged cache so it can be registered on an
+// InvalidationBus, it receives both key and tag invalidations.
+func TaggedListener[D any](t *memory.Tagged[string, D, string]) TagListener {
+	return &taggedListener[D]{t: t}
+}
+
+type taggedListener[D any] struct {
+	t *memory.Tagged[string, D, string]
+}
+
+func (l *taggedListener[D]) Evict(key string) {
+	l.t.Delete(key)
+}
+
+func (l *taggedListener[D]) EvictTag(tag string) {
+	l.t.InvalidateTag(tag)
+}
+
+func (l *taggedListener[D]) Flush() {
+	l.t.Clear()
+}
@@ -0,0 +1,161 @@
+package redis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/slawo/go-cache/codec"
+	"github.com/slawo/go-cache/memory"
+	"github.com/slawo/go-cache/redis"
+	"github.com/slawo/go-cache/tiered"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func NewInvalidationBus(t *testing.T, dsn string) *redis.InvalidationBus {
+	b, err := redis.NewInvalidationBus(t.Context(), "invalidations", redis.SynchroniserDSN(dsn))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, b.Close())
+	})
+	return b
+}
+
+func NewLocalCache(t *testing.T) *memory.LRU[string, int] {
+	c, err := memory.NewLRU[string, int](10)
+	require.NoError(t, err)
+	return c
+}
+
+func hasKey(c memory.Cache[string, int], key string) bool {
+	found, _ := c.Has(key)
+	return found
+}
+
+func TestNewInvalidationBusValidatesOptions(t *testing.T) {
+	_, err := redis.NewInvalidationBus(t.Context(), "", redis.SynchroniserDSN("localhost:6379"))
+	assert.EqualError(t, err, "invalidation bus: channel cannot be empty")
+	_, err = redis.NewInvalidationBus(t.Context(), "invalidations")
+	assert.EqualError(t, err, "invalidation bus: DSN cannot be empty")
+}
+
+func TestInvalidationBusImplementsInvalidator(t *testing.T) {
+	var inv tiered.Invalidator[string]
+	inv = NewInvalidationBus(t, NewServer(t))
+	assert.NotNil(t, inv)
+}
+
+func TestInvalidationBusEvictsKeys(t *testing.T) {
+	dsn := NewServer(t)
+	busA, busB := NewInvalidationBus(t, dsn), NewInvalidationBus(t, dsn)
+	localA, localB := NewLocalCache(t), NewLocalCache(t)
+	require.NoError(t, busA.Subscribe("a", redis.CacheListener[int](localA)))
+	require.NoError(t, busB.Subscribe("b", redis.CacheListener[int](localB)))
+	assert.EqualError(t, busA.Subscribe("a", nil), "invalidation bus: missing listener")
+
+	require.NoError(t, localA.Put("key", 1))
+	require.NoError(t, localB.Put("key", 1))
+	require.NoError(t, localB.Put("other", 2))
+
+	require.NoError(t, busA.Invalidate(t.Context(), "a", "key"))
+	require.Eventually(t, func() bool {
+		return !hasKey(localB, "key")
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, hasKey(localB, "other"))
+	assert.True(t, hasKey(localA, "key"), "the source should not evict its own entry")
+}
+
+func TestInvalidationBusEvictsTags(t *testing.T) {
+	dsn := NewServer(t)
+	busA, busB := NewInvalidationBus(t, dsn), NewInvalidationBus(t, dsn)
+	tagged, err := memory.NewTagged[string, int, string](NewLocalCache(t))
+	require.NoError(t, err)
+	local := NewLocalCache(t)
+	require.NoError(t, busB.Subscribe("b", redis.TaggedListener[int](tagged)))
+	require.NoError(t, busB.Subscribe("b", redis.CacheListener[int](local)))
+
+	require.NoError(t, tagged.Put("a1", 1, "tenant-a"))
+	require.NoError(t, tagged.Put("b1", 2, "tenant-b"))
+	require.NoError(t, local.Put("a1", 1))
+
+	require.NoError(t, busA.InvalidateTag(t.Context(), "a", "tenant-a"))
+	require.Eventually(t, func() bool {
+		found, _ := tagged.Has("a1")
+		return !found
+	}, 5*time.Second, 10*time.Millisecond)
+	found, err := tagged.Has("b1")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.True(t, hasKey(local, "a1"), "plain caches ignore tag invalidations")
+}
+
+func TestInvalidationBusWithTieredCache(t *testing.T) {
+	dsn := NewServer(t)
+	newTiered := func() *tiered.Cache[string, int] {
+		l2, err := redis.NewCache[string, int](t.Context(), redis.CacheOptions[string, int]{
+			Codec: codec.NewVersioned[int](codec.JSON[int]{}, 1),
+			Keys:  codec.StringKeys[string]{},
+		}, redis.SynchroniserDSN(dsn))
+		require.NoError(t, err)
+		t.Cleanup(func() { l2.Close() })
+		c, err := tiered.New[string, int](l2, tiered.Options[string]{
+			L1Capacity:  10,
+			Invalidator: NewInvalidationBus(t, dsn),
+		})
+		require.NoError(t, err)
+		return c
+	}
+	c1, c2 := newTiered(), newTiered()
+
+	require.NoError(t, c1.Put("key", 1))
+	v, err := c2.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	require.NoError(t, c1.Put("key", 2))
+	require.Eventually(t, func() bool {
+		v, err := c2.Get("key")
+		return err == nil && v == 2
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestInvalidationBusFlushesOnReconnect(t *testing.T) {
+	dsn := NewServer(t)
+	proxy := NewProxy(t, dsn)
+	bus := NewInvalidationBus(t, proxy.Addr())
+	local := NewLocalCache(t)
+	require.NoError(t, bus.Subscribe("local", redis.CacheListener[int](local)))
+
+	require.NoError(t, local.Put("key", 1))
+	proxy.DropConnections()
+	require.Eventually(t, func() bool {
+		return !hasKey(local, "key")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// once resubscribed, invalidations are received again
+	publisher := NewInvalidationBus(t, dsn)
+	require.Eventually(t, func() bool {
+		require.NoError(t, local.Put("key", 1))
+		require.NoError(t, publisher.Invalidate(t.Context(), "remote", "key"))
+		time.Sleep(20 * time.Millisecond)
+		return !hasKey(local, "key")
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestInvalidationBusUnsubscribe(t *testing.T) {
+	dsn := NewServer(t)
+	busA, busB := NewInvalidationBus(t, dsn), NewInvalidationBus(t, dsn)
+	closed, open := NewLocalCache(t), NewLocalCache(t)
+	listener := redis.CacheListener[int](closed)
+	require.NoError(t, busB.Subscribe("b", listener))
+	require.NoError(t, busB.Subscribe("b", redis.CacheListener[int](open)))
+	require.NoError(t, busB.Unsubscribe("b", listener))
+
+	require.NoError(t, closed.Put("key", 1))
+	require.NoError(t, open.Put("key", 1))
+	require.NoError(t, busA.Invalidate(t.Context(), "a", "key"))
+	require.Eventually(t, func() bool {
+		return !hasKey(open, "key")
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, hasKey(closed, "key"), "an unsubscribed listener should not be invalidated")
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -13,7 +13,7 @@ import (
 
 func NewWriteLock(
 	ctx context.Context,
-	client *redis.Client,
+	client redis.UniversalClient,
 	lockKey, lockValue string,
 	timeoutSeconds int) (*WriteLock, error) {
 	if client == nil {
@@ -30,75 +30,152 @@ func NewWriteLock(
 		return nil, errors.New("lock key must be at least 3 characters long")
 	}
 
-	l := &WriteLock{
+	l := newRedisLock(client, lockKey, lockValue, timeoutSeconds, newObserver(SynchroniserOptions{}))
+	if err := setLock(ctx, l); err != nil {
+		return nil, err
+	}
+	l.start(ctx)
+	return &WriteLock{redisLock: l}, nil
+}
+
+func newRedisLock(client redis.UniversalClient, lockKey, lockValue string, timeoutSeconds int, obs observer) *redisLock {
+	return &redisLock{
 		client:         client,
+		obs:            obs,
 		lockKey:        lockKey,
 		lockValue:      lockValue,
 		stop:           make(chan struct{}),
 		unlocked:       make(chan struct{}),
+		lost:           make(chan struct{}),
 		timeoutSeconds: timeoutSeconds,
-		tk:             time.NewTicker(time.Duration(timeoutSeconds) * time.Second / 2), // Default timeout of 10 seconds
 	}
+}
 
-	if err := setLock(ctx, l); err != nil {
-		return nil, err
+// start refreshes the lock until it is unlocked. The refreshes outlive the
+// context used to acquire the lock.
+//
+// The lock is lost when a refresh finds it taken over, or when it could not
+// be refreshed before its validity elapsed, for example during a network
+// partition. Lost and WaitUnlocked are then closed. The validity is counted
+// from the start of the last successful refresh and kept shorter than the
+// TTL, so that the lock is reported lost before redis lets another holder
+// take it.
+func (l *redisLock) start(ctx context.Context) {
+	ctx = context.WithoutCancel(ctx)
+	timeout := time.Duration(l.timeoutSeconds) * time.Second
+	validity := timeout - timeout/10
+	l.tk = time.NewTicker(timeout / 3)
+	deadline := time.Now().Add(validity)
+	expiry := time.NewTimer(validity)
+	lose := func(err error) {
+		l.obs.event(ctx, "lock lost", l.lockKey, l.lockValue, err)
+		l.tk.Stop()
+		close(l.lost)
+		close(l.unlocked)
 	}
-
 	go func() {
+		defer expiry.Stop()
 		for {
 			select {
-			case <-l.unlocked:
-				log.Default().Printf("lock %s has been unlocked", l.lockKey)
-				return // Stop the goroutine when unlocked
 			case <-l.stop:
 				l.tk.Stop()
-				err := releaseLock(ctx, l)
-				if err != nil {
-					log.Default().Printf("failed to release lock: %v", err)
-				} // Close the channel to signal unlocked
+				done := l.obs.release(ctx, l.lockKey, l.lockValue)
+				done(releaseLock(ctx, l))
 				close(l.unlocked)
 				return
+			case <-expiry.C:
+				lose(fmt.Errorf("%w: %s: not refreshed in time", datastore.ErrLockLost, l.lockKey))
+				return
 			case <-l.tk.C:
-				if err := setLock(ctx, l); err != nil {
-					log.Default().Printf("failed to refresh lock: %v", err)
-				} else {
-					log.Default().Printf("lock refreshed: %s", l.lockKey)
-
+				attempt := time.Now()
+				// a refresh still pending at the deadline cannot save the lock
+				rctx, cancel := context.WithDeadline(ctx, deadline)
+				res := make(chan error, 1)
+				go func() { res <- l.refresh(rctx) }()
+				var err error
+				select {
+				case err = <-res:
+				case <-expiry.C:
+					err = fmt.Errorf("%w: %s: not refreshed in time", datastore.ErrLockLost, l.lockKey)
+				}
+				cancel()
+				if err == nil {
+					deadline = attempt.Add(validity)
+					expiry.Reset(time.Until(deadline))
+					continue
+				}
+				if errors.Is(err, datastore.ErrLockLost) || !time.Now().Before(deadline) {
+					lose(err)
+					return
 				}
 			}
 		}
 	}()
-
-	return l, nil
 }
 
+// WriteLock is an exclusive lock stored in redis, it is refreshed in the
+// background until it is released.
 type WriteLock struct {
-	client         *redis.Client
+	*redisLock
+}
+
+// FencingToken returns the token issued when the lock was acquired.
+func (l *redisLock) FencingToken() uint64 {
+	return l.token
+}
+
+// redisLock holds the state shared by the write and read locks.
+type redisLock struct {
+	client         redis.UniversalClient
+	obs            observer
 	lockKey        string
 	lockValue      string
 	stop           chan struct{}
+	stopOnce       sync.Once
 	unlocked       chan struct{}
 	timeoutSeconds int
 	tk             *time.Ticker
+	lost           chan struct{}
+	// token is the fencing token issued when the lock was acquired
+	token uint64
+	// shared marks the locks backing a ReadLock or a Permit
+	shared           bool
+	writerPreference bool
+	// permits is the number of permits of the semaphore backing a Permit
+	permits int
 }
 
-func (l *WriteLock) Unlock() (err error) {
-	defer func() {
-		<-l.unlocked
-		if r := recover(); r != nil {
-			err = errors.New("failed to unlock: " + r.(string))
-		}
-	}()
+// ReadLock is a shared lock, it is refreshed and released like a WriteLock.
+// The holders of the read locks are stored in a sorted set next to the write
+// lock, scored by the expiry of each read lock.
+type ReadLock struct {
+	*redisLock
+}
+
+// newReadLock builds a read lock on the write lock with the given key. With
+// writer preference the lock is refused while callers of WaitWriteLock are
+// queued.
+func newReadLock(client redis.UniversalClient, lockKey, lockValue string, timeoutSeconds int, writerPreference bool, obs observer) *ReadLock {
+	l := newRedisLock(client, lockKey, lockValue, timeoutSeconds, obs)
+	l.shared = true
+	l.writerPreference = writerPreference
+	return &ReadLock{redisLock: l}
+}
+
+// Unlock releases the lock, it returns ErrLockLost if the lock was lost
+// before. Unlock can be called concurrently and more than once.
+func (l *redisLock) Unlock() error {
+	l.stopOnce.Do(func() { close(l.stop) })
+	<-l.unlocked
 	select {
-	case <-l.stop:
-		return nil
+	case <-l.lost:
+		return fmt.Errorf("%w: %s", datastore.ErrLockLost, l.lockKey)
 	default:
-		close(l.stop)
+		return nil
 	}
-	return nil
 }
 
-func (l *WriteLock) Unlocked() bool {
+func (l *redisLock) Unlocked() bool {
 	select {
 	case <-l.unlocked:
 		return true // The lock has been released
@@ -107,53 +184,250 @@ func (l *WriteLock) Unlocked() bool {
 	return false // The lock is still held
 }
 
-func (l *WriteLock) WaitUnlocked() <-chan struct{} {
+func (l *redisLock) WaitUnlocked() <-chan struct{} {
 	return l.unlocked
 }
 
-func setLock(ctx context.Context, l *WriteLock) error {
-	// Use a Lua script to ensure atomicity of the unlock operation
-	fmt.Printf("Setting lock: %s with value: %s and timeout: %d seconds\n", l.lockKey, l.lockValue, l.timeoutSeconds)
-	res := l.client.Eval(ctx, lockScript, []string{l.lockKey}, l.lockValue, l.timeoutSeconds)
+func (l *redisLock) Lost() <-chan struct{} {
+	return l.lost
+}
 
-	fmt.Printf("Setting lock: %s with value: %s result: %v, error: %v\n", l.lockKey, l.lockValue, res.Val(), res.Err())
+// Renew extends the TTL of the lock without waiting for the background
+// refresh.
+func (l *redisLock) Renew(ctx context.Context) error {
+	return l.refresh(ctx)
+}
+
+// refresh extends the TTL of the lock and reports the result.
+func (l *redisLock) refresh(ctx context.Context) error {
+	ctx, done := l.obs.refresh(ctx, l.lockKey, l.lockValue)
+	err := refreshLock(ctx, l)
+	done(err)
+	return err
+}
+
+func setLock(ctx context.Context, l *redisLock) (err error) {
+	ctx, done := l.obs.acquire(ctx, l.lockKey, l.lockValue)
+	defer func() { done(err) }()
+	res := l.eval(ctx, 0)
 	if res.Err() != nil {
 		if errors.Is(res.Err(), redis.Nil) {
-			fmt.Printf("Setting lock: %s with value: %s, FAILED (lock already held)\n", l.lockKey, l.lockValue)
-			return fmt.Errorf("%v: %s", datastore.ErrLockAlreadyHeld, l.lockKey)
+			return fmt.Errorf("%w: %s", datastore.ErrLockAlreadyHeld, l.lockKey)
 		}
 		return res.Err()
 	}
-	if res.Val() != "OK" {
-		fmt.Printf("Setting lock: %s with value: %s, FAILED\n", l.lockKey, l.lockValue)
-		return fmt.Errorf("%v: %s", datastore.ErrLockAlreadyHeld, l.lockKey)
+	if !l.acquired(res) {
+		return fmt.Errorf("%w: %s", datastore.ErrLockAlreadyHeld, l.lockKey)
 	}
-	fmt.Printf("Setting lock: %s with value: %s, SUCCESS\n", l.lockKey, l.lockValue)
 	return nil
 }
 
-func releaseLock(ctx context.Context, l *WriteLock) error {
+func releaseLock(ctx context.Context, l *redisLock) error {
 	// Use a Lua script to ensure atomicity of the unlock operation
-	fmt.Printf("Releasing lock: %s with value: %s\n", l.lockKey, l.lockValue)
-	res := l.client.Eval(ctx, unlockScript, []string{l.lockKey}, l.lockValue)
-	fmt.Printf("Releasing lock: %s, result: %v\n", l.lockKey, res.Val())
+	script := unlockScript
+	if l.shared {
+		script = readUnlockScript
+	}
+	res := l.client.Eval(ctx, script, l.keys(), l.lockValue, releasedChannel(l.lockKey))
 	if res.Err() != nil {
 		return fmt.Errorf("failed to delease lock: %w", res.Err())
 	}
 	return nil
 }
 
+// tryLock attempts to acquire the lock once for a caller of WaitWriteLock,
+// queueing the caller for waitMs milliseconds when the lock is not acquired.
+func tryLock(ctx context.Context, l *redisLock, waitMs int64) (bool, error) {
+	res := l.eval(ctx, waitMs)
+	if errors.Is(res.Err(), redis.Nil) {
+		return false, nil
+	} else if res.Err() != nil {
+		return false, res.Err()
+	}
+	return l.acquired(res), nil
+}
+
+// acquired checks the result of the acquisition scripts, and records the
+// fencing token returned for write locks.
+func (l *redisLock) acquired(res *redis.Cmd) bool {
+	if l.shared {
+		return res.Val() == "OK"
+	}
+	token, err := res.Uint64()
+	if err != nil || token == 0 {
+		return false
+	}
+	l.token = token
+	return true
+}
+
+// refreshLock extends the TTL of a held lock, it fails with ErrLockLost if
+// the lock is no longer held.
+func refreshLock(ctx context.Context, l *redisLock) error {
+	shared := 0
+	if l.shared {
+		shared = 1
+	}
+	err := l.client.Eval(ctx, refreshScript, l.keys(), l.lockValue, l.timeoutSeconds*1000, shared).Err()
+	if errors.Is(err, redis.Nil) {
+		return fmt.Errorf("%w: %s", datastore.ErrLockLost, l.lockKey)
+	}
+	return err
+}
+
+// leaveQueue removes a caller of WaitWriteLock giving up on the lock from
+// the queue, and wakes the other callers in case it was first in line.
+func leaveQueue(ctx context.Context, l *redisLock) error {
+	return l.client.Eval(ctx, leaveQueueScript, l.keys(), l.lockValue, releasedChannel(l.lockKey)).Err()
+}
+
+// eval runs the script acquiring the lock, waitMs is only used by write
+// locks.
+func (l *redisLock) eval(ctx context.Context, waitMs int64) *redis.Cmd {
+	if l.permits > 0 {
+		return l.client.Eval(ctx, semaphoreScript, l.keys(), l.lockValue, l.timeoutSeconds*1000, l.permits)
+	}
+	if l.shared {
+		preference := 0
+		if l.writerPreference {
+			preference = 1
+		}
+		return l.client.Eval(ctx, readLockScript, l.keys(), l.lockValue, l.timeoutSeconds*1000, preference)
+	}
+	return l.client.Eval(ctx, lockScript, l.keys(), l.lockValue, l.timeoutSeconds*1000, waitMs, fencingTTL.Milliseconds())
+}
+
+// keys returns the lock key followed by the keys of the waiting queue, of
+// the read locks and of the fencing token counter.
+func (l *redisLock) keys() []string {
+	return lockKeys(l.lockKey)
+}
+
+func lockKeys(lockKey string) []string {
+	return []string{lockKey, lockKey + ":queue", lockKey + ":deadlines", lockKey + ":readers", lockKey + ":fence"}
+}
+
+// releasedChannel is the channel notified when a lock is released.
+func releasedChannel(lockKey string) string {
+	return lockKey + ":released"
+}
+
+// fencingTTL is how long the fencing token counter of a lock is kept after
+// the last acquisition, far longer than any lease.
+const fencingTTL = 7 * 24 * time.Hour
+
+// lockScript acquires a lock and returns its fencing token.
+//
+// KEYS[1] is the lock, KEYS[2] the list of the callers waiting for the lock,
+// KEYS[3] a sorted set with the deadline of each waiting caller, KEYS[4] a
+// sorted set with the expiry of each read lock and KEYS[5] the counter of
+// the fencing tokens. ARGV[1] is the lock value, ARGV[2] the lock TTL,
+// ARGV[3] how long the caller waits in the queue when the lock is not
+// acquired and ARGV[4] the TTL of the counter, all in milliseconds.
+// The counter expires once the lock was not acquired for its TTL, so that
+// the lock IDs which are no longer used do not leave keys behind. A missing
+// counter starts from the server time in microseconds, the tokens keep
+// increasing across its expiry.
+// The callers which did not renew their place before their deadline are
+// dropped from the queue. The lock is only acquired when no read lock is
+// held and the queue is empty or the caller is first in line.
 const lockScript = `
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local head = redis.call("LINDEX", KEYS[2], 0)
+while head do
+	if tonumber(redis.call("ZSCORE", KEYS[3], head) or "0") > now then
+		break
+	end
+	redis.call("LPOP", KEYS[2])
+	redis.call("ZREM", KEYS[3], head)
+	head = redis.call("LINDEX", KEYS[2], 0)
+end
+redis.call("ZREMRANGEBYSCORE", KEYS[4], "-inf", now)
+local free = redis.call("EXISTS", KEYS[1]) == 0 and redis.call("ZCARD", KEYS[4]) == 0
+if free and (not head or head == ARGV[1]) then
+	if head then
+		redis.call("LPOP", KEYS[2])
+		redis.call("ZREM", KEYS[3], head)
+	end
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	redis.call("SET", KEYS[5], t[1] .. string.sub("00000" .. t[2], -6), "NX")
+	local token = redis.call("INCR", KEYS[5])
+	redis.call("PEXPIRE", KEYS[5], ARGV[4])
+	return token
+end
+local wait = tonumber(ARGV[3])
+if wait > 0 then
+	if not redis.call("ZSCORE", KEYS[3], ARGV[1]) then
+		redis.call("RPUSH", KEYS[2], ARGV[1])
+	end
+	redis.call("ZADD", KEYS[3], now + wait, ARGV[1])
+	redis.call("PEXPIRE", KEYS[2], wait)
+	redis.call("PEXPIRE", KEYS[3], wait)
+end
+return false
+`
+
+// readLockScript acquires a read lock, the keys are the same as for
+// lockScript. ARGV[1] is the lock value, ARGV[2] the lock TTL in
+// milliseconds and ARGV[3] is 1 to refuse the lock while writers wait.
+const readLockScript = `
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local ttl = tonumber(ARGV[2])
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return false
+end
+if ARGV[3] == "1" and redis.call("ZCOUNT", KEYS[3], "(" .. now, "+inf") > 0 then
+	return false
+end
+redis.call("ZREMRANGEBYSCORE", KEYS[4], "-inf", now)
+redis.call("ZADD", KEYS[4], now + ttl, ARGV[1])
+redis.call("PEXPIRE", KEYS[4], ttl)
+return "OK"
+`
+
+// refreshScript extends the TTL of a lock, it returns nil if the lock is no
+// longer held. The keys are the same as for lockScript, ARGV[1] is the lock
+// value, ARGV[2] the lock TTL in milliseconds and ARGV[3] is 1 for read
+// locks.
+const refreshScript = `
+local ttl = tonumber(ARGV[2])
+if ARGV[3] == "1" then
+	local t = redis.call("TIME")
+	local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+	local expiry = redis.call("ZSCORE", KEYS[4], ARGV[1])
+	if not expiry or tonumber(expiry) <= now then
+		return false
+	end
+	redis.call("ZADD", KEYS[4], now + ttl, ARGV[1])
+	redis.call("PEXPIRE", KEYS[4], ttl)
+	return "OK"
+end
 if redis.call("GET", KEYS[1]) == ARGV[1] then
-    redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
-    return "OK"
-else
-    return redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2])
+	redis.call("PEXPIRE", KEYS[1], ttl)
+	return "OK"
 end
+return false
+`
+
+const readUnlockScript = `
+if redis.call("ZREM", KEYS[4], ARGV[1]) == 1 then
+	redis.call("PUBLISH", ARGV[2], "")
+end
+return 0
+`
+
+const leaveQueueScript = `
+redis.call("LREM", KEYS[2], 0, ARGV[1])
+redis.call("ZREM", KEYS[3], ARGV[1])
+redis.call("PUBLISH", ARGV[2], "")
+return 0
 `
 
 const unlockScript = `
 if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("PUBLISH", ARGV[2], "")
 	return redis.call("DEL", KEYS[1])
 else
 	return 0
@@ -0,0 +1,313 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slawo/go-cache/datastore"
+)
+
+// markPartScript sets a part in the bitmap of a file and returns the
+// updated completion data, or a negative status if the file has no
+// completion data (-1) or if the part is out of range (-2).
+//
+// KEYS[1] is the completion hash, KEYS[2] the parts bitmap. ARGV[1] is the
+// part, ARGV[2] the update time and ARGV[3] the bound of the parts of the
+// files with an unknown number of parts.
+var markPartScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return {-1}
+end
+local part = tonumber(ARGV[1])
+local total = tonumber(redis.call("HGET", KEYS[1], "totalParts") or "0")
+if total <= 0 then
+	total = tonumber(ARGV[3])
+end
+if part < 0 or part >= total then
+	return {-2}
+end
+if redis.call("SETBIT", KEYS[2], part, 1) == 0 then
+	redis.call("HSET", KEYS[1], "updatedAt", ARGV[2])
+end
+local fields = redis.call("HMGET", KEYS[1], "partSize", "totalParts", "updatedAt")
+return {0, fields[1] or "", fields[2] or "", fields[3] or "", redis.call("GET", KEYS[2]), redis.call("BITCOUNT", KEYS[2])}
+`)
+
+// NewMetaDataStore instantiates a metadata store shared by every node
+// connected to the same redis database.
+// The connection is configured with the same options as NewSynchroniser.
+func NewMetaDataStore(ctx context.Context, opts ...SynchroniserOption) (*MetaDataStore, error) {
+	o, err := applyOptions(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("meta data store: failed to apply option: %w", err)
+	}
+	if !o.hasServer() {
+		return nil, fmt.Errorf("meta data store: DSN cannot be empty")
+	}
+	prefix, err := namespacePrefix(o.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("meta data store: %w", err)
+	}
+	client, err := newClient(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	return &MetaDataStore{client: client, prefix: prefix}, nil
+}
+
+// MetaDataStore is a datastore.MetaDataStore stored in redis.
+//
+// The metadata of a file is stored in a hash. The completion data is split
+// between a hash and a bitmap updated with SETBIT, PartsCompleted is always
+// computed with BITCOUNT. The keys of a file share a hash tag so that they
+// can be updated atomically on a cluster. The keys are prefixed with the
+// namespace set with SynchroniserNamespace.
+type MetaDataStore struct {
+	client redis.UniversalClient
+	// prefix is prepended to every key, it is built from the namespace
+	prefix string
+}
+
+// Close closes the connection to redis.
+func (s *MetaDataStore) Close() error {
+	return s.client.Close()
+}
+
+// GetFileMeta retrieves metadata for a file by its ID.
+func (s *MetaDataStore) GetFileMeta(ctx context.Context, fileId string) (*datastore.FileMeta, error) {
+	if strings.TrimSpace(fileId) == "" {
+		return nil, fmt.Errorf("%w: empty file ID", datastore.ErrInvalidFileID)
+	}
+	h, err := s.client.HGetAll(ctx, s.metaKey(fileId)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("meta data store: %w", err)
+	}
+	if len(h) == 0 {
+		return nil, fmt.Errorf("%w: %s", datastore.ErrFileNotFound, fileId)
+	}
+	m := &datastore.FileMeta{
+		FileId:      fileId,
+		Checksum:    h["checksum"],
+		ContentType: h["contentType"],
+		SourceURI:   h["sourceUri"],
+		Complete:    h["complete"] == "1",
+	}
+	if m.FileSize, err = parseInt(h["fileSize"]); err != nil {
+		return nil, fmt.Errorf("meta data store: invalid file size: %w", err)
+	}
+	times := map[string]*time.Time{
+		"createdAt":      &m.CreatedAt,
+		"modifiedAt":     &m.ModifiedAt,
+		"lastAccessedAt": &m.LastAccessedAt,
+		"expiresAt":      &m.ExpiresAt,
+	}
+	for field, t := range times {
+		if *t, err = parseTime(h[field]); err != nil {
+			return nil, fmt.Errorf("meta data store: invalid %s: %w", field, err)
+		}
+	}
+	return m, nil
+}
+
+// SaveFileMeta saves metadata for a file.
+func (s *MetaDataStore) SaveFileMeta(ctx context.Context, fileMeta *datastore.FileMeta) error {
+	if fileMeta == nil {
+		return errors.New("file metadata cannot be nil")
+	} else if strings.TrimSpace(fileMeta.FileId) == "" {
+		return fmt.Errorf("%w: empty file ID", datastore.ErrInvalidFileID)
+	}
+	complete := "0"
+	if fileMeta.Complete {
+		complete = "1"
+	}
+	values := []any{
+		"fileSize", fileMeta.FileSize,
+		"checksum", fileMeta.Checksum,
+		"contentType", fileMeta.ContentType,
+		"sourceUri", fileMeta.SourceURI,
+		"createdAt", formatTime(fileMeta.CreatedAt),
+		"modifiedAt", formatTime(fileMeta.ModifiedAt),
+		"lastAccessedAt", formatTime(fileMeta.LastAccessedAt),
+		"expiresAt", formatTime(fileMeta.ExpiresAt),
+		"complete", complete,
+	}
+	key := s.metaKey(fileMeta.FileId)
+	_, err := s.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, key)
+		p.HSet(ctx, key, values...)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("meta data store: %w", err)
+	}
+	return nil
+}
+
+// DeleteFileMeta removes the metadata and completion data of a file.
+func (s *MetaDataStore) DeleteFileMeta(ctx context.Context, fileId string) error {
+	if strings.TrimSpace(fileId) == "" {
+		return fmt.Errorf("%w: empty file ID", datastore.ErrInvalidFileID)
+	}
+	err := s.client.Del(ctx, s.metaKey(fileId), s.completionKey(fileId), s.partsKey(fileId)).Err()
+	if err != nil {
+		return fmt.Errorf("meta data store: %w", err)
+	}
+	return nil
+}
+
+// GetFileCompletionData retrieves completion data for a file by its ID.
+func (s *MetaDataStore) GetFileCompletionData(ctx context.Context, fileId string) (*datastore.FileCompletionData, error) {
+	if strings.TrimSpace(fileId) == "" {
+		return nil, fmt.Errorf("%w: empty file ID", datastore.ErrInvalidFileID)
+	}
+	var (
+		fields *redis.SliceCmd
+		parts  *redis.StringCmd
+		count  *redis.IntCmd
+	)
+	_, err := s.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		fields = p.HMGet(ctx, s.completionKey(fileId), "partSize", "totalParts", "updatedAt")
+		parts = p.Get(ctx, s.partsKey(fileId))
+		count = p.BitCount(ctx, s.partsKey(fileId), nil)
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("meta data store: %w", err)
+	}
+	values := make([]string, 0, 3)
+	for _, v := range fields.Val() {
+		str, _ := v.(string)
+		values = append(values, str)
+	}
+	if values[0] == "" {
+		return nil, fmt.Errorf("%w: %s", datastore.ErrFileNotFound, fileId)
+	}
+	return newCompletionData(fileId, values, []byte(parts.Val()), count.Val())
+}
+
+// SaveFileCompletionData saves completion data for a file. PartsCompleted is
+// not stored, it is computed from Parts.
+func (s *MetaDataStore) SaveFileCompletionData(ctx context.Context, completionData *datastore.FileCompletionData) error {
+	if completionData == nil {
+		return errors.New("completion data cannot be nil")
+	} else if strings.TrimSpace(completionData.FileId) == "" {
+		return fmt.Errorf("%w: empty file ID", datastore.ErrInvalidFileID)
+	}
+	c := completionData
+	_, err := s.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, s.completionKey(c.FileId), s.partsKey(c.FileId))
+		p.HSet(ctx, s.completionKey(c.FileId),
+			"partSize", c.PartSize,
+			"totalParts", c.TotalParts,
+			"updatedAt", formatTime(c.UpdatedAt),
+		)
+		if len(c.Parts) > 0 {
+			p.Set(ctx, s.partsKey(c.FileId), c.Parts, 0)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("meta data store: %w", err)
+	}
+	return nil
+}
+
+// MarkPartCompleted atomically sets a part in the completion data of a
+// file.
+func (s *MetaDataStore) MarkPartCompleted(ctx context.Context, fileId string, part int) (*datastore.FileCompletionData, error) {
+	if strings.TrimSpace(fileId) == "" {
+		return nil, fmt.Errorf("%w: empty file ID", datastore.ErrInvalidFileID)
+	}
+	keys := []string{s.completionKey(fileId), s.partsKey(fileId)}
+	res, err := markPartScript.Run(ctx, s.client, keys, part, formatTime(time.Now()), datastore.MaxParts).Slice()
+	if err != nil {
+		return nil, fmt.Errorf("meta data store: %w", err)
+	}
+	status, _ := res[0].(int64)
+	switch {
+	case status == -1:
+		return nil, fmt.Errorf("%w: %s", datastore.ErrFileNotFound, fileId)
+	case status == -2:
+		return nil, fmt.Errorf("%w: %d", datastore.ErrInvalidPart, part)
+	case len(res) != 6:
+		return nil, fmt.Errorf("meta data store: unexpected script result %v", res)
+	}
+	values := make([]string, 0, 3)
+	for _, v := range res[1:4] {
+		str, _ := v.(string)
+		values = append(values, str)
+	}
+	parts, _ := res[4].(string)
+	count, _ := res[5].(int64)
+	return newCompletionData(fileId, values, []byte(parts), count)
+}
+
+// newCompletionData decodes the partSize, totalParts and updatedAt fields
+// of a completion hash.
+func newCompletionData(fileId string, values []string, parts []byte, count int64) (*datastore.FileCompletionData, error) {
+	c := &datastore.FileCompletionData{
+		FileId:         fileId,
+		PartsCompleted: int(count),
+	}
+	if len(parts) > 0 {
+		c.Parts = parts
+	}
+	var err error
+	if c.PartSize, err = parseInt(values[0]); err != nil {
+		return nil, fmt.Errorf("meta data store: invalid part size: %w", err)
+	}
+	totalParts, err := parseInt(values[1])
+	if err != nil {
+		return nil, fmt.Errorf("meta data store: invalid total parts: %w", err)
+	}
+	c.TotalParts = int(totalParts)
+	if c.UpdatedAt, err = parseTime(values[2]); err != nil {
+		return nil, fmt.Errorf("meta data store: invalid update time: %w", err)
+	}
+	return c, nil
+}
+
+func (s *MetaDataStore) metaKey(fileId string) string {
+	return s.fileKey(fileId, "meta")
+}
+
+func (s *MetaDataStore) completionKey(fileId string) string {
+	return s.fileKey(fileId, "completion")
+}
+
+func (s *MetaDataStore) partsKey(fileId string) string {
+	return s.fileKey(fileId, "parts")
+}
+
+// fileKey returns the key of a file, the hash tag keeps the keys of a file
+// in the same cluster slot.
+func (s *MetaDataStore) fileKey(fileId, suffix string) string {
+	return s.prefix + "file:{" + hashTagEscaper.Replace(fileId) + "}:" + suffix
+}
+
+func parseInt(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// formatTime encodes a time, the zero time is encoded as an empty string.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
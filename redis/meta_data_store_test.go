@@ -0,0 +1,112 @@
+package redis_test
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/slawo/go-cache/datastore"
+	"github.com/slawo/go-cache/datastore/tests"
+	"github.com/slawo/go-cache/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func NewMetaDataStore(t *testing.T, dsn string, opts ...redis.SynchroniserOption) *redis.MetaDataStore {
+	s, err := redis.NewMetaDataStore(t.Context(), append([]redis.SynchroniserOption{redis.SynchroniserDSN(dsn)}, opts...)...)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, s.Close())
+	})
+	return s
+}
+
+func TestNewMetaDataStoreFailsWithoutDSN(t *testing.T) {
+	s, err := redis.NewMetaDataStore(t.Context())
+	assert.EqualError(t, err, "meta data store: DSN cannot be empty")
+	assert.Nil(t, s)
+}
+
+func TestMetaDataStoreTests(t *testing.T) {
+	dsn := NewServer(t)
+	// every store gets its own database as the tests run in parallel
+	var db atomic.Int32
+	tests.RunMetaDataStoreTests(t, tests.MetaDataStoreTestsOpts{
+		NewMetaDataStore: func(ctx context.Context, t *testing.T) (datastore.MetaDataStore, error) {
+			return NewMetaDataStore(t, dsn, redis.SynchroniserDB(int(db.Add(1)))), nil
+		},
+	})
+}
+
+func TestMetaDataStoreIsShared(t *testing.T) {
+	dsn := NewServer(t)
+	node1 := NewMetaDataStore(t, dsn)
+	node2 := NewMetaDataStore(t, dsn)
+
+	meta := &datastore.FileMeta{FileId: "file.bin", FileSize: 100, CreatedAt: time.Now()}
+	require.NoError(t, node1.SaveFileMeta(t.Context(), meta))
+	got, err := node2.GetFileMeta(t.Context(), "file.bin")
+	require.NoError(t, err)
+	tests.AssertFileMetaEqual(t, meta, got)
+
+	require.NoError(t, node1.SaveFileCompletionData(t.Context(), &datastore.FileCompletionData{FileId: "file.bin", PartSize: 10, TotalParts: 10}))
+	_, err = node1.MarkPartCompleted(t.Context(), "file.bin", 3)
+	require.NoError(t, err)
+	completion, err := node2.MarkPartCompleted(t.Context(), "file.bin", 9)
+	require.NoError(t, err)
+	assert.Equal(t, 2, completion.PartsCompleted)
+	assert.Equal(t, []byte{0x10, 0x40}, completion.Parts)
+
+	require.NoError(t, node2.DeleteFileMeta(t.Context(), "file.bin"))
+	_, err = node1.GetFileCompletionData(t.Context(), "file.bin")
+	assert.ErrorIs(t, err, datastore.ErrFileNotFound)
+}
+
+func TestMetaDataStoreNamespaces(t *testing.T) {
+	dsn := NewServer(t)
+	a := NewMetaDataStore(t, dsn, redis.SynchroniserNamespace("appA"))
+	b := NewMetaDataStore(t, dsn, redis.SynchroniserNamespace("appB"))
+
+	require.NoError(t, a.SaveFileMeta(t.Context(), &datastore.FileMeta{FileId: "file.bin", FileSize: 100}))
+	_, err := b.GetFileMeta(t.Context(), "file.bin")
+	assert.ErrorIs(t, err, datastore.ErrFileNotFound)
+
+	client := goredis.NewClient(&goredis.Options{Addr: dsn})
+	defer client.Close()
+	keys, err := client.Keys(t.Context(), "*").Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"appA:file:{file.bin}:meta"}, keys)
+}
+
+func TestMetaDataStoreEscapesHashTags(t *testing.T) {
+	dsn := NewServer(t)
+	s := NewMetaDataStore(t, dsn)
+	fileIds := []string{"}file", "{file", "a}b", "a%7Db", "{}"}
+	for i, fileId := range fileIds {
+		require.NoError(t, s.SaveFileMeta(t.Context(), &datastore.FileMeta{FileId: fileId, FileSize: int64(i + 1)}))
+		require.NoError(t, s.SaveFileCompletionData(t.Context(), &datastore.FileCompletionData{FileId: fileId, PartSize: 10, TotalParts: 2}))
+		_, err := s.MarkPartCompleted(t.Context(), fileId, 1)
+		require.NoError(t, err)
+	}
+	for i, fileId := range fileIds {
+		meta, err := s.GetFileMeta(t.Context(), fileId)
+		require.NoError(t, err)
+		assert.Equal(t, int64(i+1), meta.FileSize, fileId)
+	}
+
+	client := goredis.NewClient(&goredis.Options{Addr: dsn})
+	defer client.Close()
+	keys, err := client.Keys(t.Context(), "*").Result()
+	require.NoError(t, err)
+	assert.Len(t, keys, 3*len(fileIds))
+	for _, key := range keys {
+		// the hash tag of every key is a whole escaped file ID
+		tag := key[strings.Index(key, "{")+1 : strings.Index(key, "}")]
+		assert.NotContains(t, tag, "{", key)
+		assert.NotEmpty(t, tag, key)
+		assert.Equal(t, "}:", key[len("file:{")+len(tag):len("file:{")+len(tag)+2], key)
+	}
+}
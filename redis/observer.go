@@ -0,0 +1,108 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/slawo/go-cache/datastore"
+)
+
+// LockTracer is notified of the acquisitions and refreshes of the locks, for
+// example to record them as tracing spans. The functions returned by the
+// Start methods are called with the result of the operation, the returned
+// context is used for the requests of the operation.
+type LockTracer interface {
+	StartAcquire(ctx context.Context, key, holder string) (context.Context, func(err error))
+	StartRefresh(ctx context.Context, key, holder string) (context.Context, func(err error))
+}
+
+// observer reports the lifecycle events of the locks to the logger and the
+// tracer of a synchroniser.
+type observer struct {
+	logger *slog.Logger
+	tracer LockTracer
+}
+
+// newObserver uses the logger of the options or slog.Default. The routine
+// events are logged at debug level and the failures at warning level.
+func newObserver(o SynchroniserOptions) observer {
+	logger := o.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return observer{logger: logger, tracer: o.Tracer}
+}
+
+// acquire starts the tracing of the acquisition of a lock, the returned
+// function logs its result.
+func (o observer) acquire(ctx context.Context, key, holder string) (context.Context, func(err error)) {
+	end := noTrace
+	if o.tracer != nil {
+		ctx, end = o.tracer.StartAcquire(ctx, key, holder)
+	}
+	return ctx, o.done(ctx, "lock acquire", key, holder, end)
+}
+
+// refresh starts the tracing of the refresh of a lock, the returned function
+// logs its result.
+func (o observer) refresh(ctx context.Context, key, holder string) (context.Context, func(err error)) {
+	end := noTrace
+	if o.tracer != nil {
+		ctx, end = o.tracer.StartRefresh(ctx, key, holder)
+	}
+	return ctx, o.done(ctx, "lock refresh", key, holder, end)
+}
+
+// release returns a function logging the result of the release of a lock.
+func (o observer) release(ctx context.Context, key, holder string) func(err error) {
+	return o.done(ctx, "lock release", key, holder, noTrace)
+}
+
+// event logs an event without latency, such as the loss of a lock.
+func (o observer) event(ctx context.Context, msg, key, holder string, err error) {
+	o.log(ctx, msg, err, slog.String("key", key), slog.String("holder", holder))
+}
+
+func (o observer) done(ctx context.Context, msg, key, holder string, end func(error)) func(err error) {
+	start := time.Now()
+	return func(err error) {
+		end(err)
+		o.log(ctx, msg, err,
+			slog.String("key", key),
+			slog.String("holder", holder),
+			slog.Duration("latency", time.Since(start)))
+	}
+}
+
+// log adds the result of the operation to attrs, an already held lock is an
+// expected result logged at debug level.
+func (o observer) log(ctx context.Context, msg string, err error, attrs ...slog.Attr) {
+	level := slog.LevelDebug
+	attrs = append(attrs, slog.String("result", result(err)))
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+		if !errors.Is(err, datastore.ErrLockAlreadyHeld) {
+			level = slog.LevelWarn
+		}
+	}
+	o.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+func result(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, datastore.ErrLockAlreadyHeld):
+		return "held"
+	case errors.Is(err, datastore.ErrLockLost):
+		return "lost"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	default:
+		return "error"
+	}
+}
+
+func noTrace(error) {}
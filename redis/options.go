@@ -1,16 +1,58 @@
 package redis
 
-import "errors"
+import (
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
 
 type SynchroniserOption interface {
 	Apply(*SynchroniserOptions) error
 }
 
 type SynchroniserOptions struct {
-	DSN                string
-	Password           string
-	DB                 int
+	DSN string
+	// Addrs are the addresses of the nodes of a cluster, or of the sentinels
+	// when MasterName is set. DSN is ignored when they are set.
+	Addrs []string
+	// MasterName is the name of the master monitored by the sentinels.
+	MasterName string
+	// Cluster connects to a cluster even with a single address.
+	Cluster          bool
+	Username         string
+	Password         string
+	SentinelUsername string
+	SentinelPassword string
+	DB               int
+	TLSConfig        *tls.Config
+	PoolSize         int
+	MinIdleConns     int
+	DialTimeout      time.Duration
+	ReadTimeout      time.Duration
+	WriteTimeout     time.Duration
+	// Client is an externally constructed client used instead of connecting
+	// with the options above, it is not closed with the values using it.
+	Client             redis.UniversalClient
 	LockTimeoutSeconds int // in seconds
+	// Namespace prefixes the keys of the locks and of the metadata, so that
+	// applications sharing a database do not collide.
+	Namespace string
+	// WriterPreference refuses read locks while writers wait for the lock.
+	WriterPreference bool
+	// Nodes are the addresses of the independent servers of a
+	// QuorumSynchroniser.
+	Nodes []string
+	// ClockDriftFactor is the part of the lock TTL kept as an allowance for
+	// the clock drift between the servers of a QuorumSynchroniser.
+	ClockDriftFactor float64
+	// Logger receives the lifecycle events of the locks, slog.Default is used
+	// when it is nil.
+	Logger *slog.Logger
+	// Tracer is notified of the acquisitions and refreshes of the locks.
+	Tracer LockTracer
 }
 
 type SynchroniserOptionFunc func(*SynchroniserOptions) error
@@ -46,6 +88,112 @@ func SynchroniserPassword(password string) SynchroniserOption {
 	})
 }
 
+// SynchroniserUsername sets the ACL user name.
+func SynchroniserUsername(username string) SynchroniserOption {
+	return SynchroniserOptionFunc(func(opts *SynchroniserOptions) error {
+		if username == "" {
+			return errors.New("username cannot be empty")
+		}
+		opts.Username = username
+		return nil
+	})
+}
+
+// SynchroniserTLS enables TLS with the given configuration.
+func SynchroniserTLS(config *tls.Config) SynchroniserOption {
+	return SynchroniserOptionFunc(func(opts *SynchroniserOptions) error {
+		if config == nil {
+			return errors.New("tls config cannot be nil")
+		}
+		opts.TLSConfig = config
+		return nil
+	})
+}
+
+// SynchroniserCluster connects to the cluster with the given nodes.
+func SynchroniserCluster(addrs ...string) SynchroniserOption {
+	return SynchroniserOptionFunc(func(opts *SynchroniserOptions) error {
+		if len(addrs) == 0 {
+			return errors.New("cluster addresses cannot be empty")
+		}
+		opts.Addrs = addrs
+		opts.Cluster = true
+		return nil
+	})
+}
+
+// SynchroniserSentinel connects to the master named masterName through the
+// given sentinels, username and password are the credentials of the
+// sentinels and can be empty.
+func SynchroniserSentinel(masterName string, addrs []string, username, password string) SynchroniserOption {
+	return SynchroniserOptionFunc(func(opts *SynchroniserOptions) error {
+		if masterName == "" {
+			return errors.New("master name cannot be empty")
+		}
+		if len(addrs) == 0 {
+			return errors.New("sentinel addresses cannot be empty")
+		}
+		opts.MasterName = masterName
+		opts.Addrs = addrs
+		opts.SentinelUsername = username
+		opts.SentinelPassword = password
+		return nil
+	})
+}
+
+// SynchroniserPool sets the maximum number of connections per node and the
+// minimum number of idle connections, zero keeps the default.
+func SynchroniserPool(size, minIdle int) SynchroniserOption {
+	return SynchroniserOptionFunc(func(opts *SynchroniserOptions) error {
+		if size < 0 || minIdle < 0 {
+			return errors.New("pool sizes cannot be negative")
+		}
+		opts.PoolSize = size
+		opts.MinIdleConns = minIdle
+		return nil
+	})
+}
+
+// SynchroniserTimeouts sets the dial, read and write timeouts, zero keeps
+// the default.
+func SynchroniserTimeouts(dial, read, write time.Duration) SynchroniserOption {
+	return SynchroniserOptionFunc(func(opts *SynchroniserOptions) error {
+		if dial < 0 || read < -2 || write < -2 {
+			return errors.New("invalid timeout")
+		}
+		opts.DialTimeout = dial
+		opts.ReadTimeout = read
+		opts.WriteTimeout = write
+		return nil
+	})
+}
+
+// SynchroniserClient uses an externally constructed client, such as a
+// *redis.Client, *redis.ClusterClient or *redis.Ring, instead of connecting
+// with the other options. The client is not closed with the values using
+// it.
+func SynchroniserClient(client redis.UniversalClient) SynchroniserOption {
+	return SynchroniserOptionFunc(func(opts *SynchroniserOptions) error {
+		if client == nil {
+			return errors.New("client cannot be nil")
+		}
+		opts.Client = client
+		return nil
+	})
+}
+
+// SynchroniserNamespace prefixes the keys of the locks and of the metadata
+// with namespace.
+func SynchroniserNamespace(namespace string) SynchroniserOption {
+	return SynchroniserOptionFunc(func(opts *SynchroniserOptions) error {
+		if namespace == "" {
+			return errors.New("namespace cannot be empty")
+		}
+		opts.Namespace = namespace
+		return nil
+	})
+}
+
 func SynchroniserLockTimeOut(timeOutSeconds int) SynchroniserOption {
 	return SynchroniserOptionFunc(func(opts *SynchroniserOptions) error {
 
@@ -53,3 +201,63 @@ func SynchroniserLockTimeOut(timeOutSeconds int) SynchroniserOption {
 		return nil
 	})
 }
+
+// SynchroniserWriterPreference makes read locks wait, or fail, while callers
+// of WaitWriteLock are queued, so that readers cannot starve the writers.
+func SynchroniserWriterPreference(enabled bool) SynchroniserOption {
+	return SynchroniserOptionFunc(func(opts *SynchroniserOptions) error {
+		opts.WriterPreference = enabled
+		return nil
+	})
+}
+
+// SynchroniserNodes sets the addresses of the independent servers on which a
+// QuorumSynchroniser acquires its locks.
+func SynchroniserNodes(dsns ...string) SynchroniserOption {
+	return SynchroniserOptionFunc(func(opts *SynchroniserOptions) error {
+		for _, dsn := range dsns {
+			if dsn == "" {
+				return errors.New("node dsn cannot be empty")
+			}
+		}
+		opts.Nodes = append(opts.Nodes, dsns...)
+		return nil
+	})
+}
+
+// SynchroniserClockDrift sets the part of the lock TTL, between 0 and 1,
+// deducted from the validity of the locks of a QuorumSynchroniser to allow
+// for the clock drift between the servers.
+func SynchroniserClockDrift(factor float64) SynchroniserOption {
+	return SynchroniserOptionFunc(func(opts *SynchroniserOptions) error {
+		if factor < 0 || factor >= 1 {
+			return errors.New("clock drift factor must be between 0 and 1")
+		}
+		opts.ClockDriftFactor = factor
+		return nil
+	})
+}
+
+// SynchroniserLogger sets the logger receiving the lifecycle events of the
+// locks, with the lock key, holder, latency and result as attributes.
+func SynchroniserLogger(logger *slog.Logger) SynchroniserOption {
+	return SynchroniserOptionFunc(func(opts *SynchroniserOptions) error {
+		if logger == nil {
+			return errors.New("logger cannot be nil")
+		}
+		opts.Logger = logger
+		return nil
+	})
+}
+
+// SynchroniserTracer sets the hooks notified of the acquisitions and
+// refreshes of the locks.
+func SynchroniserTracer(tracer LockTracer) SynchroniserOption {
+	return SynchroniserOptionFunc(func(opts *SynchroniserOptions) error {
+		if tracer == nil {
+			return errors.New("tracer cannot be nil")
+		}
+		opts.Tracer = tracer
+		return nil
+	})
+}
@@ -0,0 +1,186 @@
+package redis_test
+
+import (
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slawo/go-cache/datastore"
+	"github.com/stretchr/testify/require"
+)
+
+// Proxy forwards TCP connections to a redis server, it allows tests to cut
+// or slow down the connections of a client without stopping the server.
+type Proxy struct {
+	ln          net.Listener
+	target      string
+	mu          sync.Mutex
+	conns       []net.Conn
+	partitioned bool
+	delay       time.Duration
+}
+
+func NewProxy(t *testing.T, target string) *Proxy {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	p := &Proxy{ln: ln, target: target}
+	t.Cleanup(func() {
+		ln.Close()
+		p.DropConnections()
+	})
+	go p.serve()
+	return p
+}
+
+// Addr returns the address clients should connect to.
+func (p *Proxy) Addr() string {
+	return p.ln.Addr().String()
+}
+
+// DropConnections closes every connection currently forwarded.
+func (p *Proxy) DropConnections() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range p.conns {
+		c.Close()
+	}
+	p.conns = nil
+}
+
+// Partition drops the connections and refuses the new ones until Heal is
+// called.
+func (p *Proxy) Partition() {
+	p.mu.Lock()
+	p.partitioned = true
+	p.mu.Unlock()
+	p.DropConnections()
+}
+
+// Heal accepts the connections again after a Partition.
+func (p *Proxy) Heal() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.partitioned = false
+}
+
+// Delay holds the replies of the server for d before forwarding them.
+func (p *Proxy) Delay(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.delay = d
+}
+
+func (p *Proxy) latency() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.delay
+}
+
+func (p *Proxy) serve() {
+	for {
+		client, err := p.ln.Accept()
+		if err != nil {
+			return
+		}
+		server, err := net.Dial("tcp", p.target)
+		if err != nil {
+			client.Close()
+			continue
+		}
+		p.mu.Lock()
+		if p.partitioned {
+			p.mu.Unlock()
+			client.Close()
+			server.Close()
+			continue
+		}
+		p.conns = append(p.conns, client, server)
+		p.mu.Unlock()
+		go pipe(server, client)
+		go p.reply(client, server)
+	}
+}
+
+// reply forwards the replies of the server once the delay elapsed.
+func (p *Proxy) reply(dst, src net.Conn) {
+	defer dst.Close()
+	defer src.Close()
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			time.Sleep(p.latency())
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func pipe(dst, src net.Conn) {
+	io.Copy(dst, src)
+	dst.Close()
+	src.Close()
+}
+
+// proxiedNodes routes every synchroniser through its own proxies, so that
+// the faults of the lock harness only hit a single node.
+type proxiedNodes struct {
+	mu      sync.Mutex
+	proxies map[datastore.DataSynchroniser][]*Proxy
+}
+
+func newProxiedNodes() *proxiedNodes {
+	return &proxiedNodes{proxies: make(map[datastore.DataSynchroniser][]*Proxy)}
+}
+
+func (n *proxiedNodes) add(s datastore.DataSynchroniser, proxies ...*Proxy) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.proxies[s] = proxies
+}
+
+func (n *proxiedNodes) get(t *testing.T, s datastore.DataSynchroniser) []*Proxy {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	proxies, ok := n.proxies[s]
+	require.True(t, ok, "unknown synchroniser")
+	return proxies
+}
+
+// Crash cuts the node off for good, its locks are neither refreshed nor
+// released.
+func (n *proxiedNodes) Crash(t *testing.T, s datastore.DataSynchroniser, lock datastore.DataWriteLock) {
+	for _, p := range n.get(t, s) {
+		p.Partition()
+	}
+}
+
+func (n *proxiedNodes) Partition(t *testing.T, s datastore.DataSynchroniser) func() {
+	proxies := n.get(t, s)
+	for _, p := range proxies {
+		p.Partition()
+	}
+	return func() {
+		for _, p := range proxies {
+			p.Heal()
+		}
+	}
+}
+
+func (n *proxiedNodes) SlowRefresh(t *testing.T, s datastore.DataSynchroniser, d time.Duration) func() {
+	proxies := n.get(t, s)
+	for _, p := range proxies {
+		p.Delay(d)
+	}
+	return func() {
+		for _, p := range proxies {
+			p.Delay(0)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/slawo/go-cache/datastore"
+)
+
+// defaultClockDriftFactor is the part of the lock TTL allowed for the clock
+// drift between the servers when none is configured.
+const defaultClockDriftFactor = 0.01
+
+// NewQuorumSynchroniser creates a synchroniser acquiring every lock on a
+// majority of independent servers, set with SynchroniserNodes, following
+// the Redlock algorithm. A majority of the servers must be reachable.
+func NewQuorumSynchroniser(ctx context.Context, opts ...SynchroniserOption) (*QuorumSynchroniser, error) {
+	o, err := applyOptions(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("synchroniser: failed to apply option: %w", err)
+	}
+	prefix, err := namespacePrefix(o.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("synchroniser: %w", err)
+	}
+	if len(o.Nodes) == 0 {
+		return nil, errors.New("synchroniser: nodes cannot be empty")
+	}
+	if o.LockTimeoutSeconds == 0 {
+		o.LockTimeoutSeconds = 6 // Default timeout of 6 seconds
+	}
+	if o.ClockDriftFactor == 0 {
+		o.ClockDriftFactor = defaultClockDriftFactor
+	}
+
+	obs := newObserver(o)
+	clients := make([]redis.UniversalClient, 0, len(o.Nodes))
+	reachable := 0
+	for _, dsn := range o.Nodes {
+		client := redis.NewUniversalClient(universalOptions(o, []string{dsn}))
+		clients = append(clients, client)
+		if err := client.Ping(ctx).Err(); err != nil {
+			obs.logger.LogAttrs(ctx, slog.LevelWarn, "node unreachable",
+				slog.String("node", dsn), slog.String("error", err.Error()))
+			continue
+		}
+		reachable++
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	s := &QuorumSynchroniser{
+		id:                 id.String(),
+		hostname:           hostname,
+		clients:            clients,
+		lockTimeoutSeconds: o.LockTimeoutSeconds,
+		clockDriftFactor:   o.ClockDriftFactor,
+		obs:                obs,
+		prefix:             prefix,
+	}
+	if reachable < s.quorum() {
+		for _, client := range clients {
+			client.Close()
+		}
+		return nil, fmt.Errorf("synchroniser: only %d of %d nodes are reachable", reachable, len(clients))
+	}
+	return s, nil
+}
+
+// QuorumSynchroniser is a datastore.DataSynchroniser holding its locks on a
+// majority of independent servers, so that the failure of a minority of
+// them cannot hand out the same lock twice.
+//
+// A lock is only valid for its TTL minus the time taken to acquire it and an
+// allowance for the clock drift, it is renewed in the background on a
+// majority of the servers and is lost when this fails before its validity
+// ends.
+type QuorumSynchroniser struct {
+	id                 string
+	hostname           string
+	clients            []redis.UniversalClient
+	lockTimeoutSeconds int
+	clockDriftFactor   float64
+	obs                observer
+	prefix             string
+}
+
+func (s *QuorumSynchroniser) GetWriteLock(ctx context.Context, lockID string) (_ datastore.DataWriteLock, err error) {
+	holder, err := newHolderValue(ctx, s.id, s.hostname)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(lockID) == "" || len(lockID) < 3 {
+		return nil, datastore.ErrInvalidLockID
+	}
+	l := &QuorumLock{
+		s:        s,
+		lockKey:  writeLockKey(s.prefix, lockID),
+		locks:    make([]*redisLock, len(s.clients)),
+		stop:     make(chan struct{}),
+		unlocked: make(chan struct{}),
+		lost:     make(chan struct{}),
+	}
+	for i, client := range s.clients {
+		l.locks[i] = newRedisLock(client, l.lockKey, holder, s.lockTimeoutSeconds, s.obs)
+	}
+	ctx, done := s.obs.acquire(ctx, l.lockKey, holder)
+	defer func() { done(err) }()
+
+	start := time.Now()
+	errs := l.each(ctx, func(ctx context.Context, nl *redisLock) error {
+		acquired, err := tryLock(ctx, nl, 0)
+		if err == nil && !acquired {
+			err = datastore.ErrLockAlreadyHeld
+		}
+		return err
+	})
+	validUntil := s.validUntil(start)
+	if count(errs, nil) < s.quorum() || !time.Now().Before(validUntil) {
+		l.release()
+		return nil, fmt.Errorf("%w: %s", datastore.ErrLockAlreadyHeld, l.lockKey)
+	}
+	l.validUntil = validUntil
+	l.start()
+	return l, nil
+}
+
+// Close closes the connections to the servers.
+func (s *QuorumSynchroniser) Close() error {
+	var errs []error
+	for _, client := range s.clients {
+		errs = append(errs, client.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// quorum is the number of servers on which a lock must be held.
+func (s *QuorumSynchroniser) quorum() int {
+	return len(s.clients)/2 + 1
+}
+
+func (s *QuorumSynchroniser) ttl() time.Duration {
+	return time.Duration(s.lockTimeoutSeconds) * time.Second
+}
+
+// nodeTimeout bounds each request to a server, so that an unreachable server
+// does not consume the validity of the lock.
+func (s *QuorumSynchroniser) nodeTimeout() time.Duration {
+	return s.ttl() / 10
+}
+
+// validUntil returns the end of the validity of a lock whose acquisition or
+// renewal started at start. The drift allowance covers the drift of the
+// server clocks, proportional to the TTL, and the granularity of their
+// expiry.
+func (s *QuorumSynchroniser) validUntil(start time.Time) time.Time {
+	drift := time.Duration(float64(s.ttl())*s.clockDriftFactor) + 2*time.Millisecond
+	return start.Add(s.ttl() - drift)
+}
+
+// QuorumLock is a lock held on a majority of the servers of a
+// QuorumSynchroniser.
+type QuorumLock struct {
+	s          *QuorumSynchroniser
+	lockKey    string
+	locks      []*redisLock
+	mu         sync.Mutex
+	validUntil time.Time
+	stopOnce   sync.Once
+	stop       chan struct{}
+	unlocked   chan struct{}
+	lost       chan struct{}
+}
+
+// start renews the lock until it is unlocked or lost.
+func (l *QuorumLock) start() {
+	go func() {
+		tk := time.NewTicker(l.s.ttl() / 3)
+		defer tk.Stop()
+		expiry := time.NewTimer(time.Until(l.ValidUntil()))
+		defer expiry.Stop()
+		// the loss is signalled before the release, which may wait for the
+		// unreachable nodes
+		lose := func(err error) {
+			l.s.obs.event(context.Background(), "lock lost", l.lockKey, l.holder(), err)
+			close(l.lost)
+			close(l.unlocked)
+			l.release()
+		}
+		for {
+			select {
+			case <-l.stop:
+				l.release()
+				close(l.unlocked)
+				return
+			case <-expiry.C:
+				lose(fmt.Errorf("%w: %s: not renewed in time", datastore.ErrLockLost, l.lockKey))
+				return
+			case <-tk.C:
+				// a renewal still pending at the deadline cannot save the lock
+				ctx, cancel := context.WithDeadline(context.Background(), l.ValidUntil())
+				res := make(chan error, 1)
+				go func() { res <- l.Renew(ctx) }()
+				var err error
+				select {
+				case err = <-res:
+				case <-expiry.C:
+					err = fmt.Errorf("%w: %s: not renewed in time", datastore.ErrLockLost, l.lockKey)
+				}
+				cancel()
+				if err == nil {
+					expiry.Reset(time.Until(l.ValidUntil()))
+					continue
+				}
+				if errors.Is(err, datastore.ErrLockLost) || !time.Now().Before(l.ValidUntil()) {
+					lose(err)
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Unlock releases the lock on every server, it returns ErrLockLost if the
+// lock was lost before.
+func (l *QuorumLock) Unlock() error {
+	l.stopOnce.Do(func() { close(l.stop) })
+	<-l.unlocked
+	select {
+	case <-l.lost:
+		return fmt.Errorf("%w: %s", datastore.ErrLockLost, l.lockKey)
+	default:
+		return nil
+	}
+}
+
+func (l *QuorumLock) Unlocked() bool {
+	select {
+	case <-l.unlocked:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *QuorumLock) WaitUnlocked() <-chan struct{} {
+	return l.unlocked
+}
+
+func (l *QuorumLock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// ValidUntil returns the time until which the lock is known to be held on a
+// majority of the servers.
+func (l *QuorumLock) ValidUntil() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.validUntil
+}
+
+// Renew extends the TTL of the lock on every server still holding it. It
+// fails with ErrLockLost once a majority of the servers no longer hold it.
+func (l *QuorumLock) Renew(ctx context.Context) (err error) {
+	ctx, done := l.s.obs.refresh(ctx, l.lockKey, l.holder())
+	defer func() { done(err) }()
+	if l.Unlocked() {
+		return fmt.Errorf("%w: %s", datastore.ErrLockLost, l.lockKey)
+	}
+	start := time.Now()
+	errs := l.each(ctx, refreshLock)
+	renewed := count(errs, nil)
+	validUntil := l.s.validUntil(start)
+	if renewed >= l.s.quorum() && time.Now().Before(validUntil) {
+		l.mu.Lock()
+		l.validUntil = validUntil
+		l.mu.Unlock()
+		return nil
+	}
+	if count(errs, datastore.ErrLockLost) > len(l.locks)-l.s.quorum() {
+		return fmt.Errorf("%w: %s", datastore.ErrLockLost, l.lockKey)
+	}
+	return fmt.Errorf("synchroniser: lock %s renewed on %d of %d nodes", l.lockKey, renewed, len(l.locks))
+}
+
+// release removes the lock from every server, including the servers on
+// which it may have been set after the request timed out.
+func (l *QuorumLock) release() {
+	done := l.s.obs.release(context.Background(), l.lockKey, l.holder())
+	errs := l.each(context.Background(), releaseLock)
+	done(errors.Join(errs...))
+}
+
+// holder returns the value identifying the holder of the lock.
+func (l *QuorumLock) holder() string {
+	return l.locks[0].lockValue
+}
+
+// each runs fn concurrently on the lock of every server, with the request
+// timeout of the synchroniser, and returns the error of each server.
+func (l *QuorumLock) each(ctx context.Context, fn func(context.Context, *redisLock) error) []error {
+	errs := make([]error, len(l.locks))
+	var wg sync.WaitGroup
+	for i, nl := range l.locks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, l.s.nodeTimeout())
+			defer cancel()
+			errs[i] = fn(ctx, nl)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// count returns the number of errors matching target, or the number of nil
+// errors when target is nil.
+func count(errs []error, target error) int {
+	n := 0
+	for _, err := range errs {
+		if (target == nil && err == nil) || (target != nil && errors.Is(err, target)) {
+			n++
+		}
+	}
+	return n
+}
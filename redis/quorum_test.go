@@ -0,0 +1,129 @@
+package redis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/slawo/go-cache/datastore"
+	"github.com/slawo/go-cache/datastore/tests"
+	"github.com/slawo/go-cache/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func NewServers(t *testing.T, n int) []string {
+	dsns := make([]string, n)
+	for i := range dsns {
+		dsns[i] = NewServer(t)
+	}
+	return dsns
+}
+
+func TestNewQuorumSynchroniserFailsWithoutNodes(t *testing.T) {
+	s, err := redis.NewQuorumSynchroniser(t.Context())
+	assert.EqualError(t, err, "synchroniser: nodes cannot be empty")
+	assert.Nil(t, s)
+}
+
+func TestNewQuorumSynchroniserFailsWithoutQuorum(t *testing.T) {
+	dsn := NewServer(t)
+	s, err := redis.NewQuorumSynchroniser(t.Context(), redis.SynchroniserNodes(dsn, "127.0.0.1:1", "127.0.0.1:2"))
+	assert.EqualError(t, err, "synchroniser: only 1 of 3 nodes are reachable")
+	assert.Nil(t, s)
+}
+
+func TestQuorumSynchroniserGetLockMultiTest(t *testing.T) {
+	dsns := NewServers(t, 3)
+	tests.RunParallelLockTests(t, tests.ParallelLockTestsOpts{
+		NewDataSynchroniser: func(ctx context.Context, t *testing.T) (datastore.DataSynchroniser, error) {
+			return redis.NewQuorumSynchroniser(ctx, redis.SynchroniserNodes(dsns...))
+		},
+		MaxSyncs: 3,
+		MaxTries: 3,
+		MaxLocks: 20,
+	})
+}
+
+func TestQuorumSynchroniserNeedsMajority(t *testing.T) {
+	dsns := NewServers(t, 3)
+	s, err := redis.NewQuorumSynchroniser(t.Context(), redis.SynchroniserNodes(dsns...))
+	require.NoError(t, err)
+	defer s.Close()
+	clients := make([]*goredis.Client, len(dsns))
+	for i, dsn := range dsns {
+		clients[i] = goredis.NewClient(&goredis.Options{Addr: dsn})
+		defer clients[i].Close()
+	}
+
+	// held by someone else on a minority of the nodes
+	require.NoError(t, clients[0].Set(t.Context(), "lock:{quorumKey}:write", "other", 0).Err())
+	lock, err := s.GetWriteLock(t.Context(), "quorumKey")
+	require.NoError(t, err)
+	assert.True(t, time.Now().Before(lock.(*redis.QuorumLock).ValidUntil()))
+	require.NoError(t, lock.Unlock())
+	for _, c := range clients[1:] {
+		assert.Zero(t, c.Exists(t.Context(), "lock:{quorumKey}:write").Val())
+	}
+
+	// held by someone else on a majority of the nodes
+	require.NoError(t, clients[1].Set(t.Context(), "lock:{quorumKey}:write", "other", 0).Err())
+	_, err = s.GetWriteLock(t.Context(), "quorumKey")
+	assert.ErrorIs(t, err, datastore.ErrLockAlreadyHeld)
+	// the lock acquired on the remaining node is released
+	assert.Zero(t, clients[2].Exists(t.Context(), "lock:{quorumKey}:write").Val())
+}
+
+func TestQuorumSynchroniserSignalsLostLock(t *testing.T) {
+	dsns := NewServers(t, 3)
+	s, err := redis.NewQuorumSynchroniser(t.Context(), redis.SynchroniserNodes(dsns...), redis.SynchroniserLockTimeOut(1))
+	require.NoError(t, err)
+	defer s.Close()
+	lock, err := s.GetWriteLock(t.Context(), "lostKey")
+	require.NoError(t, err)
+	require.NoError(t, lock.(datastore.RenewableLock).Renew(t.Context()))
+
+	// a minority of the nodes losing the lock is tolerated
+	client := goredis.NewClient(&goredis.Options{Addr: dsns[0]})
+	defer client.Close()
+	require.NoError(t, client.Del(t.Context(), "lock:{lostKey}:write").Err())
+	require.NoError(t, lock.(datastore.RenewableLock).Renew(t.Context()))
+
+	other := goredis.NewClient(&goredis.Options{Addr: dsns[1]})
+	defer other.Close()
+	require.NoError(t, other.Del(t.Context(), "lock:{lostKey}:write").Err())
+	select {
+	case <-lock.(datastore.LosableLock).Lost():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lost lock was not signalled")
+	}
+	assert.True(t, lock.Unlocked())
+	assert.ErrorIs(t, lock.Unlock(), datastore.ErrLockLost)
+}
+
+func TestQuorumSynchroniserLockHarness(t *testing.T) {
+	dsns := NewServers(t, 3)
+	nodes := newProxiedNodes()
+	tests.RunLockHarness(t, tests.LockHarnessOpts{
+		NewDataSynchroniser: func(ctx context.Context, t *testing.T) (datastore.DataSynchroniser, error) {
+			proxies := make([]*Proxy, len(dsns))
+			addrs := make([]string, len(dsns))
+			for i, dsn := range dsns {
+				proxies[i] = NewProxy(t, dsn)
+				addrs[i] = proxies[i].Addr()
+			}
+			s, err := redis.NewQuorumSynchroniser(ctx, redis.SynchroniserNodes(addrs...), redis.SynchroniserLockTimeOut(1))
+			if err != nil {
+				return nil, err
+			}
+			t.Cleanup(func() { s.Close() })
+			nodes.add(s, proxies...)
+			return s, nil
+		},
+		Lease:       time.Second,
+		Crash:       nodes.Crash,
+		Partition:   nodes.Partition,
+		SlowRefresh: nodes.SlowRefresh,
+	})
+}
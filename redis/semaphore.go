@@ -0,0 +1,166 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/slawo/go-cache/datastore"
+)
+
+// NewSemaphore creates a counting semaphore shared by every node connected
+// to the same redis database. The permits are held for the lock timeout and
+// refreshed in the background like the locks, the permits of a dead holder
+// are released once their lease expires.
+// The connection is configured with the same options as NewSynchroniser.
+func NewSemaphore(ctx context.Context, opts ...SynchroniserOption) (*RedisSemaphore, error) {
+	o, err := applyOptions(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("semaphore: failed to apply option: %w", err)
+	}
+	prefix, err := namespacePrefix(o.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("semaphore: %w", err)
+	}
+	if !o.hasServer() {
+		return nil, fmt.Errorf("semaphore: DSN cannot be empty")
+	}
+	if o.LockTimeoutSeconds == 0 {
+		o.LockTimeoutSeconds = 6 // Default timeout of 6 seconds
+	}
+	client, err := newClient(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	mID, err := uuid.NewV4()
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	return &RedisSemaphore{
+		client:             client,
+		managerID:          mID.String(),
+		hostname:           hostname,
+		lockTimeoutSeconds: o.LockTimeoutSeconds,
+		obs:                newObserver(o),
+		prefix:             prefix,
+	}, nil
+}
+
+// RedisSemaphore is a datastore.DataSemaphore stored in redis. The holders
+// of the permits of a key are stored in a sorted set scored by the expiry
+// of each permit. The waiting callers retry whenever a permit is released,
+// they are not served in FIFO order.
+type RedisSemaphore struct {
+	client             redis.UniversalClient
+	managerID          string
+	hostname           string
+	lockTimeoutSeconds int
+	obs                observer
+	prefix             string
+}
+
+func (s *RedisSemaphore) Acquire(ctx context.Context, key string, permits int) (datastore.SemaphorePermit, error) {
+	l, err := s.newPermit(ctx, key, permits)
+	if err != nil {
+		return nil, err
+	}
+	if err := setLock(ctx, l); err != nil {
+		if errors.Is(err, datastore.ErrLockAlreadyHeld) {
+			return nil, fmt.Errorf("%w: %s", datastore.ErrNoPermitAvailable, l.lockKey)
+		}
+		return nil, err
+	}
+	l.start(ctx)
+	return &Permit{l: l}, nil
+}
+
+// Wait blocks until a permit is acquired or ctx is done.
+func (s *RedisSemaphore) Wait(ctx context.Context, key string, permits int) (datastore.SemaphorePermit, error) {
+	l, err := s.newPermit(ctx, key, permits)
+	if err != nil {
+		return nil, err
+	}
+	if err := waitLock(ctx, l); err != nil {
+		return nil, err
+	}
+	return &Permit{l: l}, nil
+}
+
+// Close closes the connection to redis, the permits still held are no
+// longer refreshed.
+func (s *RedisSemaphore) Close() error {
+	return s.client.Close()
+}
+
+func (s *RedisSemaphore) newPermit(ctx context.Context, key string, permits int) (*redisLock, error) {
+	if strings.TrimSpace(key) == "" || len(key) < 3 {
+		return nil, datastore.ErrInvalidLockID
+	}
+	if permits < 1 {
+		return nil, datastore.ErrInvalidPermits
+	}
+	holder, err := newHolderValue(ctx, s.managerID, s.hostname)
+	if err != nil {
+		return nil, err
+	}
+	l := newRedisLock(s.client, semaphoreKey(s.prefix, key), holder, s.lockTimeoutSeconds, s.obs)
+	l.shared = true
+	l.permits = permits
+	return l, nil
+}
+
+// Permit is a permit held on a RedisSemaphore, it is refreshed in the
+// background until it is released.
+type Permit struct {
+	l *redisLock
+}
+
+func (p *Permit) Release() error {
+	return p.l.Unlock()
+}
+
+func (p *Permit) Released() bool {
+	return p.l.Unlocked()
+}
+
+func (p *Permit) WaitReleased() <-chan struct{} {
+	return p.l.WaitUnlocked()
+}
+
+func (p *Permit) Lost() <-chan struct{} {
+	return p.l.Lost()
+}
+
+// Renew extends the lease of the permit without waiting for the background
+// refresh.
+func (p *Permit) Renew(ctx context.Context) error {
+	return p.l.Renew(ctx)
+}
+
+// semaphoreKey returns the key of a semaphore, the permits are stored in the
+// set of the read locks of this key.
+func semaphoreKey(prefix, key string) string {
+	return prefix + "semaphore:{" + hashTagEscaper.Replace(key) + "}"
+}
+
+// semaphoreScript acquires a permit, the keys are the same as for lockScript
+// and the permits are stored in KEYS[4]. ARGV[1] is the holder, ARGV[2] the
+// lease in milliseconds and ARGV[3] the number of permits.
+const semaphoreScript = `
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local ttl = tonumber(ARGV[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[4], "-inf", now)
+if redis.call("ZCARD", KEYS[4]) >= tonumber(ARGV[3]) then
+	return false
+end
+redis.call("ZADD", KEYS[4], now + ttl, ARGV[1])
+redis.call("PEXPIRE", KEYS[4], ttl)
+return "OK"
+`
@@ -0,0 +1,34 @@
+package redis_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/slawo/go-cache/datastore"
+	"github.com/slawo/go-cache/datastore/tests"
+	"github.com/slawo/go-cache/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSemaphoreFailsWithoutDSN(t *testing.T) {
+	s, err := redis.NewSemaphore(t.Context())
+	assert.EqualError(t, err, "semaphore: DSN cannot be empty")
+	assert.Nil(t, s)
+}
+
+func TestSemaphoreTests(t *testing.T) {
+	dsn := NewServer(t)
+	tests.RunSemaphoreTests(t, tests.SemaphoreTestsOpts{
+		NewDataSemaphore: func(ctx context.Context, t *testing.T) (datastore.DataSemaphore, error) {
+			s, err := redis.NewSemaphore(ctx, redis.SynchroniserDSN(dsn), redis.SynchroniserLockTimeOut(1))
+			require.NoError(t, err)
+			t.Cleanup(func() { s.Close() })
+			return s, nil
+		},
+		// the permits of a closed semaphore are no longer refreshed
+		Crash: func(t *testing.T, s datastore.DataSemaphore) {
+			require.NoError(t, s.(*redis.RedisSemaphore).Close())
+		},
+	})
+}
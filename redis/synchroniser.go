@@ -2,8 +2,11 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/redis/go-redis/v9"
@@ -11,27 +14,24 @@ import (
 )
 
 func NewSynchroniser(ctx context.Context, opts ...SynchroniserOption) (*RedisSynchroniser, error) {
-	o := SynchroniserOptions{}
-	for _, opt := range opts {
-		if err := opt.Apply(&o); err != nil {
-			return nil, fmt.Errorf("synchroniser: failed to apply option: %w", err)
-		}
+	o, err := applyOptions(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("synchroniser: failed to apply option: %w", err)
 	}
 
-	if o.DSN == "" {
+	prefix, err := namespacePrefix(o.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("synchroniser: %w", err)
+	}
+	if !o.hasServer() {
 		return nil, fmt.Errorf("synchroniser: DSN cannot be empty")
 	}
 	if o.LockTimeoutSeconds == 0 {
 		o.LockTimeoutSeconds = 6 // Default timeout of 6 seconds
 	}
 
-	client := redis.NewClient(&redis.Options{
-		Addr:     o.DSN,
-		Password: o.Password,
-		DB:       o.DB,
-	})
-
-	if err := client.Ping(context.Background()).Err(); err != nil {
+	client, err := newClient(ctx, o)
+	if err != nil {
 		return nil, err
 	}
 
@@ -39,28 +39,199 @@ func NewSynchroniser(ctx context.Context, opts ...SynchroniserOption) (*RedisSyn
 	if err != nil {
 		return nil, err
 	}
+	hostname, _ := os.Hostname()
 
 	return &RedisSynchroniser{
 		client:             client,
 		managerID:          mID.String(),
+		hostname:           hostname,
 		lockTimeoutSeconds: o.LockTimeoutSeconds,
+		writerPreference:   o.WriterPreference,
+		obs:                newObserver(o),
+		prefix:             prefix,
 	}, nil
 }
 
 type RedisSynchroniser struct {
-	client             *redis.Client
+	client             redis.UniversalClient
 	managerID          string
+	hostname           string
 	lockTimeoutSeconds int
+	writerPreference   bool
+	obs                observer
+	// prefix is prepended to every key, it is built from the namespace and
+	// the tenant
+	prefix string
+}
+
+// ForTenant returns a synchroniser sharing the connection of r whose locks
+// are isolated in the scope of the given tenant: the same lock ID locks
+// different keys for different tenants.
+func (r *RedisSynchroniser) ForTenant(tenant string) (*RedisSynchroniser, error) {
+	if err := validateName(tenant); err != nil {
+		return nil, fmt.Errorf("synchroniser: invalid tenant: %w", err)
+	}
+	cp := *r
+	cp.prefix = r.prefix + tenant + ":"
+	return &cp, nil
 }
 
 func (r *RedisSynchroniser) GetWriteLock(ctx context.Context, lockID string) (datastore.DataWriteLock, error) {
-	mID, err := uuid.NewV4()
+	holder, err := newHolderValue(ctx, r.managerID, r.hostname)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(lockID) == "" || len(lockID) < 3 {
+		return nil, datastore.ErrInvalidLockID
+	}
+	l := newRedisLock(r.client, writeLockKey(r.prefix, lockID), holder, r.lockTimeoutSeconds, r.obs)
+	if err := setLock(ctx, l); err != nil {
+		return nil, err
+	}
+	l.start(ctx)
+	return &WriteLock{redisLock: l}, nil
+}
+
+// WaitWriteLock blocks until the lock is acquired or ctx is done.
+//
+// The callers wait in a queue stored next to the lock and are woken up by a
+// message published when the lock is released, so they acquire the lock in
+// FIFO order. They also retry periodically to renew their place in the
+// queue and to notice locks which expired because their holder crashed.
+func (r *RedisSynchroniser) WaitWriteLock(ctx context.Context, lockID string) (datastore.DataWriteLock, error) {
+	holder, err := newHolderValue(ctx, r.managerID, r.hostname)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(lockID) == "" || len(lockID) < 3 {
+		return nil, datastore.ErrInvalidLockID
+	}
+	l := newRedisLock(r.client, writeLockKey(r.prefix, lockID), holder, r.lockTimeoutSeconds, r.obs)
+	if err := waitLock(ctx, l); err != nil {
+		return nil, err
+	}
+	return &WriteLock{redisLock: l}, nil
+}
+
+// GetReadLock acquires a shared lock, it fails if a write lock is held or,
+// with writer preference, if callers of WaitWriteLock are queued.
+func (r *RedisSynchroniser) GetReadLock(ctx context.Context, lockID string) (datastore.DataReadLock, error) {
+	holder, err := newHolderValue(ctx, r.managerID, r.hostname)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(lockID) == "" || len(lockID) < 3 {
+		return nil, datastore.ErrInvalidLockID
+	}
+	l := newReadLock(r.client, writeLockKey(r.prefix, lockID), holder, r.lockTimeoutSeconds, r.writerPreference, r.obs)
+	if err := setLock(ctx, l.redisLock); err != nil {
+		return nil, err
+	}
+	l.start(ctx)
+	return l, nil
+}
+
+// WaitReadLock blocks until a shared lock is acquired or ctx is done.
+// Readers do not queue, they retry whenever a lock is released.
+func (r *RedisSynchroniser) WaitReadLock(ctx context.Context, lockID string) (datastore.DataReadLock, error) {
+	holder, err := newHolderValue(ctx, r.managerID, r.hostname)
 	if err != nil {
 		return nil, err
 	}
 	if strings.TrimSpace(lockID) == "" || len(lockID) < 3 {
 		return nil, datastore.ErrInvalidLockID
 	}
-	lockKey := "lock:" + lockID + ":write" // Ensure lockID is unique for write locks
-	return NewWriteLock(ctx, r.client, lockKey, mID.String(), r.lockTimeoutSeconds)
+	l := newReadLock(r.client, writeLockKey(r.prefix, lockID), holder, r.lockTimeoutSeconds, r.writerPreference, r.obs)
+	if err := waitLock(ctx, l.redisLock); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// waitLock acquires l, retrying whenever a lock with the same key is
+// released. The whole wait is reported as a single acquisition.
+func waitLock(ctx context.Context, l *redisLock) (err error) {
+	ctx, done := l.obs.acquire(ctx, l.lockKey, l.lockValue)
+	defer func() { done(err) }()
+	// subscribe before the first attempt so that no release is missed
+	sub := l.client.Subscribe(ctx, releasedChannel(l.lockKey))
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("synchroniser: unable to subscribe: %w", err)
+	}
+	released := sub.Channel()
+
+	wait := time.Duration(l.timeoutSeconds) * time.Second
+	retry := time.NewTicker(wait / 3)
+	defer retry.Stop()
+	for {
+		acquired, err := tryLock(ctx, l, wait.Milliseconds())
+		if err != nil {
+			l.leaveQueue()
+			return fmt.Errorf("synchroniser: %w", err)
+		}
+		if acquired {
+			l.start(ctx)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			l.leaveQueue()
+			return fmt.Errorf("%w: %s", ctx.Err(), l.lockKey)
+		case <-released:
+		case <-retry.C:
+		}
+	}
+}
+
+// leaveQueue removes a caller of WaitWriteLock giving up from the queue.
+func (l *redisLock) leaveQueue() {
+	if l.shared {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(l.timeoutSeconds)*time.Second)
+	defer cancel()
+	if err := leaveQueue(ctx, l); err != nil {
+		l.obs.event(ctx, "lock leave queue", l.lockKey, l.lockValue, err)
+	}
+}
+
+// writeLockKey returns the key of a lock, prefix is the namespace and
+// tenant prefix of the synchroniser.
+func writeLockKey(prefix, lockID string) string {
+	// the hash tag keeps the keys of a lock in the same cluster slot
+	return prefix + "lock:{" + hashTagEscaper.Replace(lockID) + "}:write"
+}
+
+var (
+	// hashTagEscaper escapes the braces of the IDs put in the hash tag of a
+	// key, which would otherwise end the hash tag early, and the escape
+	// character itself.
+	hashTagEscaper = strings.NewReplacer("%", "%25", "{", "%7B", "}", "%7D")
+	// hashTagUnescaper reverts hashTagEscaper.
+	hashTagUnescaper = strings.NewReplacer("%25", "%", "%7B", "{", "%7D", "}")
+)
+
+// namespacePrefix returns the prefix of the keys in the given namespace, an
+// empty namespace has no prefix.
+func namespacePrefix(namespace string) (string, error) {
+	if namespace == "" {
+		return "", nil
+	}
+	if err := validateName(namespace); err != nil {
+		return "", fmt.Errorf("invalid namespace: %w", err)
+	}
+	return namespace + ":", nil
+}
+
+// validateName checks a namespace or tenant name, the names cannot contain
+// the key separator, hash tag braces or SCAN pattern characters.
+func validateName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("name cannot be empty")
+	}
+	if strings.ContainsAny(name, ":{}*?[]\\ ") {
+		return fmt.Errorf("name %q contains reserved characters", name)
+	}
+	return nil
 }
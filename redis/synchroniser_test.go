@@ -1,9 +1,16 @@
 package redis_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	goredis "github.com/redis/go-redis/v9"
 	"github.com/slawo/go-cache/datastore"
 	"github.com/slawo/go-cache/datastore/tests"
 	"github.com/slawo/go-cache/redis"
@@ -87,7 +94,7 @@ func TestMutexSynchroniserGetLockErrorOnSecondCall(t *testing.T) {
 	assert.NotNil(t, lock1)
 
 	lock2, err := s.GetWriteLock(context.Background(), "redisKey2")
-	assert.EqualError(t, err, "lock is already held: lock:redisKey2:write")
+	assert.EqualError(t, err, "lock is already held: lock:{redisKey2}:write")
 	assert.Nil(t, lock2)
 
 	err = lock1.Unlock()
@@ -101,12 +108,14 @@ func TestMutexSynchroniserGetLockErrorOnSecondCall(t *testing.T) {
 	assert.NoError(t, err)
 
 	lock4, err := s.GetWriteLock(context.Background(), "redisKey2")
-	assert.EqualError(t, err, "lock is already held: lock:redisKey2:write")
+	assert.EqualError(t, err, "lock is already held: lock:{redisKey2}:write")
 	assert.Nil(t, lock4)
 }
 
 func TestMutexSynchroniserGetLockMultiTest(t *testing.T) {
 	dsn := NewServer(t)
+	client := goredis.NewClient(&goredis.Options{Addr: dsn})
+	t.Cleanup(func() { client.Close() })
 	create := func(ctx context.Context, t *testing.T) (datastore.DataSynchroniser, error) {
 		s, err := redis.NewSynchroniser(context.Background(), redis.SynchroniserDSN(dsn))
 		require.NoError(t, err)
@@ -118,5 +127,445 @@ func TestMutexSynchroniserGetLockMultiTest(t *testing.T) {
 		MaxSyncs:            10,
 		MaxTries:            3,
 		MaxLocks:            50,
+		Waiting: func(t *testing.T, s datastore.DataSynchroniser, lockID string) int {
+			return queueLength(t, client, lockID)
+		},
 	})
 }
+
+// queueLength returns the number of callers queued for a write lock.
+func queueLength(t *testing.T, client *goredis.Client, lockID string) int {
+	escaped := strings.NewReplacer("%", "%25", "{", "%7B", "}", "%7D").Replace(lockID)
+	n, err := client.LLen(t.Context(), "lock:{"+escaped+"}:write:queue").Result()
+	require.NoError(t, err)
+	return int(n)
+}
+
+func TestSynchroniserWaitLockTests(t *testing.T) {
+	dsn := NewServer(t)
+	client := goredis.NewClient(&goredis.Options{Addr: dsn})
+	t.Cleanup(func() { client.Close() })
+	tests.RunWaitLockTests(t, tests.WaitLockTestsOpts{
+		NewDataSynchroniser: func(ctx context.Context, t *testing.T) (datastore.BlockingDataSynchroniser, error) {
+			return redis.NewSynchroniser(ctx, redis.SynchroniserDSN(dsn))
+		},
+		Waiting: func(t *testing.T, s datastore.DataSynchroniser, lockID string) int {
+			return queueLength(t, client, lockID)
+		},
+	})
+}
+
+func TestSynchroniserWriterPreferenceLockTests(t *testing.T) {
+	dsn := NewServer(t)
+	client := goredis.NewClient(&goredis.Options{Addr: dsn})
+	t.Cleanup(func() { client.Close() })
+	create := func(ctx context.Context, t *testing.T) (datastore.DataSynchroniser, error) {
+		return redis.NewSynchroniser(ctx, redis.SynchroniserDSN(dsn), redis.SynchroniserWriterPreference(true))
+	}
+	tests.RunParallelLockTests(t, tests.ParallelLockTestsOpts{
+		NewDataSynchroniser: create,
+		MaxSyncs:            3,
+		MaxTries:            3,
+		MaxLocks:            10,
+		WriterPreference:    true,
+		Waiting: func(t *testing.T, s datastore.DataSynchroniser, lockID string) int {
+			return queueLength(t, client, lockID)
+		},
+	})
+}
+
+func TestSynchroniserReadLockExcludesWriters(t *testing.T) {
+	dsn := NewServer(t)
+	s, err := redis.NewSynchroniser(t.Context(), redis.SynchroniserDSN(dsn))
+	require.NoError(t, err)
+
+	lock, err := s.GetReadLock(t.Context(), "readKey")
+	require.NoError(t, err)
+	assert.IsType(t, &redis.ReadLock{}, lock)
+	_, err = s.GetWriteLock(t.Context(), "readKey")
+	assert.ErrorIs(t, err, datastore.ErrLockAlreadyHeld)
+
+	require.NoError(t, lock.Unlock())
+	assert.True(t, lock.Unlocked())
+	write, err := s.GetWriteLock(t.Context(), "readKey")
+	require.NoError(t, err)
+	require.NoError(t, write.Unlock())
+}
+
+func TestSynchroniserSignalsLostLock(t *testing.T) {
+	dsn := NewServer(t)
+	s, err := redis.NewSynchroniser(t.Context(), redis.SynchroniserDSN(dsn), redis.SynchroniserLockTimeOut(1))
+	require.NoError(t, err)
+	lock, err := s.GetWriteLock(t.Context(), "lostKey")
+	require.NoError(t, err)
+	token := lock.(datastore.FencedLock).FencingToken()
+	require.NoError(t, lock.(datastore.RenewableLock).Renew(t.Context()))
+
+	// another node takes the lock over once it expired
+	client := goredis.NewClient(&goredis.Options{Addr: dsn})
+	defer client.Close()
+	require.NoError(t, client.Del(t.Context(), "lock:{lostKey}:write").Err())
+	other, err := s.GetWriteLock(t.Context(), "lostKey")
+	require.NoError(t, err)
+	assert.Greater(t, other.(datastore.FencedLock).FencingToken(), token)
+
+	select {
+	case <-lock.(datastore.LosableLock).Lost():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lost lock was not signalled")
+	}
+	assert.True(t, lock.Unlocked())
+	assert.ErrorIs(t, lock.Unlock(), datastore.ErrLockLost)
+	assert.ErrorIs(t, lock.(datastore.RenewableLock).Renew(t.Context()), datastore.ErrLockLost)
+
+	select {
+	case <-other.(datastore.LosableLock).Lost():
+		t.Fatal("the new holder did not lose the lock")
+	default:
+	}
+	require.NoError(t, other.Unlock())
+}
+
+func TestSynchroniserConcurrentUnlock(t *testing.T) {
+	s := NewSynchroniser(t)
+	lock, err := s.GetWriteLock(t.Context(), "unlockKey")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, lock.Unlock())
+		}()
+	}
+	wg.Wait()
+	assert.True(t, lock.Unlocked())
+	assert.NoError(t, lock.Unlock())
+}
+
+func TestSynchroniserFencingCounterExpires(t *testing.T) {
+	dsn := NewServer(t)
+	s, err := redis.NewSynchroniser(t.Context(), redis.SynchroniserDSN(dsn))
+	require.NoError(t, err)
+	client := goredis.NewClient(&goredis.Options{Addr: dsn})
+	defer client.Close()
+
+	lock, err := s.GetWriteLock(t.Context(), "fenceKey")
+	require.NoError(t, err)
+	token := lock.(datastore.FencedLock).FencingToken()
+	require.NoError(t, lock.Unlock())
+	ttl, err := client.PTTL(t.Context(), "lock:{fenceKey}:write:fence").Result()
+	require.NoError(t, err)
+	assert.Greater(t, ttl, 24*time.Hour, "the counter outlives any lease")
+
+	// the tokens keep increasing once the counter expired
+	require.NoError(t, client.Del(t.Context(), "lock:{fenceKey}:write:fence").Err())
+	lock, err = s.GetWriteLock(t.Context(), "fenceKey")
+	require.NoError(t, err)
+	assert.Greater(t, lock.(datastore.FencedLock).FencingToken(), token)
+	require.NoError(t, lock.Unlock())
+}
+
+type recordingTracer struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recordingTracer) record(op, key string) func(error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, "start "+op+" "+key)
+	return func(err error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if err != nil {
+			r.events = append(r.events, "fail "+op+" "+key)
+			return
+		}
+		r.events = append(r.events, "end "+op+" "+key)
+	}
+}
+
+func (r *recordingTracer) StartAcquire(ctx context.Context, key, holder string) (context.Context, func(error)) {
+	return ctx, r.record("acquire", key)
+}
+
+func (r *recordingTracer) StartRefresh(ctx context.Context, key, holder string) (context.Context, func(error)) {
+	return ctx, r.record("refresh", key)
+}
+
+func (r *recordingTracer) Events() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+func TestSynchroniserReportsLockEvents(t *testing.T) {
+	dsn := NewServer(t)
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	logger := slog.New(slog.NewJSONHandler(&lockedWriter{mu: &mu, w: &buf}, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	tracer := &recordingTracer{}
+	s, err := redis.NewSynchroniser(t.Context(), redis.SynchroniserDSN(dsn),
+		redis.SynchroniserLogger(logger), redis.SynchroniserTracer(tracer))
+	require.NoError(t, err)
+
+	lock, err := s.GetWriteLock(t.Context(), "eventKey")
+	require.NoError(t, err)
+	_, err = s.GetWriteLock(t.Context(), "eventKey")
+	require.ErrorIs(t, err, datastore.ErrLockAlreadyHeld)
+	require.NoError(t, lock.(datastore.RenewableLock).Renew(t.Context()))
+	require.NoError(t, lock.Unlock())
+
+	assert.Equal(t, []string{
+		"start acquire lock:{eventKey}:write",
+		"end acquire lock:{eventKey}:write",
+		"start acquire lock:{eventKey}:write",
+		"fail acquire lock:{eventKey}:write",
+		"start refresh lock:{eventKey}:write",
+		"end refresh lock:{eventKey}:write",
+	}, tracer.Events())
+
+	mu.Lock()
+	defer mu.Unlock()
+	var results []string
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var record struct {
+			Msg     string `json:"msg"`
+			Key     string `json:"key"`
+			Holder  string `json:"holder"`
+			Latency *int64 `json:"latency"`
+			Result  string `json:"result"`
+		}
+		require.NoError(t, json.Unmarshal(line, &record))
+		assert.Equal(t, "lock:{eventKey}:write", record.Key)
+		assert.NotEmpty(t, record.Holder)
+		assert.NotNil(t, record.Latency)
+		results = append(results, record.Msg+" "+record.Result)
+	}
+	assert.Equal(t, []string{"lock acquire ok", "lock acquire held", "lock refresh ok", "lock release ok"}, results)
+}
+
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  *bytes.Buffer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+func TestSynchroniserUsesInjectedClient(t *testing.T) {
+	dsn := NewServer(t)
+	client := goredis.NewUniversalClient(&goredis.UniversalOptions{Addrs: []string{dsn}})
+	defer client.Close()
+	s, err := redis.NewSynchroniser(t.Context(), redis.SynchroniserClient(client))
+	require.NoError(t, err)
+
+	lock, err := s.WaitWriteLock(t.Context(), "injectedKey")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), client.Exists(t.Context(), "lock:{injectedKey}:write").Val())
+	require.NoError(t, lock.Unlock())
+
+	// the values using an injected client do not close it
+	store, err := redis.NewMetaDataStore(t.Context(), redis.SynchroniserClient(client))
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+	assert.NoError(t, client.Ping(t.Context()).Err())
+}
+
+func TestSynchroniserLockKeysShareHashSlot(t *testing.T) {
+	dsn := NewServer(t)
+	s, err := redis.NewSynchroniser(t.Context(), redis.SynchroniserDSN(dsn))
+	require.NoError(t, err)
+	lock, err := s.GetWriteLock(t.Context(), "slotKey")
+	require.NoError(t, err)
+	defer lock.Unlock()
+
+	client := goredis.NewClient(&goredis.Options{Addr: dsn})
+	defer client.Close()
+	keys, err := client.Keys(t.Context(), "lock:*").Result()
+	require.NoError(t, err)
+	require.NotEmpty(t, keys)
+	for _, key := range keys {
+		assert.Contains(t, key, "{slotKey}")
+	}
+}
+
+func TestSynchroniserOptionsValidation(t *testing.T) {
+	for name, opt := range map[string]redis.SynchroniserOption{
+		"username cannot be empty":           redis.SynchroniserUsername(""),
+		"tls config cannot be nil":           redis.SynchroniserTLS(nil),
+		"cluster addresses cannot be empty":  redis.SynchroniserCluster(),
+		"master name cannot be empty":        redis.SynchroniserSentinel("", []string{"a:1"}, "", ""),
+		"sentinel addresses cannot be empty": redis.SynchroniserSentinel("master", nil, "", ""),
+		"pool sizes cannot be negative":      redis.SynchroniserPool(-1, 0),
+		"client cannot be nil":               redis.SynchroniserClient(nil),
+	} {
+		_, err := redis.NewSynchroniser(t.Context(), opt)
+		assert.EqualError(t, err, "synchroniser: failed to apply option: "+name)
+	}
+}
+
+func TestSynchroniserNamespacesAndTenants(t *testing.T) {
+	dsn := NewServer(t)
+	a, err := redis.NewSynchroniser(t.Context(), redis.SynchroniserDSN(dsn), redis.SynchroniserNamespace("appA"))
+	require.NoError(t, err)
+	b, err := redis.NewSynchroniser(t.Context(), redis.SynchroniserDSN(dsn), redis.SynchroniserNamespace("appB"))
+	require.NoError(t, err)
+	tenant1, err := a.ForTenant("tenant1")
+	require.NoError(t, err)
+	tenant2, err := a.ForTenant("tenant2")
+	require.NoError(t, err)
+	_, err = a.ForTenant("bad:tenant")
+	assert.EqualError(t, err, `synchroniser: invalid tenant: name "bad:tenant" contains reserved characters`)
+
+	for _, s := range []*redis.RedisSynchroniser{a, b, tenant1, tenant2} {
+		lock, err := s.GetWriteLock(t.Context(), "shared")
+		require.NoError(t, err)
+		defer lock.Unlock()
+	}
+	_, err = tenant1.GetWriteLock(t.Context(), "shared")
+	assert.EqualError(t, err, "lock is already held: appA:tenant1:lock:{shared}:write")
+}
+
+func TestSynchroniserListLocks(t *testing.T) {
+	dsn := NewServer(t)
+	s, err := redis.NewSynchroniser(t.Context(), redis.SynchroniserDSN(dsn), redis.SynchroniserNamespace("app"))
+	require.NoError(t, err)
+	tenant, err := s.ForTenant("tenant")
+	require.NoError(t, err)
+
+	write, err := s.GetWriteLock(t.Context(), "writeKey")
+	require.NoError(t, err)
+	defer write.Unlock()
+	read1, err := s.GetReadLock(t.Context(), "readKey")
+	require.NoError(t, err)
+	defer read1.Unlock()
+	read2, err := s.GetReadLock(t.Context(), "readKey")
+	require.NoError(t, err)
+	defer read2.Unlock()
+	other, err := tenant.GetWriteLock(t.Context(), "tenantKey")
+	require.NoError(t, err)
+	defer other.Unlock()
+
+	locks, err := s.ListLocks(t.Context())
+	require.NoError(t, err)
+	require.Len(t, locks, 3)
+	assert.Equal(t, "readKey", locks[0].LockID)
+	assert.True(t, locks[0].Shared)
+	assert.Equal(t, "readKey", locks[1].LockID)
+	assert.NotEqual(t, locks[0].Holder.ID, locks[1].Holder.ID)
+	assert.Equal(t, "writeKey", locks[2].LockID)
+	assert.False(t, locks[2].Shared)
+	for _, l := range locks {
+		assert.NotEmpty(t, l.Holder.SynchroniserID)
+		assert.Greater(t, l.TTL, time.Duration(0))
+		assert.LessOrEqual(t, l.TTL, 6*time.Second)
+	}
+
+	locks, err = tenant.ListLocks(t.Context())
+	require.NoError(t, err)
+	require.Len(t, locks, 1)
+	assert.Equal(t, "tenantKey", locks[0].LockID)
+
+	require.NoError(t, write.Unlock())
+	locks, err = s.ListLocks(t.Context())
+	require.NoError(t, err)
+	assert.Len(t, locks, 2)
+}
+
+func TestSynchroniserInspectionTests(t *testing.T) {
+	dsn := NewServer(t)
+	tests.RunInspectionTests(t, tests.InspectionTestsOpts{
+		NewDataSynchroniser: func(ctx context.Context, t *testing.T) (datastore.InspectableDataSynchroniser, error) {
+			return redis.NewSynchroniser(ctx, redis.SynchroniserDSN(dsn), redis.SynchroniserLockTimeOut(1))
+		},
+	})
+}
+
+func TestSynchroniserLockHarness(t *testing.T) {
+	dsn := NewServer(t)
+	nodes := newProxiedNodes()
+	tests.RunLockHarness(t, tests.LockHarnessOpts{
+		NewDataSynchroniser: func(ctx context.Context, t *testing.T) (datastore.DataSynchroniser, error) {
+			p := NewProxy(t, dsn)
+			s, err := redis.NewSynchroniser(ctx, redis.SynchroniserDSN(p.Addr()), redis.SynchroniserLockTimeOut(1))
+			if err != nil {
+				return nil, err
+			}
+			nodes.add(s, p)
+			return s, nil
+		},
+		Lease:       time.Second,
+		Crash:       nodes.Crash,
+		Partition:   nodes.Partition,
+		SlowRefresh: nodes.SlowRefresh,
+	})
+}
+
+// hashTag returns the part of key hashed by a redis cluster.
+func hashTag(key string) string {
+	start := strings.Index(key, "{")
+	if start < 0 {
+		return key
+	}
+	end := strings.Index(key[start+1:], "}")
+	if end <= 0 {
+		return key
+	}
+	return key[start+1 : start+1+end]
+}
+
+func TestSynchroniserEscapesHashTags(t *testing.T) {
+	dsn := NewServer(t)
+	client := goredis.NewClient(&goredis.Options{Addr: dsn})
+	defer client.Close()
+	s, err := redis.NewSynchroniser(t.Context(), redis.SynchroniserDSN(dsn))
+	require.NoError(t, err)
+	sem, err := redis.NewSemaphore(t.Context(), redis.SynchroniserDSN(dsn))
+	require.NoError(t, err)
+	defer sem.Close()
+
+	for _, lockID := range []string{"}lock", "{lock", "a}b"} {
+		held, err := s.GetWriteLock(t.Context(), lockID)
+		require.NoError(t, err)
+		waited := make(chan error)
+		go func() {
+			lock, err := s.WaitWriteLock(t.Context(), lockID)
+			if err == nil {
+				err = lock.Unlock()
+			}
+			waited <- err
+		}()
+		require.Eventually(t, func() bool { return queueLength(t, client, lockID) == 1 }, 5*time.Second, time.Millisecond)
+
+		locks, err := s.ListLocks(t.Context())
+		require.NoError(t, err)
+		require.Len(t, locks, 1)
+		assert.Equal(t, lockID, locks[0].LockID)
+
+		keys, err := client.Keys(t.Context(), "lock:*").Result()
+		require.NoError(t, err)
+		assert.Greater(t, len(keys), 1)
+		for _, key := range keys {
+			assert.Equal(t, hashTag(keys[0]), hashTag(key), "the keys of %q are in different slots", lockID)
+			assert.NotEqual(t, key, hashTag(key), "the key %q has no hash tag", key)
+		}
+		require.NoError(t, held.Unlock())
+		require.NoError(t, <-waited)
+		require.NoError(t, client.FlushDB(t.Context()).Err())
+
+		permit, err := sem.Acquire(t.Context(), lockID, 2)
+		require.NoError(t, err)
+		keys, err = client.Keys(t.Context(), "semaphore:*").Result()
+		require.NoError(t, err)
+		for _, key := range keys {
+			assert.NotEqual(t, key, hashTag(key), "the key %q has no hash tag", key)
+		}
+		require.NoError(t, permit.Release())
+	}
+}
@@ -0,0 +1,102 @@
+package stdio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ReadCloser is a cache.ReadCloser reading from an io.Reader. The context is
+// checked before every read, the reader is closed if it is an io.Closer.
+type ReadCloser struct {
+	mu     sync.Mutex
+	r      io.Reader
+	p      int64
+	closed bool
+}
+
+// FromReader wraps r as a cache.ReadCloser.
+func FromReader(r io.Reader) *ReadCloser {
+	return &ReadCloser{r: r}
+}
+
+func (r *ReadCloser) GetPosition(ctx context.Context) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.p
+}
+
+func (r *ReadCloser) Read(ctx context.Context, p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return 0, ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("stdio: %w", err)
+	}
+	n, err := r.r.Read(p)
+	r.p += int64(n)
+	return n, err
+}
+
+func (r *ReadCloser) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrClosed
+	}
+	r.closed = true
+	if c, ok := r.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// WriteCloser is a cache.WriteCloser writing to an io.Writer. The context is
+// checked before every write, the writer is closed if it is an io.Closer.
+type WriteCloser struct {
+	mu     sync.Mutex
+	w      io.Writer
+	p      int64
+	closed bool
+}
+
+// FromWriter wraps w as a cache.WriteCloser.
+func FromWriter(w io.Writer) *WriteCloser {
+	return &WriteCloser{w: w}
+}
+
+func (w *WriteCloser) GetPosition(ctx context.Context) int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.p
+}
+
+func (w *WriteCloser) Write(ctx context.Context, p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("stdio: %w", err)
+	}
+	n, err := w.w.Write(p)
+	w.p += int64(n)
+	return n, err
+}
+
+func (w *WriteCloser) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	w.closed = true
+	if c, ok := w.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
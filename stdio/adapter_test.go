@@ -0,0 +1,50 @@
+package stdio_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/slawo/go-cache/stdio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromReader(t *testing.T) {
+	r := stdio.FromReader(io.NopCloser(bytes.NewReader([]byte("0123456789"))))
+	buf := make([]byte, 4)
+	n, err := r.Read(t.Context(), buf)
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, int64(4), r.GetPosition(t.Context()))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err = r.Read(ctx, buf)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	require.NoError(t, r.Close())
+	_, err = r.Read(t.Context(), buf)
+	assert.ErrorIs(t, err, stdio.ErrClosed)
+	assert.ErrorIs(t, r.Close(), stdio.ErrClosed)
+}
+
+func TestFromWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := stdio.FromWriter(&buf)
+	n, err := w.Write(t.Context(), []byte("data"))
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, int64(4), w.GetPosition(t.Context()))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err = w.Write(ctx, []byte("more"))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, "data", buf.String())
+
+	require.NoError(t, w.Close())
+	_, err = w.Write(t.Context(), []byte("more"))
+	assert.ErrorIs(t, err, stdio.ErrClosed)
+}
@@ -0,0 +1,215 @@
+// Package stdio adapts the context aware readers and writers of the cache to
+// the io interfaces of the standard library, so that cached data can be
+// passed to io.Copy, http.ServeContent, archive/zip or bufio, and the other
+// way around.
+package stdio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	cache "github.com/slawo/go-cache"
+	"github.com/slawo/go-cache/datastore"
+)
+
+// bufferSize is the size of the buffer used by WriteTo and ReadFrom.
+const bufferSize = 32 << 10
+
+// ErrClosed is returned when an adapter is used after being closed.
+var ErrClosed = errors.New("stdio: adapter closed")
+
+// ReaderProvider opens readers at a given position, it is implemented by
+// datastore.DataIOProvider and cache.DataRepository.
+type ReaderProvider interface {
+	GetReaderAt(ctx context.Context, dataID string, position int64) (cache.ReadCloser, error)
+}
+
+// Reader is an io.ReadCloser reading from a cache.ReadCloser with a bound
+// context.
+type Reader struct {
+	ctx context.Context
+	r   cache.ReadCloser
+}
+
+// NewReader binds ctx to r.
+func NewReader(ctx context.Context, r cache.ReadCloser) *Reader {
+	return &Reader{ctx: ctx, r: r}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	return r.r.Read(r.ctx, p)
+}
+
+// WriteTo writes the remaining data to w.
+func (r *Reader) WriteTo(w io.Writer) (int64, error) {
+	return writeTo(w, r.Read)
+}
+
+func (r *Reader) Close() error {
+	return r.r.Close()
+}
+
+// ReadSeeker is an io.ReadSeekCloser over the data with a given ID. It
+// opens a reader at the current offset on the first read following a seek.
+//
+// Seeking relative to the end of the data requires a provider implementing
+// datastore.DataManager.
+type ReadSeeker struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	p      ReaderProvider
+	id     string
+	r      cache.ReadCloser
+	off    int64
+	closed bool
+}
+
+// NewReadSeeker returns a ReadSeeker over the data with the given ID, bound
+// to ctx.
+func NewReadSeeker(ctx context.Context, p ReaderProvider, dataID string) *ReadSeeker {
+	return &ReadSeeker{ctx: ctx, p: p, id: dataID}
+}
+
+func (s *ReadSeeker) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(p)
+}
+
+func (s *ReadSeeker) read(p []byte) (int, error) {
+	if s.closed {
+		return 0, ErrClosed
+	}
+	if s.r == nil {
+		r, err := s.p.GetReaderAt(s.ctx, s.id, s.off)
+		if err != nil {
+			return 0, fmt.Errorf("stdio: unable to open reader: %w", err)
+		}
+		s.r = r
+	}
+	n, err := s.r.Read(s.ctx, p)
+	s.off += int64(n)
+	return n, err
+}
+
+// Seek sets the offset of the next read. The current reader is closed when
+// the offset changes.
+func (s *ReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, ErrClosed
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.off
+	case io.SeekEnd:
+		m, ok := s.p.(datastore.DataManager)
+		if !ok {
+			return 0, errors.New("stdio: seeking from the end requires a datastore.DataManager")
+		}
+		info, err := m.Stat(s.ctx, s.id)
+		if err != nil {
+			return 0, fmt.Errorf("stdio: %w", err)
+		}
+		offset += info.Size
+	default:
+		return 0, errors.New("stdio: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("stdio: negative position")
+	}
+	if offset != s.off && s.r != nil {
+		err := s.r.Close()
+		s.r = nil
+		if err != nil {
+			return 0, fmt.Errorf("stdio: unable to close reader: %w", err)
+		}
+	}
+	s.off = offset
+	return offset, nil
+}
+
+// WriteTo writes the data from the current offset to w.
+func (s *ReadSeeker) WriteTo(w io.Writer) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return writeTo(w, s.read)
+}
+
+func (s *ReadSeeker) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	s.closed = true
+	if s.r != nil {
+		return s.r.Close()
+	}
+	return nil
+}
+
+// ReaderAt is an io.ReaderAt over the data with a given ID, every call opens
+// a reader at the requested offset so it is safe for concurrent use.
+type ReaderAt struct {
+	ctx context.Context
+	p   ReaderProvider
+	id  string
+}
+
+// NewReaderAt returns a ReaderAt over the data with the given ID, bound to
+// ctx.
+func NewReaderAt(ctx context.Context, p ReaderProvider, dataID string) *ReaderAt {
+	return &ReaderAt{ctx: ctx, p: p, id: dataID}
+}
+
+func (r *ReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("stdio: negative offset")
+	}
+	reader, err := r.p.GetReaderAt(r.ctx, r.id, off)
+	if err != nil {
+		return 0, fmt.Errorf("stdio: unable to open reader: %w", err)
+	}
+	defer reader.Close()
+	for n < len(p) {
+		var m int
+		m, err = reader.Read(r.ctx, p[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+		if m == 0 {
+			return n, io.ErrNoProgress
+		}
+	}
+	return n, nil
+}
+
+// writeTo copies the data returned by read to w until read returns io.EOF.
+func writeTo(w io.Writer, read func(p []byte) (int, error)) (written int64, err error) {
+	buf := make([]byte, bufferSize)
+	for {
+		n, rerr := read(buf)
+		if n > 0 {
+			m, werr := w.Write(buf[:n])
+			written += int64(m)
+			if werr != nil {
+				return written, werr
+			}
+			if m < n {
+				return written, io.ErrShortWrite
+			}
+		}
+		if errors.Is(rerr, io.EOF) {
+			return written, nil
+		} else if rerr != nil {
+			return written, rerr
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package stdio_test
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cache "github.com/slawo/go-cache"
+	"github.com/slawo/go-cache/datastore/memory"
+	"github.com/slawo/go-cache/stdio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newProvider(t *testing.T, files map[string][]byte) *memory.IOProvider {
+	t.Helper()
+	p, err := memory.NewIOProvider()
+	require.NoError(t, err)
+	for id, data := range files {
+		w, err := p.GetWriterAt(t.Context(), id, 0)
+		require.NoError(t, err)
+		_, err = w.Write(t.Context(), data)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+	}
+	return p
+}
+
+func testData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	return data
+}
+
+// readerOnly hides the WriterTo implementation of a reader.
+type readerOnly struct{ io.Reader }
+
+func TestReaderCopy(t *testing.T) {
+	data := testData(100 << 10)
+	p := newProvider(t, map[string][]byte{"file.bin": data})
+	r, err := p.GetReaderAt(t.Context(), "file.bin", 0)
+	require.NoError(t, err)
+	reader := stdio.NewReader(t.Context(), r)
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, reader)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.Equal(t, data, buf.Bytes())
+	assert.NoError(t, reader.Close())
+
+	r, err = p.GetReaderAt(t.Context(), "file.bin", 10)
+	require.NoError(t, err)
+	got, err := io.ReadAll(bufio.NewReader(readerOnly{stdio.NewReader(t.Context(), r)}))
+	require.NoError(t, err)
+	assert.Equal(t, data[10:], got)
+}
+
+func TestReaderUsesBoundContext(t *testing.T) {
+	p := newProvider(t, map[string][]byte{"file.bin": testData(10)})
+	r, err := p.GetReaderAt(t.Context(), "file.bin", 0)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err = io.ReadAll(stdio.NewReader(ctx, r))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestReadSeeker(t *testing.T) {
+	data := testData(1000)
+	p := newProvider(t, map[string][]byte{"file.bin": data})
+	s := stdio.NewReadSeeker(t.Context(), p, "file.bin")
+
+	buf := make([]byte, 10)
+	_, err := io.ReadFull(s, buf)
+	require.NoError(t, err)
+	assert.Equal(t, data[:10], buf)
+
+	off, err := s.Seek(100, io.SeekStart)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), off)
+	_, err = io.ReadFull(s, buf)
+	require.NoError(t, err)
+	assert.Equal(t, data[100:110], buf)
+
+	off, err = s.Seek(-20, io.SeekCurrent)
+	require.NoError(t, err)
+	assert.Equal(t, int64(90), off)
+	_, err = io.ReadFull(s, buf)
+	require.NoError(t, err)
+	assert.Equal(t, data[90:100], buf)
+
+	off, err = s.Seek(-10, io.SeekEnd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(990), off)
+	var rest bytes.Buffer
+	n, err := s.WriteTo(&rest)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), n)
+	assert.Equal(t, data[990:], rest.Bytes())
+
+	_, err = s.Seek(-1, io.SeekStart)
+	assert.Error(t, err)
+	_, err = s.Seek(0, 42)
+	assert.Error(t, err)
+
+	require.NoError(t, s.Close())
+	_, err = s.Read(buf)
+	assert.ErrorIs(t, err, stdio.ErrClosed)
+	assert.ErrorIs(t, s.Close(), stdio.ErrClosed)
+}
+
+func TestReadSeekerSeekEndRequiresDataManager(t *testing.T) {
+	p := newProvider(t, map[string][]byte{"file.bin": testData(10)})
+	s := stdio.NewReadSeeker(t.Context(), readerProvider{p}, "file.bin")
+	_, err := s.Seek(0, io.SeekEnd)
+	assert.Error(t, err)
+}
+
+// readerProvider hides the DataManager implementation of a provider.
+type readerProvider struct {
+	p stdio.ReaderProvider
+}
+
+func (r readerProvider) GetReaderAt(ctx context.Context, dataID string, position int64) (cache.ReadCloser, error) {
+	return r.p.GetReaderAt(ctx, dataID, position)
+}
+
+func TestReadSeekerServeContent(t *testing.T) {
+	data := testData(5000)
+	p := newProvider(t, map[string][]byte{"file.bin": data})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := stdio.NewReadSeeker(r.Context(), p, "file.bin")
+		defer s.Close()
+		http.ServeContent(w, r, "file.bin", time.Time{}, s)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/file.bin", nil)
+	req.Header.Set("Range", "bytes=1000-1999")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "bytes 1000-1999/5000", rec.Header().Get("Content-Range"))
+	assert.Equal(t, data[1000:2000], rec.Body.Bytes())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/file.bin", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, data, rec.Body.Bytes())
+}
+
+func TestReaderAtZip(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		f, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(strings.Repeat(name, 100)))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	p := newProvider(t, map[string][]byte{"archive.zip": archive.Bytes()})
+
+	zr, err := zip.NewReader(stdio.NewReaderAt(t.Context(), p, "archive.zip"), int64(archive.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 2)
+	f, err := zr.File[1].Open()
+	require.NoError(t, err)
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("b.txt", 100), string(content))
+}
+
+func TestReaderAtShortRead(t *testing.T) {
+	p := newProvider(t, map[string][]byte{"file.bin": []byte("0123456789")})
+	r := stdio.NewReaderAt(t.Context(), p, "file.bin")
+	buf := make([]byte, 4)
+	n, err := r.ReadAt(buf, 8)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []byte("89"), buf[:n])
+	n, err = r.ReadAt(buf, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, []byte("2345"), buf)
+	_, err = r.ReadAt(buf, -1)
+	assert.Error(t, err)
+}
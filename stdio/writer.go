@@ -0,0 +1,53 @@
+package stdio
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	cache "github.com/slawo/go-cache"
+)
+
+// Writer is an io.WriteCloser writing to a cache.WriteCloser with a bound
+// context.
+type Writer struct {
+	ctx context.Context
+	w   cache.WriteCloser
+}
+
+// NewWriter binds ctx to w.
+func NewWriter(ctx context.Context, w cache.WriteCloser) *Writer {
+	return &Writer{ctx: ctx, w: w}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	n, err := w.w.Write(w.ctx, p)
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
+
+// ReadFrom writes the data read from r until io.EOF.
+func (w *Writer) ReadFrom(r io.Reader) (read int64, err error) {
+	buf := make([]byte, bufferSize)
+	for {
+		n, rerr := r.Read(buf)
+		if n > 0 {
+			m, werr := w.Write(buf[:n])
+			read += int64(m)
+			if werr != nil {
+				return read, werr
+			}
+		}
+		if errors.Is(rerr, io.EOF) {
+			return read, nil
+		} else if rerr != nil {
+			return read, rerr
+		}
+	}
+}
+
+func (w *Writer) Close() error {
+	return w.w.Close()
+}
@@ -0,0 +1,27 @@
+package stdio_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/slawo/go-cache/stdio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterCopy(t *testing.T) {
+	data := testData(100 << 10)
+	p := newProvider(t, nil)
+	w, err := p.GetWriterAt(t.Context(), "file.bin", 0)
+	require.NoError(t, err)
+	writer := stdio.NewWriter(t.Context(), w)
+	n, err := io.Copy(writer, bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	require.NoError(t, writer.Close())
+
+	got, err := io.ReadAll(stdio.NewReadSeeker(t.Context(), p, "file.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+}
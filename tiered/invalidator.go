@@ -0,0 +1,83 @@
+package tiered
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// Listener receives the invalidations published by other cache instances.
+type Listener[K comparable] interface {
+	// Evict drops the local copy of the key.
+	Evict(key K)
+	// Flush drops every local entry, it is used when invalidations may have
+	// been missed.
+	Flush()
+}
+
+// Invalidator broadcasts the keys written by one cache instance so that the
+// other instances drop their local copies.
+//
+// Each instance identifies itself with a source, invalidations are never
+// delivered back to listeners registered with the source they come from.
+//
+//go:generate mockery --name Invalidator --output mocks
+type Invalidator[K comparable] interface {
+	// Invalidate announces that the key has been written by source.
+	Invalidate(ctx context.Context, source string, key K) error
+	// Subscribe registers l to receive the invalidations of other sources.
+	Subscribe(source string, l Listener[K]) error
+	// Unsubscribe stops the delivery of the invalidations to l, which was
+	// registered with Subscribe under source.
+	Unsubscribe(source string, l Listener[K]) error
+}
+
+// NewLocalInvalidator instantiates an Invalidator delivering invalidations
+// to the listeners registered in the same process.
+func NewLocalInvalidator[K comparable]() *LocalInvalidator[K] {
+	return &LocalInvalidator[K]{
+		listeners: make(map[string][]Listener[K]),
+	}
+}
+
+// LocalInvalidator is an in-process Invalidator, it suits several caches
+// sharing an L2 store within one process and tests.
+type LocalInvalidator[K comparable] struct {
+	mu        sync.RWMutex
+	listeners map[string][]Listener[K]
+}
+
+func (i *LocalInvalidator[K]) Invalidate(ctx context.Context, source string, key K) error {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	for s, listeners := range i.listeners {
+		if s == source {
+			continue
+		}
+		for _, l := range listeners {
+			l.Evict(key)
+		}
+	}
+	return nil
+}
+
+func (i *LocalInvalidator[K]) Subscribe(source string, l Listener[K]) error {
+	if l == nil {
+		return errors.New("local invalidator: missing listener")
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.listeners[source] = append(i.listeners[source], l)
+	return nil
+}
+
+func (i *LocalInvalidator[K]) Unsubscribe(source string, l Listener[K]) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.listeners[source] = slices.DeleteFunc(i.listeners[source], func(r Listener[K]) bool { return r == l })
+	if len(i.listeners[source]) == 0 {
+		delete(i.listeners, source)
+	}
+	return nil
+}
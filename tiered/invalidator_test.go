@@ -0,0 +1,47 @@
+package tiered_test
+
+import (
+	"testing"
+
+	"github.com/slawo/go-cache/tiered"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingListener struct {
+	evicted []string
+	flushed int
+}
+
+func (l *recordingListener) Evict(key string) {
+	l.evicted = append(l.evicted, key)
+}
+
+func (l *recordingListener) Flush() {
+	l.flushed++
+}
+
+func TestLocalInvalidatorSkipsSource(t *testing.T) {
+	inv := tiered.NewLocalInvalidator[string]()
+	a, b := &recordingListener{}, &recordingListener{}
+	require.NoError(t, inv.Subscribe("a", a))
+	require.NoError(t, inv.Subscribe("b", b))
+
+	require.NoError(t, inv.Invalidate(t.Context(), "a", "key"))
+	assert.Empty(t, a.evicted)
+	assert.Equal(t, []string{"key"}, b.evicted)
+
+	assert.EqualError(t, inv.Subscribe("c", nil), "local invalidator: missing listener")
+}
+
+func TestLocalInvalidatorUnsubscribe(t *testing.T) {
+	inv := tiered.NewLocalInvalidator[string]()
+	a, b := &recordingListener{}, &recordingListener{}
+	require.NoError(t, inv.Subscribe("a", a))
+	require.NoError(t, inv.Subscribe("a", b))
+	require.NoError(t, inv.Unsubscribe("a", a))
+
+	require.NoError(t, inv.Invalidate(t.Context(), "other", "key"))
+	assert.Empty(t, a.evicted)
+	assert.Equal(t, []string{"key"}, b.evicted)
+}
@@ -0,0 +1,300 @@
+// Package tiered provides a two level cache keeping an in-process LRU (L1)
+// in front of a shared store (L2), such as Redis or a datastore provider
+// exposed through the codec package.
+package tiered
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/slawo/go-cache/memory"
+)
+
+// WriteMode defines how writes are applied to the tiers.
+type WriteMode int
+
+const (
+	// WriteThrough writes to L2 then stores the value in L1.
+	WriteThrough WriteMode = iota
+	// WriteAround writes to L2 and drops the L1 copy, the value reaches L1
+	// on the next read.
+	WriteAround
+)
+
+// ExpiringCache is implemented by L2 stores which support a per entry
+// time to live.
+type ExpiringCache[K comparable, D any] interface {
+	PutWithTTL(key K, data D, ttl time.Duration) error
+}
+
+// Options configures a tiered cache.
+type Options[K comparable] struct {
+	// L1Capacity is the number of entries kept in the L1 LRU.
+	L1Capacity int
+	// L1TTL bounds how long an entry is served from L1, zero disables expiry.
+	L1TTL time.Duration
+	// L2TTL is the time to live of the entries written to L2, zero disables
+	// expiry. It requires an L2 implementing ExpiringCache.
+	L2TTL time.Duration
+	// Mode selects how writes are applied, it defaults to WriteThrough.
+	Mode WriteMode
+	// Invalidator, when set, broadcasts this instance's writes and evicts
+	// the L1 entries written by other instances.
+	Invalidator Invalidator[K]
+	// Now returns the current time, it defaults to time.Now.
+	Now func() time.Time
+}
+
+// New instantiates a tiered cache in front of the given L2 store.
+func New[K comparable, D any](l2 memory.Cache[K, D], opts Options[K]) (*Cache[K, D], error) {
+	if l2 == nil {
+		return nil, errors.New("tiered cache: missing l2 cache")
+	}
+	if opts.L1TTL < 0 || opts.L2TTL < 0 {
+		return nil, errors.New("tiered cache: ttl cannot be negative")
+	}
+	if opts.Mode != WriteThrough && opts.Mode != WriteAround {
+		return nil, fmt.Errorf("tiered cache: unknown write mode %d", opts.Mode)
+	}
+	l2TTL, _ := l2.(ExpiringCache[K, D])
+	l2Deleter, _ := l2.(memory.Deleter[K])
+	if opts.L2TTL > 0 && l2TTL == nil {
+		return nil, errors.New("tiered cache: l2 cache does not support ttl")
+	}
+	l1, err := memory.NewLRU[K, entry[D]](opts.L1Capacity)
+	if err != nil {
+		return nil, fmt.Errorf("tiered cache: %w", err)
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("tiered cache: %w", err)
+	}
+	c := &Cache[K, D]{
+		id:        hex.EncodeToString(id),
+		opts:      opts,
+		l1:        l1,
+		l2:        l2,
+		l2TTL:     l2TTL,
+		l2Deleter: l2Deleter,
+		writes:    make(map[K]*keyLock),
+	}
+	if opts.Invalidator != nil {
+		if err := opts.Invalidator.Subscribe(c.id, c); err != nil {
+			return nil, fmt.Errorf("tiered cache: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// Cache is a two level cache implementing memory.Cache.
+//
+// Reads are served from L1 when possible and promoted from L2 to L1
+// otherwise. Writes always reach L2 before L1 is updated, the writes of a key
+// are serialised so that L1 is updated in the order L2 was written.
+type Cache[K comparable, D any] struct {
+	id    string
+	opts  Options[K]
+	l2    memory.Cache[K, D]
+	l2TTL ExpiringCache[K, D]
+	// l2Deleter is nil when L2 cannot delete entries
+	l2Deleter memory.Deleter[K]
+
+	mu sync.Mutex
+	l1 *memory.LRU[K, entry[D]]
+	// gen is bumped on every L1 change, a value read from L2 is only
+	// promoted if no change happened during the read.
+	gen uint64
+	// writes holds the locks of the keys being written
+	writes map[K]*keyLock
+}
+
+// keyLock serialises the writes of a key.
+type keyLock struct {
+	mu sync.Mutex
+	// refs counts the writers holding or waiting for the lock, guarded by
+	// the mutex of the cache
+	refs int
+}
+
+type entry[D any] struct {
+	data    D
+	expires time.Time
+}
+
+// Has reports whether either tier has a key
+func (c *Cache[K, D]) Has(key K) (bool, error) {
+	if _, found := c.getL1(key); found {
+		return true, nil
+	}
+	return c.l2.Has(key)
+}
+
+// Get returns the value for the given key from L1 or from L2, in which case
+// the value is promoted to L1.
+func (c *Cache[K, D]) Get(key K) (D, error) {
+	if data, found := c.getL1(key); found {
+		return data, nil
+	}
+	c.mu.Lock()
+	gen := c.gen
+	c.mu.Unlock()
+
+	data, err := c.l2.Get(key)
+	if err != nil {
+		return data, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen == c.gen {
+		c.putL1(key, data)
+	}
+	return data, nil
+}
+
+// Put writes the value to L2, then updates L1 according to the write mode
+// and notifies the other instances.
+func (c *Cache[K, D]) Put(key K, data D) error {
+	return c.PutContext(context.Background(), key, data)
+}
+
+// PutContext is Put with the context of the invalidation broadcast.
+func (c *Cache[K, D]) PutContext(ctx context.Context, key K, data D) error {
+	unlock := c.lockKey(key)
+	defer unlock()
+	var err error
+	if c.opts.L2TTL > 0 {
+		err = c.l2TTL.PutWithTTL(key, data, c.opts.L2TTL)
+	} else {
+		err = c.l2.Put(key, data)
+	}
+	if err != nil {
+		c.Evict(key)
+		return err
+	}
+	c.mu.Lock()
+	c.gen++
+	if c.opts.Mode == WriteThrough {
+		c.putL1(key, data)
+	} else {
+		c.l1.Delete(key)
+	}
+	c.mu.Unlock()
+	return c.invalidate(ctx, key)
+}
+
+// Close unsubscribes the cache from the invalidator, the writes of the other
+// instances no longer evict its L1 entries. The L2 store is left open.
+func (c *Cache[K, D]) Close() error {
+	if c.opts.Invalidator == nil {
+		return nil
+	}
+	if err := c.opts.Invalidator.Unsubscribe(c.id, c); err != nil {
+		return fmt.Errorf("tiered cache: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the key from both tiers and notifies the other instances,
+// it requires an L2 implementing memory.Deleter.
+func (c *Cache[K, D]) Delete(key K) error {
+	return c.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext is Delete with the context of the invalidation broadcast.
+func (c *Cache[K, D]) DeleteContext(ctx context.Context, key K) error {
+	if c.l2Deleter == nil {
+		return errors.New("tiered cache: l2 cache does not support delete")
+	}
+	unlock := c.lockKey(key)
+	defer unlock()
+	c.Evict(key)
+	err := c.l2Deleter.Delete(key)
+	if err != nil && !errors.Is(err, memory.ErrNotFound) {
+		return err
+	}
+	if ierr := c.invalidate(ctx, key); ierr != nil {
+		return ierr
+	}
+	return err
+}
+
+// Evict drops the L1 copy of the key, L2 is left untouched.
+func (c *Cache[K, D]) Evict(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.l1.Delete(key)
+}
+
+// Flush drops every L1 entry, L2 is left untouched.
+func (c *Cache[K, D]) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.l1.Clear()
+}
+
+func (c *Cache[K, D]) getL1(key K) (D, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, err := c.l1.Get(key)
+	if err != nil {
+		return e.data, false
+	}
+	if !e.expires.IsZero() && !c.opts.Now().Before(e.expires) {
+		c.l1.Delete(key)
+		var data D
+		return data, false
+	}
+	return e.data, true
+}
+
+// putL1 stores the value in L1, the lock must be held.
+func (c *Cache[K, D]) putL1(key K, data D) {
+	e := entry[D]{data: data}
+	if c.opts.L1TTL > 0 {
+		e.expires = c.opts.Now().Add(c.opts.L1TTL)
+	}
+	c.l1.Put(key, e)
+}
+
+// lockKey serialises the writes of a key, it returns the function releasing
+// the lock.
+func (c *Cache[K, D]) lockKey(key K) (unlock func()) {
+	c.mu.Lock()
+	l := c.writes[key]
+	if l == nil {
+		l = &keyLock{}
+		c.writes[key] = l
+	}
+	l.refs++
+	c.mu.Unlock()
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		c.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(c.writes, key)
+		}
+		c.mu.Unlock()
+	}
+}
+
+func (c *Cache[K, D]) invalidate(ctx context.Context, key K) error {
+	if c.opts.Invalidator == nil {
+		return nil
+	}
+	if err := c.opts.Invalidator.Invalidate(ctx, c.id, key); err != nil {
+		return fmt.Errorf("tiered cache: unable to broadcast invalidation: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,320 @@
+package tiered_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/slawo/go-cache/memory"
+	"github.com/slawo/go-cache/tiered"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingCache wraps an LRU to count the calls reaching L2 and record TTLs.
+type countingCache struct {
+	*memory.LRU[string, int]
+	gets int
+	ttls map[string]time.Duration
+}
+
+func newCountingCache(t *testing.T) *countingCache {
+	lru, err := memory.NewLRU[string, int](100)
+	require.NoError(t, err)
+	return &countingCache{LRU: lru, ttls: map[string]time.Duration{}}
+}
+
+func (c *countingCache) Get(key string) (int, error) {
+	c.gets++
+	return c.LRU.Get(key)
+}
+
+func (c *countingCache) PutWithTTL(key string, data int, ttl time.Duration) error {
+	c.ttls[key] = ttl
+	return c.LRU.Put(key, data)
+}
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func TestNewValidatesOptions(t *testing.T) {
+	l2 := newCountingCache(t)
+	lru, err := memory.NewLRU[string, int](10)
+	require.NoError(t, err)
+
+	_, err = tiered.New[string, int](nil, tiered.Options[string]{L1Capacity: 10})
+	assert.EqualError(t, err, "tiered cache: missing l2 cache")
+	_, err = tiered.New[string, int](l2, tiered.Options[string]{})
+	assert.EqualError(t, err, "tiered cache: cannot initialize cache with capacity 0")
+	_, err = tiered.New[string, int](l2, tiered.Options[string]{L1Capacity: 10, L1TTL: -1})
+	assert.EqualError(t, err, "tiered cache: ttl cannot be negative")
+	_, err = tiered.New[string, int](l2, tiered.Options[string]{L1Capacity: 10, Mode: 5})
+	assert.EqualError(t, err, "tiered cache: unknown write mode 5")
+	_, err = tiered.New[string, int](lru, tiered.Options[string]{L1Capacity: 10, L2TTL: time.Minute})
+	assert.EqualError(t, err, "tiered cache: l2 cache does not support ttl")
+}
+
+func TestTieredImplementsCache(t *testing.T) {
+	var c memory.Cache[string, int]
+	c, err := tiered.New[string, int](newCountingCache(t), tiered.Options[string]{L1Capacity: 1})
+	assert.NoError(t, err)
+	assert.NotNil(t, c)
+}
+
+func TestTieredReadPromotion(t *testing.T) {
+	l2 := newCountingCache(t)
+	require.NoError(t, l2.Put("key", 1))
+	c, err := tiered.New[string, int](l2, tiered.Options[string]{L1Capacity: 10})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		v, err := c.Get("key")
+		require.NoError(t, err)
+		assert.Equal(t, 1, v)
+	}
+	assert.Equal(t, 1, l2.gets, "only the first read should reach l2")
+
+	_, err = c.Get("missing")
+	assert.ErrorIs(t, err, memory.ErrNotFound)
+}
+
+func TestTieredWriteThrough(t *testing.T) {
+	l2 := newCountingCache(t)
+	c, err := tiered.New[string, int](l2, tiered.Options[string]{L1Capacity: 10})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Put("key", 1))
+	v, err := c.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, 0, l2.gets)
+
+	v, err = l2.LRU.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, 1, v)
+}
+
+func TestTieredWriteAround(t *testing.T) {
+	l2 := newCountingCache(t)
+	c, err := tiered.New[string, int](l2, tiered.Options[string]{L1Capacity: 10, Mode: tiered.WriteAround})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Put("key", 1))
+	_, err = c.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, 1, l2.gets, "write around should not populate l1")
+
+	require.NoError(t, c.Put("key", 2))
+	v, err := c.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, 2, v, "write around should drop the l1 copy")
+	assert.Equal(t, 2, l2.gets)
+}
+
+func TestTieredTTLs(t *testing.T) {
+	l2 := newCountingCache(t)
+	clk := &clock{now: time.Unix(1000, 0)}
+	c, err := tiered.New[string, int](l2, tiered.Options[string]{
+		L1Capacity: 10,
+		L1TTL:      time.Second,
+		L2TTL:      time.Hour,
+		Now:        clk.Now,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Put("key", 1))
+	assert.Equal(t, time.Hour, l2.ttls["key"])
+
+	clk.now = clk.now.Add(999 * time.Millisecond)
+	_, err = c.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, 0, l2.gets)
+
+	clk.now = clk.now.Add(time.Millisecond)
+	_, err = c.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, 1, l2.gets, "expired l1 entries should be read from l2")
+}
+
+func TestTieredDelete(t *testing.T) {
+	l2 := newCountingCache(t)
+	c, err := tiered.New[string, int](l2, tiered.Options[string]{L1Capacity: 10})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Put("key", 1))
+	require.NoError(t, c.Delete("key"))
+	found, err := c.Has("key")
+	assert.NoError(t, err)
+	assert.False(t, found)
+	assert.ErrorIs(t, c.Delete("key"), memory.ErrNotFound)
+}
+
+func TestTieredInvalidation(t *testing.T) {
+	l2 := newCountingCache(t)
+	inv := tiered.NewLocalInvalidator[string]()
+	opts := tiered.Options[string]{L1Capacity: 10, Invalidator: inv}
+	c1, err := tiered.New[string, int](l2, opts)
+	require.NoError(t, err)
+	c2, err := tiered.New[string, int](l2, opts)
+	require.NoError(t, err)
+
+	require.NoError(t, c1.Put("key", 1))
+	v, err := c2.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	require.NoError(t, c1.Put("key", 2))
+	v, err = c2.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, 2, v, "the write on c1 should evict the l1 copy of c2")
+
+	gets := l2.gets
+	v, err = c1.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, 2, v)
+	assert.Equal(t, gets, l2.gets, "c1 should not invalidate its own l1 entry")
+}
+
+func TestTieredFlush(t *testing.T) {
+	l2 := newCountingCache(t)
+	c, err := tiered.New[string, int](l2, tiered.Options[string]{L1Capacity: 10})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Put("key", 1))
+	c.Flush()
+	_, err = c.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, 1, l2.gets)
+}
+
+type failingCache struct {
+	*countingCache
+}
+
+func (c failingCache) Put(key string, data int) error {
+	return errors.New("l2 unavailable")
+}
+
+func TestTieredPutErrorKeepsL1Consistent(t *testing.T) {
+	l2 := newCountingCache(t)
+	require.NoError(t, l2.Put("key", 1))
+	c, err := tiered.New[string, int](failingCache{l2}, tiered.Options[string]{L1Capacity: 10})
+	require.NoError(t, err)
+
+	_, err = c.Get("key")
+	require.NoError(t, err)
+	assert.EqualError(t, c.Put("key", 2), "l2 unavailable")
+	v, err := c.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, 2, l2.gets)
+}
+
+// pausingCache pauses the writes of the value 1 once they reached the LRU,
+// until release is closed.
+type pausingCache struct {
+	*countingCache
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (c pausingCache) Put(key string, data int) error {
+	err := c.countingCache.Put(key, data)
+	if data == 1 {
+		close(c.entered)
+		<-c.release
+	}
+	return err
+}
+
+func TestTieredSerialisesWritesOfAKey(t *testing.T) {
+	l2 := pausingCache{newCountingCache(t), make(chan struct{}), make(chan struct{})}
+	c, err := tiered.New[string, int](l2, tiered.Options[string]{L1Capacity: 10})
+	require.NoError(t, err)
+
+	first := make(chan error)
+	go func() { first <- c.Put("key", 1) }()
+	<-l2.entered
+	second := make(chan error)
+	go func() { second <- c.Put("key", 2) }()
+	select {
+	case <-second:
+		t.Fatal("the second write overtook the first one")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(l2.release)
+	require.NoError(t, <-first)
+	require.NoError(t, <-second)
+
+	v, err := c.Get("key")
+	require.NoError(t, err)
+	stored, err := l2.LRU.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, stored, v, "l1 and l2 disagree")
+	assert.Equal(t, 2, v)
+}
+
+// contextInvalidator records the contexts of the invalidations.
+type contextInvalidator struct {
+	tiered.Invalidator[string]
+	ctxs []context.Context
+}
+
+func (i *contextInvalidator) Invalidate(ctx context.Context, source string, key string) error {
+	i.ctxs = append(i.ctxs, ctx)
+	return i.Invalidator.Invalidate(ctx, source, key)
+}
+
+func TestTieredInvalidationUsesCallerContext(t *testing.T) {
+	inv := &contextInvalidator{Invalidator: tiered.NewLocalInvalidator[string]()}
+	c, err := tiered.New[string, int](newCountingCache(t), tiered.Options[string]{L1Capacity: 10, Invalidator: inv})
+	require.NoError(t, err)
+
+	type key struct{}
+	ctx := context.WithValue(t.Context(), key{}, "caller")
+	require.NoError(t, c.PutContext(ctx, "key", 1))
+	require.NoError(t, c.DeleteContext(ctx, "key"))
+	require.Len(t, inv.ctxs, 2)
+	for _, got := range inv.ctxs {
+		assert.Equal(t, "caller", got.Value(key{}))
+	}
+}
+
+// putOnlyCache is an L2 which cannot delete entries.
+type putOnlyCache struct {
+	memory.Cache[string, int]
+}
+
+func TestTieredDeleteRequiresDeleter(t *testing.T) {
+	c, err := tiered.New[string, int](putOnlyCache{newCountingCache(t)}, tiered.Options[string]{L1Capacity: 10})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Put("key", 1))
+	assert.EqualError(t, c.Delete("key"), "tiered cache: l2 cache does not support delete")
+}
+
+func TestTieredCloseStopsInvalidations(t *testing.T) {
+	l2 := newCountingCache(t)
+	inv := tiered.NewLocalInvalidator[string]()
+	opts := tiered.Options[string]{L1Capacity: 10, Invalidator: inv}
+	c1, err := tiered.New[string, int](l2, opts)
+	require.NoError(t, err)
+	c2, err := tiered.New[string, int](l2, opts)
+	require.NoError(t, err)
+
+	require.NoError(t, c2.Put("key", 1))
+	require.NoError(t, c2.Close())
+	require.NoError(t, c1.Put("key", 2))
+
+	gets := l2.gets
+	v, err := c2.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, 1, v, "a closed cache is not invalidated")
+	assert.Equal(t, gets, l2.gets)
+}